@@ -0,0 +1,34 @@
+// dingtalk-mock 以独立服务运行模拟的钉钉接口
+//
+// 启动后将 DINGTALK_OAPI_BASE_URL 和 DINGTALK_API_BASE_URL 指向该服务即可离线调试：
+//
+//	go run ./cmd/dingtalk-mock -addr :9090 -fixtures fixtures.json
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "监听地址")
+	fixturesPath := flag.String("fixtures", "", "模拟数据 JSON 文件，不指定时使用内置示例数据")
+	flag.Parse()
+
+	fixtures := dingtalkmock.DefaultFixtures()
+	if *fixturesPath != "" {
+		var err error
+		fixtures, err = dingtalkmock.LoadFixtures(*fixturesPath)
+		if err != nil {
+			logrus.Fatalf("加载模拟数据失败: %v", err)
+		}
+	}
+
+	logrus.Infof("模拟钉钉服务启动，监听地址: %s", *addr)
+	if err := http.ListenAndServe(*addr, dingtalkmock.New(fixtures)); err != nil {
+		logrus.Fatalf("模拟钉钉服务启动失败: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// reencrypt-secrets 使用当前版本的主密钥重新加密数据库中的敏感字段
+//
+// 启用加密或轮换主密钥后执行，旧版本的主密钥需要保留在 SECRET_KEYS 中直到执行完成：
+//
+//	SECRET_KEYS=v1:xxx,v2:yyy SECRET_KEY_VERSION=v2 go run ./cmd/reencrypt-secrets
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/secret"
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只统计需要重新加密的行数，不修改数据")
+	flag.Parse()
+
+	logrus.SetOutput(os.Stdout)
+
+	cfg := config.LoadConfig()
+	keyring, err := secret.Init(cfg.Security.SecretKeys, cfg.Security.SecretKeyVersion)
+	if err != nil {
+		logrus.Fatalf("初始化加密密钥失败: %v", err)
+	}
+	if !keyring.Enabled() {
+		logrus.Fatal("未配置 SECRET_KEYS")
+	}
+
+	if err := database.InitMySQL(&cfg.MySQL, false); err != nil {
+		logrus.Fatalf("初始化MySQL失败: %v", err)
+	}
+
+	// 加密后的值比明文长，先按模型调整字段长度
+	if !*dryRun {
+		if err := database.GetDB().Migrator().AlterColumn(&model.AccessToken{}, "AppSecret"); err != nil {
+			logrus.Fatalf("调整 access_token.app_secret 字段失败: %v", err)
+		}
+		if err := database.GetDB().Migrator().AlterColumn(&model.SSOConfig{}, "AppSecret"); err != nil {
+			logrus.Fatalf("调整 sso_config.app_secret 字段失败: %v", err)
+		}
+	}
+
+	results, err := service.NewSecretService().Reencrypt(*dryRun)
+	for _, result := range results {
+		logrus.Infof("%s.%s: 共 %d 行, 重新加密 %d 行, 失败 %d 行", result.Table, result.Column, result.Total, result.Reencrypted, result.Failed)
+	}
+	if err != nil {
+		logrus.Fatalf("重新加密失败: %v", err)
+	}
+	logrus.Infof("重新加密完成，当前密钥版本: %s", keyring.ActiveVersion())
+}
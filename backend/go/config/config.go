@@ -1,8 +1,11 @@
 package config
 
 import (
-	"github.com/sirupsen/logrus"
 	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // Config 应用配置
@@ -11,6 +14,10 @@ type Config struct {
 	MySQL    MySQLConfig
 	Redis    RedisConfig
 	DingTalk DingTalkConfig
+	Download DownloadConfig
+	Storage  StorageConfig
+	Security SecurityConfig
+	Legacy   LegacyConfig
 }
 
 // ServerConfig 服务器配置
@@ -41,6 +48,59 @@ type RedisConfig struct {
 type DingTalkConfig struct {
 	AppKey    string
 	AppSecret string
+
+	OAPIBaseURL string // 旧版接口地址，默认 https://oapi.dingtalk.com，测试或离线环境可指向模拟服务
+	APIBaseURL  string // 新版接口地址，默认 https://api.dingtalk.com
+
+	TokenRefreshInterval time.Duration // 后台检查 AccessToken 是否需要刷新的间隔
+	TokenRefreshPercent  int           // 在有效期过去百分之多少时提前刷新，如 80
+
+	OrgSyncInterval time.Duration // 后台同步组织架构的间隔，0 表示不定时同步
+
+	ThrottleRetries int           // 钉钉返回限流错误（如 errcode 90018）时的最大重试次数
+	ThrottleBackoff time.Duration // 限流重试的初始等待时间，每次重试翻倍
+}
+
+// DownloadConfig 下载任务配置
+type DownloadConfig struct {
+	Workers      int           // 并发执行任务的worker数量
+	QueueSize    int           // 内存队列深度，超出部分留在数据库中等待轮询
+	PollInterval time.Duration // 轮询数据库待执行任务的间隔
+	StaleTimeout time.Duration // 运行中任务超过该时间未更新视为中断，应大于心跳间隔的 3 倍
+	Heartbeat    time.Duration // 任务执行期间刷新 updated_at 的间隔
+	ExportDir    string        // 导出文件的临时生成目录
+	HiddenField  string        // 不可查看字段在导出中的处理方式：mask 脱敏, drop 移除
+}
+
+// StorageConfig 导出文件存储配置
+type StorageConfig struct {
+	Type            string        // 存储类型：local, s3
+	LocalDir        string        // 本地存储目录
+	SignSecret      string        // 本地存储签名URL的密钥
+	PublicURL       string        // 本地存储签名URL的访问前缀
+	Endpoint        string        // S3 兼容服务地址，如 127.0.0.1:9000
+	AccessKey       string        // S3 AccessKey
+	SecretKey       string        // S3 SecretKey
+	Bucket          string        // S3 存储桶
+	Region          string        // S3 区域
+	UseSSL          bool          // 是否使用 HTTPS 访问 S3
+	SignedURLExpire time.Duration // 签名URL有效期
+	Retention       time.Duration // 导出文件保留时长，过期后由清理任务删除
+	CleanupInterval time.Duration // 清理任务执行间隔
+}
+
+// SecurityConfig 敏感字段加密配置
+type SecurityConfig struct {
+	SecretKeys       string // 主密钥列表，格式 版本:base64密钥，多个以逗号分隔，如 v1:xxx,v2:yyy
+	SecretKeyVersion string // 加密新数据使用的密钥版本，为空时使用列表中的最后一个
+}
+
+// LegacyConfig 旧版API代理服务（backend/python）配置
+type LegacyConfig struct {
+	URL            string        // Python 服务地址，如 http://localhost:8081
+	Secret         string        // 调用 Python 服务的共享密钥，与 Python 侧 LEGACY_SERVICE_SECRET 一致
+	Timeout        time.Duration // 单次代理调用的超时时间
+	HealthInterval time.Duration // 后台健康检查间隔
 }
 
 var GlobalConfig *Config
@@ -70,9 +130,54 @@ func LoadConfig() *Config {
 		DingTalk: DingTalkConfig{
 			AppKey:    getEnv("DINGTALK_APPKEY", ""),
 			AppSecret: getEnv("DINGTALK_APPSECRET", ""),
+
+			OAPIBaseURL: getEnv("DINGTALK_OAPI_BASE_URL", "https://oapi.dingtalk.com"),
+			APIBaseURL:  getEnv("DINGTALK_API_BASE_URL", "https://api.dingtalk.com"),
+
+			TokenRefreshInterval: getEnvDuration("DINGTALK_TOKEN_REFRESH_INTERVAL", time.Minute),
+			TokenRefreshPercent:  getEnvInt("DINGTALK_TOKEN_REFRESH_PERCENT", 80),
+
+			OrgSyncInterval: getEnvDuration("DINGTALK_ORG_SYNC_INTERVAL", 0),
+
+			ThrottleRetries: getEnvInt("DINGTALK_THROTTLE_RETRIES", 3),
+			ThrottleBackoff: getEnvDuration("DINGTALK_THROTTLE_BACKOFF", time.Second),
+		},
+		Download: DownloadConfig{
+			Workers:      getEnvInt("DOWNLOAD_WORKERS", 4),
+			QueueSize:    getEnvInt("DOWNLOAD_QUEUE_SIZE", 100),
+			PollInterval: getEnvDuration("DOWNLOAD_POLL_INTERVAL", 10*time.Second),
+			StaleTimeout: getEnvDuration("DOWNLOAD_STALE_TIMEOUT", 2*time.Minute),
+			Heartbeat:    getEnvDuration("DOWNLOAD_HEARTBEAT_INTERVAL", 30*time.Second),
+			ExportDir:    getEnv("DOWNLOAD_EXPORT_DIR", "./exports"),
+			HiddenField:  getEnv("DOWNLOAD_HIDDEN_FIELD", "mask"),
+		},
+		Storage: StorageConfig{
+			Type:            getEnv("STORAGE_TYPE", "local"),
+			LocalDir:        getEnv("STORAGE_LOCAL_DIR", "./storage"),
+			PublicURL:       getEnv("STORAGE_PUBLIC_URL", "/api/v1/storage/file"),
+			Endpoint:        getEnv("STORAGE_S3_ENDPOINT", ""),
+			AccessKey:       getEnv("STORAGE_S3_ACCESS_KEY", ""),
+			SecretKey:       getEnv("STORAGE_S3_SECRET_KEY", ""),
+			Bucket:          getEnv("STORAGE_S3_BUCKET", "dd-oa-download"),
+			Region:          getEnv("STORAGE_S3_REGION", ""),
+			UseSSL:          getEnv("STORAGE_S3_USE_SSL", "false") == "true",
+			SignedURLExpire: getEnvDuration("STORAGE_SIGNED_URL_EXPIRE", 15*time.Minute),
+			Retention:       getEnvDuration("STORAGE_RETENTION", 7*24*time.Hour),
+			CleanupInterval: getEnvDuration("STORAGE_CLEANUP_INTERVAL", time.Hour),
+		},
+		Security: SecurityConfig{
+			SecretKeys:       getEnv("SECRET_KEYS", ""),
+			SecretKeyVersion: getEnv("SECRET_KEY_VERSION", ""),
+		},
+		Legacy: LegacyConfig{
+			URL:            getEnv("LEGACY_SERVICE_URL", "http://localhost:8081"),
+			Secret:         getEnv("LEGACY_SERVICE_SECRET", ""),
+			Timeout:        getEnvDuration("LEGACY_SERVICE_TIMEOUT", 30*time.Second),
+			HealthInterval: getEnvDuration("LEGACY_HEALTH_INTERVAL", 30*time.Second),
 		},
 	}
 	config.Server.JWTSecret = getEnv("JWT_SECRET", "ddoalistdownload-secret-key")
+	config.Storage.SignSecret = getEnv("STORAGE_SIGN_SECRET", config.Server.JWTSecret)
 
 	GlobalConfig = config
 	logrus.Info("配置加载完成")
@@ -87,3 +192,21 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt 获取整数类型的环境变量，解析失败时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration 获取时间间隔类型的环境变量（如 30s、5m），解析失败时返回默认值
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
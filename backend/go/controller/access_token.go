@@ -29,6 +29,7 @@ func NewAccessTokenController() *AccessTokenController {
 // @Accept json
 // @Produce json
 // @Param company_id query uint true "公司ID"
+// @Param token_type query string false "令牌类型：legacy, oauth2" default(legacy)
 // @Success 200 {object} model.AccessToken
 // @Router /api/access-token [get]
 func (c *AccessTokenController) GetAccessToken(ctx *gin.Context) {
@@ -41,7 +42,7 @@ func (c *AccessTokenController) GetAccessToken(ctx *gin.Context) {
 	}
 
 	// 调用服务
-	accessToken, err := c.accessTokenService.GetAccessToken(ctx, uint(companyID))
+	accessToken, err := c.accessTokenService.GetAccessToken(ctx, uint(companyID), ctx.DefaultQuery("token_type", model.AccessTokenTypeLegacy))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -188,6 +189,7 @@ func (c *AccessTokenController) GetAccessTokenList(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param company_id query uint true "公司ID"
+// @Param token_type query string false "令牌类型：legacy, oauth2" default(legacy)
 // @Success 200 {object} model.AccessToken
 // @Router /api/access-token/refresh [post]
 func (c *AccessTokenController) RefreshAccessToken(ctx *gin.Context) {
@@ -200,7 +202,7 @@ func (c *AccessTokenController) RefreshAccessToken(ctx *gin.Context) {
 	}
 
 	// 调用服务
-	accessToken, err := c.accessTokenService.RefreshAccessToken(ctx, uint(companyID))
+	accessToken, err := c.accessTokenService.RefreshAccessToken(ctx, uint(companyID), ctx.DefaultQuery("token_type", model.AccessTokenTypeLegacy))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -216,6 +218,7 @@ func (c *AccessTokenController) RefreshAccessToken(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param company_id query uint true "公司ID"
+// @Param token_type query string false "令牌类型：legacy, oauth2" default(legacy)
 // @Success 200 {object} map[string]string
 // @Router /api/access-token/test [post]
 func (c *AccessTokenController) TestAccessToken(ctx *gin.Context) {
@@ -228,7 +231,7 @@ func (c *AccessTokenController) TestAccessToken(ctx *gin.Context) {
 	}
 
 	// 调用服务
-	err = c.accessTokenService.TestAccessToken(ctx, uint(companyID))
+	err = c.accessTokenService.TestAccessToken(ctx, uint(companyID), ctx.DefaultQuery("token_type", model.AccessTokenTypeLegacy))
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -236,3 +239,64 @@ func (c *AccessTokenController) TestAccessToken(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "AccessToken有效"})
 }
+
+// GetTokenHealth 获取各公司AccessToken健康状态
+// @Summary 获取AccessToken健康状态
+// @Description 返回各公司AccessToken的过期时间、计划刷新时间及最近一次刷新结果
+// @Tags AccessToken管理
+// @Produce json
+// @Success 200 {array} service.TokenHealth
+// @Router /api/access-token/health [get]
+func (c *AccessTokenController) GetTokenHealth(ctx *gin.Context) {
+	health, err := c.accessTokenService.GetTokenHealth()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, health)
+}
+
+// GetRefreshLogs 获取AccessToken刷新记录
+// @Summary 获取AccessToken刷新记录
+// @Description 分页获取AccessToken刷新记录，可按公司筛选
+// @Tags AccessToken管理
+// @Produce json
+// @Param company_id query uint false "公司ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/access-token/refresh-logs [get]
+func (c *AccessTokenController) GetRefreshLogs(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID := uint64(0)
+	if companyIDStr := ctx.Query("company_id"); companyIDStr != "" {
+		companyID, err = strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的公司ID"})
+			return
+		}
+	}
+
+	logs, total, err := c.accessTokenService.GetRefreshLogs(uint(companyID), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"list":  logs,
+		"total": total,
+		"page":  page,
+		"size":  pageSize,
+	})
+}
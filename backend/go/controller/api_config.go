@@ -4,6 +4,7 @@ import (
 	"github.com/ddoalistdownload/backend/model"
 	"github.com/ddoalistdownload/backend/service"
 	"github.com/gin-gonic/gin"
+	"errors"
 	"net/http"
 	"strconv"
 )
@@ -11,12 +12,14 @@ import (
 // APIConfigController API配置控制器
 type APIConfigController struct {
 	apiConfigService *service.APIConfigService
+	syncStateService *service.SyncStateService
 }
 
 // NewAPIConfigController 创建API配置控制器实例
 func NewAPIConfigController() *APIConfigController {
 	return &APIConfigController{
 		apiConfigService: service.NewAPIConfigService(),
+		syncStateService: service.NewSyncStateService(),
 	}
 }
 
@@ -292,6 +295,14 @@ func (c *APIConfigController) Test(ctx *gin.Context) {
 	
 	// 调用服务层测试
 	result, err := c.apiConfigService.Test(&apiConfig)
+	if errors.Is(err, service.ErrLegacyServiceUnavailable) {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -307,3 +318,157 @@ func (c *APIConfigController) Test(ctx *gin.Context) {
 		"data":    result,
 	})
 }
+
+// LegacyHealth 检查旧版API服务状态
+// @Summary 检查旧版API服务状态
+// @Description 立即请求 Python 旧版API服务的健康检查接口，Type 为 2 的API配置通过该服务调用
+// @Tags API配置管理
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-config/legacy/health [get]
+func (c *APIConfigController) LegacyHealth(ctx *gin.Context) {
+	health := service.NewLegacyClient().Check(ctx.Request.Context())
+	if health == nil || health.Status != service.LegacyStatusUp {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": service.ErrLegacyServiceUnavailable.Error(),
+			"data":    health,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "旧版API服务运行正常",
+		"data":    health,
+	})
+}
+
+// PreviewMapping 预览响应映射
+// @Summary 预览响应映射
+// @Description 将API配置中的记录路径和映射应用到示例响应，返回导出列和映射后的行
+// @Tags API配置管理
+// @Accept json
+// @Produce json
+// @Param body body object true "api_config: API配置（使用 record_path、module、code、mapping），sample: 示例响应"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-config/mapping/preview [post]
+func (c *APIConfigController) PreviewMapping(ctx *gin.Context) {
+	// 绑定请求参数
+	var req struct {
+		APIConfig model.APIConfig        `json:"api_config"`
+		Sample    map[string]interface{} `json:"sample" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	preview, err := c.apiConfigService.PreviewMapping(&req.APIConfig, req.Sample)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "预览映射成功",
+		"data":    preview,
+	})
+}
+
+// syncStateParams 解析增量同步状态接口的API配置ID和公司ID
+func syncStateParams(ctx *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "ID参数错误",
+			"data":    nil,
+		})
+		return 0, 0, false
+	}
+
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32)
+	if err != nil || companyID == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return 0, 0, false
+	}
+
+	return uint(id), uint(companyID), true
+}
+
+// GetSyncState 获取增量同步状态
+// @Summary 获取增量同步状态
+// @Description 获取公司在该API配置下的增量同步水位和已记录的主键数量
+// @Tags API配置管理
+// @Produce json
+// @Param id path uint true "API配置ID"
+// @Param company_id query uint true "公司ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-config/{id}/sync-state [get]
+func (c *APIConfigController) GetSyncState(ctx *gin.Context) {
+	id, companyID, ok := syncStateParams(ctx)
+	if !ok {
+		return
+	}
+
+	state, err := c.syncStateService.Get(companyID, id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取增量同步状态成功",
+		"data":    state,
+	})
+}
+
+// ResetSyncState 重置增量同步状态
+// @Summary 重置增量同步状态
+// @Description 清除水位和主键记录，下次增量同步从初始水位重新开始
+// @Tags API配置管理
+// @Produce json
+// @Param id path uint true "API配置ID"
+// @Param company_id query uint true "公司ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/api-config/{id}/sync-state [delete]
+func (c *APIConfigController) ResetSyncState(ctx *gin.Context) {
+	id, companyID, ok := syncStateParams(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.syncStateService.Reset(companyID, id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "重置增量同步状态成功",
+		"data":    nil,
+	})
+}
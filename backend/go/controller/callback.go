@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// CallbackController 钉钉事件订阅控制器
+type CallbackController struct {
+	callbackService *service.CallbackService
+}
+
+// NewCallbackController 创建钉钉事件订阅控制器
+func NewCallbackController() *CallbackController {
+	return &CallbackController{
+		callbackService: service.NewCallbackService(),
+	}
+}
+
+// Receive 接收钉钉推送的事件，成功时按钉钉要求直接返回加密的应答
+func (c *CallbackController) Receive(ctx *gin.Context) {
+	companyID, err := strconv.ParseUint(ctx.Param("company_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req struct {
+		Encrypt string `json:"encrypt" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	signature := ctx.Query("msg_signature")
+	if signature == "" {
+		signature = ctx.Query("signature")
+	}
+
+	reply, err := c.callbackService.Receive(uint(companyID), signature, ctx.Query("timestamp"), ctx.Query("nonce"), req.Encrypt)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, service.ErrCallbackNotConfigured):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrCallbackSignature):
+			status = http.StatusForbidden
+		}
+		ctx.JSON(status, gin.H{
+			"code":    status,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reply)
+}
+
+// ListEvents 获取回调事件
+func (c *CallbackController) ListEvents(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID := uint64(0)
+	if companyIDStr := ctx.Query("company_id"); companyIDStr != "" {
+		companyID, err = strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "公司ID参数错误",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	events, total, err := c.callbackService.ListEvents(uint(companyID), ctx.Query("event_type"), ctx.Query("status"), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取回调事件失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取回调事件成功",
+		"data": gin.H{
+			"list":      events,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// RetryEvent 重新处理回调事件
+func (c *CallbackController) RetryEvent(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "事件ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	event, err := c.callbackService.Retry(ctx.Request.Context(), uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "重新处理回调事件完成",
+		"data":    event,
+	})
+}
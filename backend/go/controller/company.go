@@ -4,6 +4,7 @@ import (
 	"github.com/ddoalistdownload/backend/model"
 	"github.com/ddoalistdownload/backend/service"
 	"github.com/gin-gonic/gin"
+	"errors"
 	"net/http"
 	"strconv"
 )
@@ -73,7 +74,16 @@ func (c *CompanyController) Create(ctx *gin.Context) {
 	}
 	
 	// 调用服务层创建集团公司
-	if err := c.companyService.Create(&company); err != nil {
+	err := c.companyService.Create(&company)
+	if errors.Is(err, service.ErrInvalidRateLimit) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "创建集团公司失败",
@@ -158,7 +168,16 @@ func (c *CompanyController) Update(ctx *gin.Context) {
 	company.ID = uint(id)
 	
 	// 调用服务层更新集团公司
-	if err := c.companyService.Update(&company); err != nil {
+	err = c.companyService.Update(&company)
+	if errors.Is(err, service.ErrInvalidRateLimit) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "更新集团公司失败",
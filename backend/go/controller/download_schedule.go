@@ -0,0 +1,412 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/ddoalistdownload/backend/util"
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadScheduleController 定时下载任务控制器
+type DownloadScheduleController struct {
+	downloadScheduleService *service.DownloadScheduleService
+}
+
+// NewDownloadScheduleController 创建定时下载任务控制器实例
+func NewDownloadScheduleController() *DownloadScheduleController {
+	return &DownloadScheduleController{
+		downloadScheduleService: service.NewDownloadScheduleService(),
+	}
+}
+
+// List 获取定时任务列表
+// @Summary 获取定时任务列表
+// @Description 分页获取定时任务列表，非管理员只能看到自己创建的定时任务
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param company_id query uint false "公司ID"
+// @Param name query string false "定时任务名称"
+// @Param status query int false "状态 1:启用 0:暂停"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/download-schedule [get]
+func (c *DownloadScheduleController) List(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID := uint(0)
+	if companyIDUint64, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32); err == nil {
+		companyID = uint(companyIDUint64)
+	}
+
+	status := -1
+	if statusInt, err := strconv.Atoi(ctx.Query("status")); err == nil {
+		status = statusInt
+	}
+
+	currentUserID, roleCodes, err := currentUserRoleCodes(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取角色信息失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	schedules, total, err := c.downloadScheduleService.List(page, pageSize, companyID, ctx.Query("name"), status, model.User{ID: currentUserID}, roleCodes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取定时任务列表成功",
+		"data": gin.H{
+			"list":      schedules,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// Get 获取定时任务详情
+// @Summary 获取定时任务详情
+// @Description 根据ID获取定时任务详情
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Success 200 {object} model.DownloadSchedule
+// @Router /api/v1/download-schedule/{id} [get]
+func (c *DownloadScheduleController) Get(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取定时任务详情成功",
+		"data":    schedule,
+	})
+}
+
+// Create 创建定时任务
+// @Summary 创建定时任务
+// @Description 创建定时任务，参数模板支持 ${yesterday_start_ms}、${today_start_ms} 等相对日期占位符
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param download_schedule body model.DownloadSchedule true "定时任务信息"
+// @Success 200 {object} model.DownloadSchedule
+// @Router /api/v1/download-schedule [post]
+func (c *DownloadScheduleController) Create(ctx *gin.Context) {
+	var schedule model.DownloadSchedule
+	if err := ctx.ShouldBindJSON(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 定时生成的下载任务归属创建人
+	currentUserID, _, _ := currentUserRoleCodes(ctx)
+	schedule.UserID = currentUserID
+
+	if err := c.downloadScheduleService.Create(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "创建定时任务成功",
+		"data":    schedule,
+	})
+}
+
+// Update 更新定时任务
+// @Summary 更新定时任务
+// @Description 更新定时任务配置，修改后立即按新的 Cron 表达式调度
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Param download_schedule body model.DownloadSchedule true "定时任务信息"
+// @Success 200 {object} model.DownloadSchedule
+// @Router /api/v1/download-schedule/{id} [put]
+func (c *DownloadScheduleController) Update(ctx *gin.Context) {
+	existing, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	var schedule model.DownloadSchedule
+	if err := ctx.ShouldBindJSON(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+	schedule.ID = existing.ID
+
+	if err := c.downloadScheduleService.Update(&schedule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新定时任务成功",
+		"data":    schedule,
+	})
+}
+
+// Delete 删除定时任务
+// @Summary 删除定时任务
+// @Description 根据ID删除定时任务，已生成的下载任务保留
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/download-schedule/{id} [delete]
+func (c *DownloadScheduleController) Delete(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.downloadScheduleService.Delete(schedule.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除定时任务成功",
+		"data":    nil,
+	})
+}
+
+// Pause 暂停定时任务
+// @Summary 暂停定时任务
+// @Description 暂停后不再按 Cron 表达式生成下载任务
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Success 200 {object} model.DownloadSchedule
+// @Router /api/v1/download-schedule/{id}/pause [post]
+func (c *DownloadScheduleController) Pause(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	schedule, err := c.downloadScheduleService.Pause(schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "暂停定时任务成功",
+		"data":    schedule,
+	})
+}
+
+// Resume 恢复定时任务
+// @Summary 恢复定时任务
+// @Description 恢复按 Cron 表达式生成下载任务
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Success 200 {object} model.DownloadSchedule
+// @Router /api/v1/download-schedule/{id}/resume [post]
+func (c *DownloadScheduleController) Resume(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	schedule, err := c.downloadScheduleService.Resume(schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "恢复定时任务成功",
+		"data":    schedule,
+	})
+}
+
+// RunNow 立即执行定时任务
+// @Summary 立即执行定时任务
+// @Description 按当前时间计算参数模板并立即创建一个下载任务
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Success 200 {object} model.DownloadScheduleRun
+// @Router /api/v1/download-schedule/{id}/run [post]
+func (c *DownloadScheduleController) RunNow(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	run, err := c.downloadScheduleService.RunNow(schedule.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    run,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "执行定时任务成功",
+		"data":    run,
+	})
+}
+
+// Runs 获取定时任务执行记录
+// @Summary 获取定时任务执行记录
+// @Description 分页获取定时任务的执行历史及生成的下载任务
+// @Tags 定时任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "定时任务ID"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/download-schedule/{id}/runs [get]
+func (c *DownloadScheduleController) Runs(ctx *gin.Context) {
+	schedule, ok := c.ownedSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	runs, total, err := c.downloadScheduleService.Runs(schedule.ID, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取定时任务执行记录成功",
+		"data": gin.H{
+			"list":      runs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ownedSchedule 获取路径参数中的定时任务并校验权限，非管理员只能操作自己创建的定时任务
+// 校验失败时已写入响应
+func (c *DownloadScheduleController) ownedSchedule(ctx *gin.Context) (*model.DownloadSchedule, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "ID参数错误",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	schedule, err := c.downloadScheduleService.Get(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	currentUserID, roleCodes, err := currentUserRoleCodes(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取角色信息失败",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	if schedule.UserID != currentUserID && !util.ContainsString(roleCodes, "admin") {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "没有权限操作该定时任务",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	return schedule, true
+}
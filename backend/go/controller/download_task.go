@@ -1,12 +1,15 @@
 package controller
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ddoalistdownload/backend/database"
 	"github.com/ddoalistdownload/backend/model"
 	"github.com/ddoalistdownload/backend/service"
+	"github.com/ddoalistdownload/backend/util"
 	"github.com/gin-gonic/gin"
 )
 
@@ -74,14 +77,8 @@ func (c *DownloadTaskController) List(ctx *gin.Context) {
 	}
 
 	// 获取当前用户信息
-	userIDAny, _ := ctx.Get("userID")
-	currentUserID := userIDAny.(uint)
-	roleIDsAny, _ := ctx.Get("roleIDs")
-	roleIDList := roleIDsAny.([]uint)
-
-	// 获取角色代码列表
-	var roleCodes []string
-	if err := database.GetDB().Model(&model.Role{}).Where("id IN ?", roleIDList).Pluck("code", &roleCodes).Error; err != nil {
+	currentUserID, roleCodes, err := currentUserRoleCodes(ctx)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "获取角色信息失败",
@@ -176,6 +173,10 @@ func (c *DownloadTaskController) Create(ctx *gin.Context) {
 		return
 	}
 
+	// 任务归属当前用户，导出时按其字段权限脱敏，不信任请求中的 user_id
+	currentUserID, _, _ := currentUserRoleCodes(ctx)
+	downloadTask.UserID = currentUserID
+
 	// 调用服务层创建
 	if err := c.downloadTaskService.Create(&downloadTask); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -311,3 +312,298 @@ func (c *DownloadTaskController) GetTaskByUserID(ctx *gin.Context) {
 		"data":    tasks,
 	})
 }
+
+// Download 下载任务导出文件
+// @Summary 下载任务导出文件
+// @Description 重定向到任务导出文件的限时签名下载地址
+// @Tags 下载任务管理
+// @Produce json
+// @Param id path uint true "下载任务ID"
+// @Success 302 {string} string "签名下载地址"
+// @Router /api/v1/download-task/{id}/file [get]
+func (c *DownloadTaskController) Download(ctx *gin.Context) {
+	downloadTask, _, ok := c.ownedTask(ctx)
+	if !ok {
+		return
+	}
+
+	url, err := c.downloadTaskService.GetDownloadURL(ctx.Request.Context(), downloadTask)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, url)
+}
+
+// Cancel 取消下载任务
+// @Summary 取消下载任务
+// @Description 取消等待中或执行中的任务，执行中的请求会被立即中断
+// @Tags 下载任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "下载任务ID"
+// @Success 200 {object} model.DownloadTask
+// @Router /api/v1/download-task/{id}/cancel [post]
+func (c *DownloadTaskController) Cancel(ctx *gin.Context) {
+	downloadTask, _, ok := c.ownedTask(ctx)
+	if !ok {
+		return
+	}
+
+	downloadTask, err := c.downloadTaskService.Cancel(downloadTask.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "取消下载任务成功",
+		"data":    downloadTask,
+	})
+}
+
+// Retry 重试下载任务
+// @Summary 重试下载任务
+// @Description 重试失败或已取消的任务，从上次完成的页继续请求
+// @Tags 下载任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "下载任务ID"
+// @Success 200 {object} model.DownloadTask
+// @Router /api/v1/download-task/{id}/retry [post]
+func (c *DownloadTaskController) Retry(ctx *gin.Context) {
+	downloadTask, _, ok := c.ownedTask(ctx)
+	if !ok {
+		return
+	}
+
+	downloadTask, err := c.downloadTaskService.Retry(downloadTask.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "重试下载任务成功",
+		"data":    downloadTask,
+	})
+}
+
+// Rerun 重新运行下载任务
+// @Summary 重新运行下载任务
+// @Description 以相同参数创建新任务并执行
+// @Tags 下载任务管理
+// @Accept json
+// @Produce json
+// @Param id path uint true "下载任务ID"
+// @Success 200 {object} model.DownloadTask
+// @Router /api/v1/download-task/{id}/rerun [post]
+func (c *DownloadTaskController) Rerun(ctx *gin.Context) {
+	downloadTask, currentUserID, ok := c.ownedTask(ctx)
+	if !ok {
+		return
+	}
+
+	newTask, err := c.downloadTaskService.Rerun(downloadTask.ID, currentUserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "重新运行下载任务成功",
+		"data":    newTask,
+	})
+}
+
+// Events 推送单个任务的状态变更
+// @Summary 订阅下载任务进度
+// @Description 以 SSE 推送任务状态、进度、已获取页数和记录数，任务结束后关闭连接
+// @Tags 下载任务管理
+// @Produce text/event-stream
+// @Param id path uint true "下载任务ID"
+// @Success 200 {object} service.DownloadTaskEvent
+// @Router /api/v1/download-task/{id}/events [get]
+func (c *DownloadTaskController) Events(ctx *gin.Context) {
+	downloadTask, _, ok := c.ownedTask(ctx)
+	if !ok {
+		return
+	}
+
+	taskID := downloadTask.ID
+	events, unsubscribe := service.GetDownloadEventHub().Subscribe(func(event *service.DownloadTaskEvent) bool {
+		return event.TaskID == taskID
+	})
+	defer unsubscribe()
+
+	// 订阅后重新读取一次，避免漏掉订阅前发生的变更
+	snapshot := func() *service.DownloadTaskEvent {
+		task, err := c.downloadTaskService.Get(taskID)
+		if err != nil {
+			return nil
+		}
+		event := service.NewDownloadTaskEvent(task)
+		return &event
+	}
+
+	startEventStream(ctx)
+	current := snapshot()
+	if current == nil {
+		return
+	}
+	ctx.SSEvent("task", current)
+	if current.Finished() {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case event := <-events:
+			ctx.SSEvent("task", event)
+			return !event.Finished()
+		case <-heartbeat.C:
+			// 事件可能因订阅者缓冲写满被丢弃，心跳时兜底检查任务是否已结束
+			if current := snapshot(); current != nil && current.Finished() {
+				ctx.SSEvent("task", current)
+				return false
+			}
+			ctx.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
+
+// UserEvents 推送当前用户所有任务的状态变更
+// @Summary 订阅当前用户的下载任务进度
+// @Description 以 SSE 推送当前用户所有任务的状态变更，连接保持到客户端断开
+// @Tags 下载任务管理
+// @Produce text/event-stream
+// @Success 200 {object} service.DownloadTaskEvent
+// @Router /api/v1/download-task/events [get]
+func (c *DownloadTaskController) UserEvents(ctx *gin.Context) {
+	currentUserID, _, _ := currentUserRoleCodes(ctx)
+	events, unsubscribe := service.GetDownloadEventHub().Subscribe(func(event *service.DownloadTaskEvent) bool {
+		return event.UserID == currentUserID
+	})
+	defer unsubscribe()
+
+	startEventStream(ctx)
+	ctx.SSEvent("ping", time.Now().Unix())
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case event := <-events:
+			ctx.SSEvent("task", event)
+			return true
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
+
+// eventHeartbeatInterval SSE 心跳间隔，防止代理因连接空闲而断开
+const eventHeartbeatInterval = 15 * time.Second
+
+// startEventStream 设置 SSE 响应头并立即发送
+func startEventStream(ctx *gin.Context) {
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+}
+
+// ownedTask 获取路径参数中的任务并校验权限，非管理员只能操作自己的任务
+// 校验失败时已写入响应
+func (c *DownloadTaskController) ownedTask(ctx *gin.Context) (*model.DownloadTask, uint, bool) {
+	// 获取ID参数
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "ID参数错误",
+			"data":    nil,
+		})
+		return nil, 0, false
+	}
+
+	downloadTask, err := c.downloadTaskService.Get(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return nil, 0, false
+	}
+
+	currentUserID, roleCodes, err := currentUserRoleCodes(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取角色信息失败",
+			"data":    nil,
+		})
+		return nil, 0, false
+	}
+	if downloadTask.UserID != currentUserID && !util.ContainsString(roleCodes, "admin") {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "没有权限操作该任务",
+			"data":    nil,
+		})
+		return nil, 0, false
+	}
+
+	return downloadTask, currentUserID, true
+}
+
+// currentUserRoleCodes 获取当前登录用户的ID和角色代码列表
+func currentUserRoleCodes(ctx *gin.Context) (uint, []string, error) {
+	userIDAny, _ := ctx.Get("userID")
+	currentUserID, _ := userIDAny.(uint)
+	roleIDsAny, _ := ctx.Get("roleIDs")
+	roleIDList, _ := roleIDsAny.([]uint)
+
+	var roleCodes []string
+	if len(roleIDList) == 0 {
+		return currentUserID, roleCodes, nil
+	}
+	if err := database.GetDB().Model(&model.Role{}).Where("id IN ?", roleIDList).Pluck("code", &roleCodes).Error; err != nil {
+		return currentUserID, nil, err
+	}
+	return currentUserID, roleCodes, nil
+}
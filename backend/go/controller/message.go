@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MessageController 消息通知控制器
+type MessageController struct {
+	messageService *service.MessageService
+}
+
+// NewMessageController 创建消息通知控制器
+func NewMessageController() *MessageController {
+	return &MessageController{
+		messageService: service.NewMessageService(),
+	}
+}
+
+// parseCompanyID 解析必填的 company_id 查询参数，错误时写入响应并返回 false
+func parseCompanyID(ctx *gin.Context) (uint, bool) {
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return 0, false
+	}
+	return uint(companyID), true
+}
+
+// parseIDParam 解析路径中的 id 参数，错误时写入响应并返回 false
+func parseIDParam(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "ID参数错误",
+			"data":    nil,
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ListTemplates 获取公司的消息模板
+func (c *MessageController) ListTemplates(ctx *gin.Context) {
+	companyID, ok := parseCompanyID(ctx)
+	if !ok {
+		return
+	}
+
+	templates, err := c.messageService.ListTemplates(companyID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取消息模板失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取消息模板成功",
+		"data":    templates,
+	})
+}
+
+// SaveTemplate 保存消息模板
+func (c *MessageController) SaveTemplate(ctx *gin.Context) {
+	var tmpl model.MessageTemplate
+	if err := ctx.ShouldBindJSON(&tmpl); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := c.messageService.SaveTemplate(&tmpl); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "保存消息模板成功",
+		"data":    tmpl,
+	})
+}
+
+// DeleteTemplate 删除消息模板
+func (c *MessageController) DeleteTemplate(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.messageService.DeleteTemplate(id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除消息模板失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除消息模板成功",
+		"data":    nil,
+	})
+}
+
+// ListRobots 获取公司的机器人
+func (c *MessageController) ListRobots(ctx *gin.Context) {
+	companyID, ok := parseCompanyID(ctx)
+	if !ok {
+		return
+	}
+
+	robots, err := c.messageService.ListRobots(companyID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取机器人失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取机器人成功",
+		"data":    robots,
+	})
+}
+
+// CreateRobot 创建机器人
+func (c *MessageController) CreateRobot(ctx *gin.Context) {
+	var robot model.MessageRobot
+	if err := ctx.ShouldBindJSON(&robot); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := c.messageService.CreateRobot(&robot); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "创建机器人成功",
+		"data":    robot,
+	})
+}
+
+// UpdateRobot 更新机器人
+func (c *MessageController) UpdateRobot(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	var robot model.MessageRobot
+	if err := ctx.ShouldBindJSON(&robot); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+	robot.ID = id
+
+	if err := c.messageService.UpdateRobot(&robot); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新机器人成功",
+		"data":    robot,
+	})
+}
+
+// DeleteRobot 删除机器人
+func (c *MessageController) DeleteRobot(ctx *gin.Context) {
+	id, ok := parseIDParam(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.messageService.DeleteRobot(id); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除机器人成功",
+		"data":    nil,
+	})
+}
+
+// ListLogs 获取消息发送记录
+func (c *MessageController) ListLogs(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID := uint64(0)
+	if companyIDStr := ctx.Query("company_id"); companyIDStr != "" {
+		companyID, err = strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "公司ID参数错误",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	logs, total, err := c.messageService.ListLogs(uint(companyID), ctx.Query("event"), ctx.Query("channel"), ctx.Query("status"), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取消息发送记录失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取消息发送记录成功",
+		"data": gin.H{
+			"list":      logs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// Send 直接发送一条消息，用于测试机器人和工作通知配置
+func (c *MessageController) Send(ctx *gin.Context) {
+	var message service.OutgoingMessage
+	if err := ctx.ShouldBindJSON(&message); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	logs, err := c.messageService.Send(ctx.Request.Context(), &message)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    logs,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "发送消息成功",
+		"data":    logs,
+	})
+}
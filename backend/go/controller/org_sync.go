@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OrgSyncController 组织架构同步控制器
+type OrgSyncController struct {
+	orgSyncService *service.OrgSyncService
+}
+
+// NewOrgSyncController 创建组织架构同步控制器
+func NewOrgSyncController() *OrgSyncController {
+	return &OrgSyncController{
+		orgSyncService: service.NewOrgSyncService(),
+	}
+}
+
+// Sync 立即同步公司的组织架构，返回本次同步的变更明细
+func (c *OrgSyncController) Sync(ctx *gin.Context) {
+	var req struct {
+		CompanyID uint `json:"company_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	run, err := c.orgSyncService.Sync(ctx.Request.Context(), req.CompanyID, model.OrgSyncTriggerManual)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    run,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "同步组织架构成功",
+		"data":    run,
+	})
+}
+
+// ListRuns 获取组织架构同步记录
+func (c *OrgSyncController) ListRuns(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID := uint64(0)
+	if companyIDStr := ctx.Query("company_id"); companyIDStr != "" {
+		companyID, err = strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "公司ID参数错误",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	runs, total, err := c.orgSyncService.ListRuns(uint(companyID), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取组织架构同步记录失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取组织架构同步记录成功",
+		"data": gin.H{
+			"list":      runs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ListDepartments 获取公司的部门列表
+func (c *OrgSyncController) ListDepartments(ctx *gin.Context) {
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	departments, err := c.orgSyncService.ListDepartments(uint(companyID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取部门列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取部门列表成功",
+		"data":    departments,
+	})
+}
+
+// ListUsers 获取公司的钉钉成员列表
+func (c *OrgSyncController) ListUsers(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	deptID := int64(0)
+	if deptIDStr := ctx.Query("dept_id"); deptIDStr != "" {
+		deptID, err = strconv.ParseInt(deptIDStr, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "部门ID参数错误",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	users, total, err := c.orgSyncService.ListUsers(uint(companyID), deptID, ctx.Query("keyword"), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取钉钉成员列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取钉钉成员列表成功",
+		"data": gin.H{
+			"list":      users,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
@@ -1,22 +1,28 @@
 package controller
 
 import (
+	"errors"
+	"github.com/ddoalistdownload/backend/database"
 	"github.com/ddoalistdownload/backend/model"
 	"github.com/ddoalistdownload/backend/service"
+	"github.com/ddoalistdownload/backend/util"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // SSOController 身份验证（免登）控制器
 type SSOController struct {
-	ssoService *service.SSOService
+	ssoService         *service.SSOService
+	jsapiTicketService *service.JSAPITicketService
 }
 
 // NewSSOController 创建身份验证（免登）控制器
 func NewSSOController() *SSOController {
 	return &SSOController{
-		ssoService: service.NewSSOService(),
+		ssoService:         service.NewSSOService(),
+		jsapiTicketService: service.NewJSAPITicketService(),
 	}
 }
 
@@ -125,3 +131,267 @@ func (c *SSOController) TestSSO(ctx *gin.Context) {
 		"data":    result,
 	})
 }
+
+// JSAPISignature 获取H5微应用调用 dd.config 所需的签名
+func (c *SSOController) JSAPISignature(ctx *gin.Context) {
+	// 绑定请求参数
+	var req struct {
+		CompanyID uint   `json:"company_id" binding:"required"`
+		URL       string `json:"url" binding:"required"`
+	}
+	
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+	
+	// 只能获取本公司的签名，免登配置管理员可以获取其他公司的签名
+	currentUserID, roleCodes, err := currentUserRoleCodes(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询角色信息失败",
+			"data":    nil,
+		})
+		return
+	}
+	if !util.ContainsString(roleCodes, "admin") && !util.ContainsString(roleCodes, "sso:manage") {
+		var user model.User
+		if err := database.GetDB().Select("id", "company_id").First(&user, currentUserID).Error; err != nil || user.CompanyID != req.CompanyID {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "没有权限获取该公司的签名",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	// 调用服务层生成签名
+	signature, err := c.jsapiTicketService.Sign(ctx.Request.Context(), req.CompanyID, req.URL)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取签名成功",
+		"data":    signature,
+	})
+}
+
+// ListBindings 获取钉钉账号绑定列表
+func (c *SSOController) ListBindings(ctx *gin.Context) {
+	companyID, err := strconv.ParseUint(ctx.Query("company_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "公司ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	bindings, err := c.ssoService.ListBindings(uint(companyID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取钉钉账号绑定列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取钉钉账号绑定列表成功",
+		"data":    bindings,
+	})
+}
+
+// CreateBinding 绑定钉钉账号与系统用户
+func (c *SSOController) CreateBinding(ctx *gin.Context) {
+	var binding model.SSOBinding
+	if err := ctx.ShouldBindJSON(&binding); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := c.ssoService.CreateBinding(&binding); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "绑定钉钉账号成功",
+		"data":    binding,
+	})
+}
+
+// DeleteBinding 解除钉钉账号绑定
+func (c *SSOController) DeleteBinding(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "ID参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := c.ssoService.DeleteBinding(uint(id)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "解除钉钉账号绑定成功",
+		"data":    nil,
+	})
+}
+
+// parseSSOLogFilter 解析免登记录的查询条件，时间支持 2006-01-02 15:04:05、2006-01-02 和 RFC3339 格式
+func parseSSOLogFilter(ctx *gin.Context) (service.SSOLogFilter, error) {
+	filter := service.SSOLogFilter{
+		Action:         ctx.Query("action"),
+		Status:         ctx.Query("status"),
+		DingTalkUserID: ctx.Query("dingtalk_userid"),
+	}
+
+	if companyIDStr := ctx.Query("company_id"); companyIDStr != "" {
+		companyID, err := strconv.ParseUint(companyIDStr, 10, 32)
+		if err != nil {
+			return filter, errors.New("公司ID参数错误")
+		}
+		filter.CompanyID = uint(companyID)
+	}
+	if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			return filter, errors.New("用户ID参数错误")
+		}
+		filter.UserID = uint(userID)
+	}
+
+	for _, item := range []struct {
+		name   string
+		target **time.Time
+	}{{"start_time", &filter.StartTime}, {"end_time", &filter.EndTime}} {
+		value := ctx.Query(item.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseQueryTime(value)
+		if err != nil {
+			return filter, errors.New("时间参数格式错误: " + item.name)
+		}
+		*item.target = &t
+	}
+	return filter, nil
+}
+
+// parseQueryTime 解析查询参数中的时间
+func parseQueryTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ListLogs 获取免登记录
+func (c *SSOController) ListLogs(ctx *gin.Context) {
+	page, err := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	filter, err := parseSSOLogFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	logs, total, err := c.ssoService.ListLogs(filter, page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取免登记录失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取免登记录成功",
+		"data": gin.H{
+			"list":      logs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// Stats 按公司统计免登成功率
+func (c *SSOController) Stats(ctx *gin.Context) {
+	filter, err := parseSSOLogFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	stats, err := c.ssoService.Stats(filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "统计免登记录失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "统计免登记录成功",
+		"data":    stats,
+	})
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ddoalistdownload/backend/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// StorageController 文件存储控制器
+type StorageController struct{}
+
+// NewStorageController 创建文件存储控制器实例
+func NewStorageController() *StorageController {
+	return &StorageController{}
+}
+
+// File 通过签名地址下载本地存储的文件
+// @Summary 下载文件
+// @Description 校验签名和有效期后返回本地存储的文件，无需登录
+// @Tags 文件存储
+// @Produce octet-stream
+// @Param key query string true "文件路径"
+// @Param expires query int true "过期时间戳"
+// @Param filename query string false "下载文件名"
+// @Param signature query string true "签名"
+// @Success 200 {file} file
+// @Router /api/v1/storage/file [get]
+func (c *StorageController) File(ctx *gin.Context) {
+	localStorage, ok := storage.Get().(*storage.LocalStorage)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "当前存储不支持直接下载",
+			"data":    nil,
+		})
+		return
+	}
+
+	key := ctx.Query("key")
+	fileName := ctx.Query("filename")
+	path, err := localStorage.Verify(key, fileName, ctx.Query("expires"), ctx.Query("signature"))
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	if fileName == "" {
+		ctx.File(path)
+		return
+	}
+	ctx.FileAttachment(path, fileName)
+}
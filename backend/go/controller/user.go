@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -13,12 +14,14 @@ import (
 // UserController 用户控制器
 type UserController struct {
 	userService *service.UserService
+	ssoService  *service.SSOService
 }
 
 // NewUserController 创建用户控制器实例
 func NewUserController() *UserController {
 	return &UserController{
 		userService: service.NewUserService(),
+		ssoService:  service.NewSSOService(),
 	}
 }
 
@@ -554,3 +557,55 @@ func (c *UserController) Login(ctx *gin.Context) {
 		},
 	})
 }
+
+// SSOLogin 钉钉免登登录，签发与账号密码登录相同的令牌
+func (c *UserController) SSOLogin(ctx *gin.Context) {
+	// 绑定请求参数
+	var req struct {
+		CompanyID uint   `json:"company_id" binding:"required"`
+		Code      string `json:"code" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 调用服务层免登
+	user, roleIDs, err := c.ssoService.Login(req.CompanyID, req.Code, ctx.ClientIP())
+	if err != nil {
+		status, code := http.StatusInternalServerError, 500
+		if errors.Is(err, service.ErrSSOUnbound) {
+			status, code = http.StatusForbidden, 403
+		}
+		ctx.JSON(status, gin.H{
+			"code":    code,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	// 生成JWT令牌
+	token, err := middleware.GenerateToken(user.ID, user.Username, roleIDs)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "生成令牌失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "登录成功",
+		"data": gin.H{
+			"user":  user,
+			"token": token,
+		},
+	})
+}
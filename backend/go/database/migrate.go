@@ -18,13 +18,27 @@ func MigrateDB() error {
 		&model.Menu{},
 		&model.RoleMenu{},
 		&model.SSOConfig{},
+		&model.SSOBinding{},
+		&model.SSOLoginLog{},
+		&model.Department{},
+		&model.DingTalkUser{},
+		&model.OrgSyncRun{},
+		&model.CallbackEvent{},
+		&model.MessageTemplate{},
+		&model.MessageRobot{},
+		&model.MessageLog{},
 		&model.AccessToken{},
+		&model.AccessTokenRefreshLog{},
 		&model.APIConfig{},
 		&model.Log{},
 		&model.FieldPermission{},
 		&model.DataDictionary{},
 		&model.DownloadTask{},
 		&model.DownloadResult{},
+		&model.DownloadSchedule{},
+		&model.DownloadScheduleRun{},
+		&model.SyncWatermark{},
+		&model.SyncRecord{},
 		&model.APITestCase{},
 		&model.APITestHistory{},
 	)
@@ -34,6 +48,14 @@ func MigrateDB() error {
 		return err
 	}
 
+	// AccessToken 改为按公司和令牌类型唯一，删除旧的公司唯一索引
+	if DB.Migrator().HasIndex(&model.AccessToken{}, "idx_access_token_company_id") {
+		if err := DB.Migrator().DropIndex(&model.AccessToken{}, "idx_access_token_company_id"); err != nil {
+			logrus.Errorf("删除AccessToken旧索引失败: %v", err)
+			return err
+		}
+	}
+
 	logrus.Info("数据库迁移完成")
 	return nil
 }
@@ -49,6 +49,23 @@ func GetRedis() *redis.Client {
 	return RedisClient
 }
 
+// TryLock 尝试获取分布式锁，锁在 ttl 后自动释放
+// 未连接 Redis 时视为单实例部署，直接返回成功
+func TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if RedisClient == nil {
+		return true, nil
+	}
+	return RedisClient.SetNX(ctx, key, time.Now().Unix(), ttl).Result()
+}
+
+// Unlock 提前释放 TryLock 获取的锁
+func Unlock(ctx context.Context, key string) error {
+	if RedisClient == nil {
+		return nil
+	}
+	return RedisClient.Del(ctx, key).Err()
+}
+
 // CloseRedis 关闭Redis连接
 func CloseRedis() error {
 	if RedisClient != nil {
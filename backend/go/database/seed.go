@@ -104,6 +104,7 @@ func SeedData() error {
 			Name: "业务功能", Path: "/business", Component: "BasicLayout", Icon: "component", Sort: 2, Type: 1,
 			Children: []MenuNode{
 				{Name: "下载任务", Path: "/business/download-task", Component: "/business/download-task/index", Icon: "download", Sort: 1, Type: 1},
+				{Name: "定时任务", Path: "/business/download-schedule", Component: "/business/download-schedule/index", Icon: "time", Sort: 2, Type: 1},
 			},
 		},
 		{
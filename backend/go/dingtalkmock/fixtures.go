@@ -0,0 +1,147 @@
+package dingtalkmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// App 可以换取 AccessToken 的应用
+type App struct {
+	AppKey    string `json:"app_key"`
+	AppSecret string `json:"app_secret"`
+}
+
+// User 通讯录用户
+type User struct {
+	UserID     string  `json:"userid"`
+	UnionID    string  `json:"unionid"`
+	Name       string  `json:"name"`
+	Avatar     string  `json:"avatar"`
+	Mobile     string  `json:"mobile"`
+	Email      string  `json:"email"`
+	JobNumber  string  `json:"job_number"`
+	Title      string  `json:"title"`
+	DeptIDList []int64 `json:"dept_id_list"`
+	Active     bool    `json:"active"`
+}
+
+// Department 部门
+type Department struct {
+	DeptID   int64  `json:"dept_id"`
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+}
+
+// FormComponentValue 审批表单控件的值
+type FormComponentValue struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Value         string `json:"value"`
+	ExtValue      string `json:"ext_value,omitempty"`
+	ComponentType string `json:"component_type"`
+}
+
+// ApprovalInstance 审批实例
+type ApprovalInstance struct {
+	ProcessInstanceID   string               `json:"process_instance_id"`
+	ProcessCode         string               `json:"process_code"`
+	Title               string               `json:"title"`
+	Status              string               `json:"status"` // NEW, RUNNING, TERMINATED, COMPLETED, CANCELED
+	Result              string               `json:"result"` // agree, refuse
+	BusinessID          string               `json:"business_id"`
+	OriginatorUserID    string               `json:"originator_userid"`
+	OriginatorDeptID    string               `json:"originator_dept_id"`
+	CreateTime          int64                `json:"create_time"` // 毫秒时间戳
+	FinishTime          int64                `json:"finish_time"` // 毫秒时间戳，未结束时为 0
+	FormComponentValues []FormComponentValue `json:"form_component_values"`
+}
+
+// Robot 群自定义机器人
+type Robot struct {
+	AccessToken string `json:"access_token"`
+	Secret      string `json:"secret"` // 加签密钥，为空时不校验签名
+}
+
+// Fixtures 模拟服务返回的数据
+type Fixtures struct {
+	Apps              []App              `json:"apps"`
+	Users             []User             `json:"users"`
+	Departments       []Department       `json:"departments"`
+	Codes             map[string]string  `json:"codes"` // 免登授权码 → userid
+	ApprovalInstances []ApprovalInstance `json:"approval_instances"`
+	Robots            []Robot            `json:"robots"`
+	TokenExpiresIn    int                `json:"token_expires_in"` // 签发的 AccessToken 有效期（秒），默认 7200
+}
+
+// DefaultFixtures 返回一组可以直接使用的示例数据
+func DefaultFixtures() Fixtures {
+	return Fixtures{
+		Apps: []App{{AppKey: "mock-app-key", AppSecret: "mock-app-secret"}},
+		Departments: []Department{
+			{DeptID: 1, Name: "模拟企业", ParentID: 0},
+			{DeptID: 2, Name: "研发部", ParentID: 1},
+			{DeptID: 3, Name: "后端组", ParentID: 2},
+			{DeptID: 4, Name: "财务部", ParentID: 1},
+		},
+		Users: []User{
+			{UserID: "manager01", UnionID: "union-manager01", Name: "张三", Mobile: "13800000001", Email: "zhangsan@example.com", JobNumber: "A001", Title: "经理", DeptIDList: []int64{1}, Active: true},
+			{UserID: "dev01", UnionID: "union-dev01", Name: "李四", Mobile: "13800000002", Email: "lisi@example.com", JobNumber: "A002", Title: "工程师", DeptIDList: []int64{3}, Active: true},
+			{UserID: "finance01", UnionID: "union-finance01", Name: "王五", Mobile: "13800000003", Email: "wangwu@example.com", JobNumber: "A003", Title: "会计", DeptIDList: []int64{4}, Active: true},
+		},
+		Codes: map[string]string{
+			"mock-code-manager": "manager01",
+			"mock-code-dev":     "dev01",
+		},
+		ApprovalInstances: []ApprovalInstance{
+			{
+				ProcessInstanceID: "proc-inst-001",
+				ProcessCode:       "PROC-LEAVE",
+				Title:             "李四提交的请假",
+				Status:            "COMPLETED",
+				Result:            "agree",
+				BusinessID:        "202601010001",
+				OriginatorUserID:  "dev01",
+				OriginatorDeptID:  "3",
+				CreateTime:        1767225600000,
+				FinishTime:        1767232800000,
+				FormComponentValues: []FormComponentValue{
+					{ID: "TextField-1", Name: "请假类型", Value: "年假", ComponentType: "TextField"},
+					{ID: "NumberField-1", Name: "天数", Value: "2", ComponentType: "NumberField"},
+				},
+			},
+			{
+				ProcessInstanceID: "proc-inst-002",
+				ProcessCode:       "PROC-LEAVE",
+				Title:             "王五提交的请假",
+				Status:            "RUNNING",
+				BusinessID:        "202601020001",
+				OriginatorUserID:  "finance01",
+				OriginatorDeptID:  "4",
+				CreateTime:        1767312000000,
+				FormComponentValues: []FormComponentValue{
+					{ID: "TextField-1", Name: "请假类型", Value: "事假", ComponentType: "TextField"},
+					{ID: "NumberField-1", Name: "天数", Value: "1", ComponentType: "NumberField"},
+				},
+			},
+		},
+		Robots: []Robot{
+			{AccessToken: "mock-robot", Secret: "mock-robot-secret"},
+		},
+		TokenExpiresIn: 7200,
+	}
+}
+
+// LoadFixtures 从 JSON 文件加载数据
+func LoadFixtures(path string) (Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixtures{}, err
+	}
+
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return Fixtures{}, fmt.Errorf("解析模拟数据失败: %v", err)
+	}
+	return fixtures, nil
+}
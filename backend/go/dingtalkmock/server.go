@@ -0,0 +1,779 @@
+// Package dingtalkmock 模拟钉钉开放平台接口，用于测试和离线环境
+//
+// 可以直接配合 httptest 使用：
+//
+//	mock := dingtalkmock.New(dingtalkmock.DefaultFixtures())
+//	server := httptest.NewServer(mock)
+//	defer server.Close()
+//
+// 也可以通过 cmd/dingtalk-mock 作为独立服务运行，并通过 /mock/ 下的管理接口修改数据和注入错误。
+package dingtalkmock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 模拟的钉钉接口路径，同时作为注入错误和统计调用次数的键
+const (
+	EndpointGetToken               = "/gettoken"
+	EndpointOAuth2AccessToken      = "/v1.0/oauth2/accessToken"
+	EndpointGetJSAPITicket         = "/get_jsapi_ticket"
+	EndpointGetUserInfo            = "/user/getuserinfo"
+	EndpointUserGet                = "/topapi/v2/user/get"
+	EndpointDepartmentListSub      = "/topapi/v2/department/listsub"
+	EndpointDepartmentGet          = "/topapi/v2/department/get"
+	EndpointUserList               = "/topapi/v2/user/list"
+	EndpointProcessInstanceListIDs = "/topapi/processinstance/listids"
+	EndpointProcessInstanceGet     = "/topapi/processinstance/get"
+	EndpointWorkNoticeSend         = "/topapi/message/corpconversation/asyncsend_v2"
+	EndpointRobotSend              = "/robot/send"
+)
+
+// 钉钉旧版接口的错误码
+const (
+	ErrcodeInvalidCredential   = 40089  // 不合法的 appkey 或 appsecret
+	ErrcodeInvalidAccessToken  = 40014  // 不合法的 access_token
+	ErrcodeAccessTokenExpired  = 42001  // access_token 超时
+	ErrcodeInvalidAuthCode     = 40078  // 不存在的临时授权码
+	ErrcodeUserNotFound        = 60121  // 找不到该用户
+	ErrcodeDepartmentNotFound  = 60003  // 部门不存在
+	ErrcodeInstanceNotFound    = 880002 // 审批实例不存在
+	ErrcodeInvalidParameter    = 40035  // 缺少参数或参数不合法
+	ErrcodeServiceUnavailable  = -1     // 系统繁忙
+	ErrcodeRequestLimitReached = 90018  // 调用频率超过限制
+	ErrcodeRobotNotFound       = 300001 // 机器人 access_token 不存在
+	ErrcodeRobotSignMismatch   = 310000 // 机器人加签校验失败
+)
+
+// processInstanceMaxSize listids 接口每页最多返回的数量
+const processInstanceMaxSize = 20
+
+// userListMaxSize user/list 接口每页最多返回的数量
+const userListMaxSize = 100
+
+// robotSignWindow 机器人加签时间戳的有效期
+const robotSignWindow = time.Hour
+
+// cst 审批实例时间使用的时区
+var cst = time.FixedZone("CST", 8*3600)
+
+// Fault 注入的错误
+// 旧版接口返回 errcode/errmsg，新版接口返回 HTTP 状态码和 code/message
+type Fault struct {
+	Errcode int    `json:"errcode"`  // 旧版接口返回的错误码
+	Errmsg  string `json:"errmsg"`   // 旧版接口返回的错误信息
+	Status  int    `json:"status"`   // HTTP 状态码，旧版接口默认 200，新版接口默认 400
+	Code    string `json:"code"`     // 新版接口返回的错误码
+	Message string `json:"message"`  // 新版接口返回的错误信息
+	DelayMS int    `json:"delay_ms"` // 返回前等待的毫秒数，可用于模拟超时
+	Times   int    `json:"times"`    // 生效次数，0 表示一直生效
+}
+
+// Message 发送成功的工作通知或机器人消息
+type Message struct {
+	Endpoint string                 `json:"endpoint"`
+	Target   string                 `json:"target"` // 工作通知为 userid_list，机器人为 access_token
+	Body     map[string]interface{} `json:"body"`
+}
+
+// Server 模拟的钉钉服务
+type Server struct {
+	mu       sync.Mutex
+	fixtures Fixtures
+	tokens   map[string]time.Time // 已签发的 AccessToken → 过期时间
+	faults   map[string]*Fault
+	calls    map[string]int
+	messages []Message
+	seq      int
+	mux      *http.ServeMux
+}
+
+// New 使用给定数据创建模拟服务
+func New(fixtures Fixtures) *Server {
+	s := &Server{
+		fixtures: fixtures,
+		tokens:   make(map[string]time.Time),
+		faults:   make(map[string]*Fault),
+		calls:    make(map[string]int),
+		mux:      http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc(EndpointGetToken, s.handleGetToken)
+	s.mux.HandleFunc(EndpointOAuth2AccessToken, s.handleOAuth2AccessToken)
+	s.mux.HandleFunc(EndpointGetJSAPITicket, s.handleGetJSAPITicket)
+	s.mux.HandleFunc(EndpointGetUserInfo, s.handleGetUserInfo)
+	s.mux.HandleFunc(EndpointUserGet, s.handleUserGet)
+	s.mux.HandleFunc(EndpointDepartmentListSub, s.handleDepartmentListSub)
+	s.mux.HandleFunc(EndpointDepartmentGet, s.handleDepartmentGet)
+	s.mux.HandleFunc(EndpointUserList, s.handleUserList)
+	s.mux.HandleFunc(EndpointProcessInstanceListIDs, s.handleProcessInstanceListIDs)
+	s.mux.HandleFunc(EndpointProcessInstanceGet, s.handleProcessInstanceGet)
+	s.mux.HandleFunc(EndpointWorkNoticeSend, s.handleWorkNoticeSend)
+	s.mux.HandleFunc(EndpointRobotSend, s.handleRobotSend)
+
+	s.mux.HandleFunc("/mock/fixtures", s.handleFixtures)
+	s.mux.HandleFunc("/mock/faults", s.handleFaults)
+	s.mux.HandleFunc("/mock/tokens/expire", s.handleExpireTokens)
+	s.mux.HandleFunc("/mock/calls", s.handleCalls)
+	s.mux.HandleFunc("/mock/messages", s.handleMessages)
+	return s
+}
+
+// ServeHTTP 记录调用次数，命中注入的错误时直接返回错误
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/mock/") {
+		if fault := s.takeFault(r.URL.Path); fault != nil {
+			writeFault(w, r.URL.Path, fault)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// SetFixtures 替换模拟数据，已签发的 AccessToken 保持有效
+func (s *Server) SetFixtures(fixtures Fixtures) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = fixtures
+}
+
+// InjectFault 为接口注入错误
+func (s *Server) InjectFault(endpoint string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = &fault
+}
+
+// ClearFaults 清除所有注入的错误
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string]*Fault)
+}
+
+// ExpireTokens 使已签发的 AccessToken 全部过期，用于模拟 42001
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.tokens {
+		s.tokens[token] = time.Time{}
+	}
+}
+
+// Calls 返回接口被调用的次数，包含返回注入错误的调用
+func (s *Server) Calls(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[endpoint]
+}
+
+// Messages 返回发送成功的消息
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// recordMessage 保存发送成功的消息
+func (s *Server) recordMessage(endpoint, target string, body map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, Message{Endpoint: endpoint, Target: target, Body: body})
+}
+
+// takeFault 记录调用并返回本次需要返回的错误
+func (s *Server) takeFault(endpoint string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[endpoint]++
+	fault, ok := s.faults[endpoint]
+	if !ok {
+		return nil
+	}
+	if fault.Times > 0 {
+		fault.Times--
+		if fault.Times == 0 {
+			delete(s.faults, endpoint)
+		}
+	}
+	copied := *fault
+	return &copied
+}
+
+// issueToken 签发新的 AccessToken
+func (s *Server) issueToken() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresIn := s.fixtures.TokenExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = 7200
+	}
+	s.seq++
+	token := fmt.Sprintf("mock-token-%d-%d", time.Now().UnixNano(), s.seq)
+	s.tokens[token] = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	return token, expiresIn
+}
+
+// checkToken 校验旧版接口的 access_token 参数，无效时写入错误响应
+func (s *Server) checkToken(w http.ResponseWriter, r *http.Request) bool {
+	token := r.URL.Query().Get("access_token")
+
+	s.mu.Lock()
+	expiresAt, ok := s.tokens[token]
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeError(w, ErrcodeInvalidAccessToken, "不合法的access_token")
+		return false
+	case !time.Now().Before(expiresAt):
+		writeError(w, ErrcodeAccessTokenExpired, "access_token超时")
+		return false
+	}
+	return true
+}
+
+// validApp 应用的 appkey 和 appsecret 是否匹配
+func (s *Server) validApp(appKey, appSecret string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, app := range s.fixtures.Apps {
+		if app.AppKey == appKey && app.AppSecret == appSecret {
+			return true
+		}
+	}
+	return false
+}
+
+// findUser 按 userid 查找用户
+func (s *Server) findUser(userID string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.fixtures.Users {
+		if user.UserID == userID {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *Server) handleGetToken(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if !s.validApp(query.Get("appkey"), query.Get("appsecret")) {
+		writeError(w, ErrcodeInvalidCredential, "不合法的appkey或appsecret")
+		return
+	}
+
+	token, expiresIn := s.issueToken()
+	writeOK(w, map[string]interface{}{
+		"access_token": token,
+		"expires_in":   expiresIn,
+	})
+}
+
+func (s *Server) handleOAuth2AccessToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppKey    string `json:"appKey"`
+		AppSecret string `json:"appSecret"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"code":    "MissingParameter",
+			"message": "缺少参数 appKey 或 appSecret",
+		})
+		return
+	}
+	if !s.validApp(req.AppKey, req.AppSecret) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"code":    "invalidClientIdOrSecret",
+			"message": "无效的clientId或clientSecret",
+		})
+		return
+	}
+
+	token, expiresIn := s.issueToken()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accessToken": token,
+		"expireIn":    expiresIn,
+	})
+}
+
+func (s *Server) handleGetJSAPITicket(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	s.seq++
+	ticket := fmt.Sprintf("mock-ticket-%d-%d", time.Now().UnixNano(), s.seq)
+	s.mu.Unlock()
+
+	writeOK(w, map[string]interface{}{
+		"ticket":     ticket,
+		"expires_in": 7200,
+	})
+}
+
+func (s *Server) handleGetUserInfo(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	userID, ok := s.fixtures.Codes[r.URL.Query().Get("code")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, ErrcodeInvalidAuthCode, "不存在的临时授权码")
+		return
+	}
+	user, ok := s.findUser(userID)
+	if !ok {
+		writeError(w, ErrcodeUserNotFound, "找不到该用户")
+		return
+	}
+
+	writeOK(w, map[string]interface{}{
+		"userid":     user.UserID,
+		"unionid":    user.UnionID,
+		"name":       user.Name,
+		"avatar":     user.Avatar,
+		"mobile":     user.Mobile,
+		"email":      user.Email,
+		"jobnumber":  user.JobNumber,
+		"position":   user.Title,
+		"department": user.DeptIDList,
+		"sys_level":  0,
+		"is_sys":     false,
+	})
+}
+
+func (s *Server) handleUserGet(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		UserID string `json:"userid"`
+	}
+	decodeBody(r, &req)
+	if req.UserID == "" {
+		req.UserID = r.URL.Query().Get("userid")
+	}
+	user, ok := s.findUser(req.UserID)
+	if !ok {
+		writeError(w, ErrcodeUserNotFound, "找不到该用户")
+		return
+	}
+
+	writeOK(w, map[string]interface{}{"result": user})
+}
+
+func (s *Server) handleDepartmentListSub(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		DeptID int64 `json:"dept_id"`
+	}
+	decodeBody(r, &req)
+	if req.DeptID == 0 {
+		req.DeptID = 1
+	}
+
+	s.mu.Lock()
+	exists := false
+	children := make([]Department, 0)
+	for _, dept := range s.fixtures.Departments {
+		if dept.DeptID == req.DeptID {
+			exists = true
+		}
+		if dept.ParentID == req.DeptID && dept.DeptID != req.DeptID {
+			children = append(children, dept)
+		}
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		writeError(w, ErrcodeDepartmentNotFound, "部门不存在")
+		return
+	}
+	writeOK(w, map[string]interface{}{"result": children})
+}
+
+func (s *Server) handleDepartmentGet(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		DeptID int64 `json:"dept_id"`
+	}
+	decodeBody(r, &req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, dept := range s.fixtures.Departments {
+		if dept.DeptID == req.DeptID {
+			writeOK(w, map[string]interface{}{"result": dept})
+			return
+		}
+	}
+	writeError(w, ErrcodeDepartmentNotFound, "部门不存在")
+}
+
+func (s *Server) handleUserList(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		DeptID int64 `json:"dept_id"`
+		Cursor int   `json:"cursor"`
+		Size   int   `json:"size"`
+	}
+	decodeBody(r, &req)
+	if req.Size <= 0 || req.Size > userListMaxSize {
+		writeError(w, ErrcodeInvalidParameter, "size参数不合法")
+		return
+	}
+
+	s.mu.Lock()
+	exists := false
+	for _, dept := range s.fixtures.Departments {
+		if dept.DeptID == req.DeptID {
+			exists = true
+			break
+		}
+	}
+	members := make([]User, 0)
+	for _, user := range s.fixtures.Users {
+		for _, deptID := range user.DeptIDList {
+			if deptID == req.DeptID {
+				members = append(members, user)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		writeError(w, ErrcodeDepartmentNotFound, "部门不存在")
+		return
+	}
+
+	start := req.Cursor
+	if start > len(members) {
+		start = len(members)
+	}
+	end := start + req.Size
+	if end > len(members) {
+		end = len(members)
+	}
+	result := map[string]interface{}{
+		"has_more": end < len(members),
+		"list":     members[start:end],
+	}
+	if end < len(members) {
+		result["next_cursor"] = end
+	}
+	writeOK(w, map[string]interface{}{"result": result})
+}
+
+func (s *Server) handleProcessInstanceListIDs(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		ProcessCode string `json:"process_code"`
+		StartTime   int64  `json:"start_time"`
+		EndTime     int64  `json:"end_time"`
+		Size        int    `json:"size"`
+		Cursor      int    `json:"cursor"`
+		UserIDList  string `json:"userid_list"`
+	}
+	decodeBody(r, &req)
+	if req.ProcessCode == "" || req.StartTime == 0 {
+		writeError(w, ErrcodeInvalidParameter, "缺少参数 process_code 或 start_time")
+		return
+	}
+	if req.Size <= 0 || req.Size > processInstanceMaxSize {
+		req.Size = processInstanceMaxSize
+	}
+
+	userIDs := make(map[string]bool)
+	for _, userID := range strings.Split(req.UserIDList, ",") {
+		if userID = strings.TrimSpace(userID); userID != "" {
+			userIDs[userID] = true
+		}
+	}
+
+	s.mu.Lock()
+	var ids []string
+	for _, instance := range s.fixtures.ApprovalInstances {
+		if instance.ProcessCode != req.ProcessCode || instance.CreateTime < req.StartTime {
+			continue
+		}
+		if req.EndTime > 0 && instance.CreateTime > req.EndTime {
+			continue
+		}
+		if len(userIDs) > 0 && !userIDs[instance.OriginatorUserID] {
+			continue
+		}
+		ids = append(ids, instance.ProcessInstanceID)
+	}
+	s.mu.Unlock()
+
+	result := map[string]interface{}{"list": []string{}}
+	if req.Cursor < len(ids) {
+		end := req.Cursor + req.Size
+		if end < len(ids) {
+			result["next_cursor"] = end
+		} else {
+			end = len(ids)
+		}
+		result["list"] = ids[req.Cursor:end]
+	}
+	writeOK(w, map[string]interface{}{"result": result})
+}
+
+func (s *Server) handleProcessInstanceGet(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req struct {
+		ProcessInstanceID string `json:"process_instance_id"`
+	}
+	decodeBody(r, &req)
+
+	s.mu.Lock()
+	var found *ApprovalInstance
+	for i := range s.fixtures.ApprovalInstances {
+		if s.fixtures.ApprovalInstances[i].ProcessInstanceID == req.ProcessInstanceID {
+			instance := s.fixtures.ApprovalInstances[i]
+			found = &instance
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		writeError(w, ErrcodeInstanceNotFound, "审批实例不存在")
+		return
+	}
+
+	instance := map[string]interface{}{
+		"title":                 found.Title,
+		"status":                found.Status,
+		"result":                found.Result,
+		"business_id":           found.BusinessID,
+		"originator_userid":     found.OriginatorUserID,
+		"originator_dept_id":    found.OriginatorDeptID,
+		"create_time":           formatTime(found.CreateTime),
+		"form_component_values": found.FormComponentValues,
+	}
+	if found.FinishTime > 0 {
+		instance["finish_time"] = formatTime(found.FinishTime)
+	}
+	writeOK(w, map[string]interface{}{"process_instance": instance})
+}
+
+// handleFixtures GET 返回当前数据，PUT/POST 替换数据
+func (s *Server) handleFixtures(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		s.mu.Lock()
+		fixtures := s.fixtures
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, fixtures)
+		return
+	}
+
+	var fixtures Fixtures
+	if err := json.NewDecoder(r.Body).Decode(&fixtures); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	s.SetFixtures(fixtures)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+// handleFaults POST 注入错误，DELETE 清除所有错误
+func (s *Server) handleFaults(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		s.ClearFaults()
+		writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+		return
+	}
+
+	var req struct {
+		Endpoint string `json:"endpoint"`
+		Fault
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Endpoint == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "需要指定 endpoint"})
+		return
+	}
+	s.InjectFault(req.Endpoint, req.Fault)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+// handleExpireTokens 使已签发的 AccessToken 全部过期
+func (s *Server) handleExpireTokens(w http.ResponseWriter, r *http.Request) {
+	s.ExpireTokens()
+	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
+}
+
+func (s *Server) handleWorkNoticeSend(w http.ResponseWriter, r *http.Request) {
+	if !s.checkToken(w, r) {
+		return
+	}
+
+	var req map[string]interface{}
+	decodeBody(r, &req)
+	agentID, _ := req["agent_id"].(float64)
+	userIDList, _ := req["userid_list"].(string)
+	if agentID <= 0 || userIDList == "" || req["msg"] == nil {
+		writeError(w, ErrcodeInvalidParameter, "agent_id、userid_list和msg不能为空")
+		return
+	}
+
+	s.recordMessage(EndpointWorkNoticeSend, userIDList, req)
+	s.mu.Lock()
+	s.seq++
+	taskID := s.seq
+	s.mu.Unlock()
+	writeOK(w, map[string]interface{}{"task_id": taskID, "request_id": fmt.Sprintf("mock-request-%d", taskID)})
+}
+
+func (s *Server) handleRobotSend(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	token := query.Get("access_token")
+
+	s.mu.Lock()
+	var robot *Robot
+	for i := range s.fixtures.Robots {
+		if s.fixtures.Robots[i].AccessToken == token {
+			robot = &s.fixtures.Robots[i]
+			break
+		}
+	}
+	s.mu.Unlock()
+	if robot == nil {
+		writeError(w, ErrcodeRobotNotFound, "token is not exist")
+		return
+	}
+
+	if robot.Secret != "" {
+		timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+		if err != nil || time.Since(time.UnixMilli(timestamp)).Abs() > robotSignWindow || query.Get("sign") != RobotSign(robot.Secret, timestamp) {
+			writeError(w, ErrcodeRobotSignMismatch, "sign not match")
+			return
+		}
+	}
+
+	var req map[string]interface{}
+	decodeBody(r, &req)
+	if req["msgtype"] == nil {
+		writeError(w, ErrcodeInvalidParameter, "msgtype不能为空")
+		return
+	}
+	s.recordMessage(EndpointRobotSend, token, req)
+	writeOK(w, map[string]interface{}{})
+}
+
+// RobotSign 计算机器人加签：timestamp + "\n" + secret 以 secret 做 HmacSHA256 后 base64
+func RobotSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleMessages 返回发送成功的消息
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Messages())
+}
+
+// handleCalls 返回各接口的调用次数
+func (s *Server) handleCalls(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	calls := make(map[string]int, len(s.calls))
+	for endpoint, count := range s.calls {
+		calls[endpoint] = count
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, calls)
+}
+
+// writeFault 按接口版本写入注入的错误
+func writeFault(w http.ResponseWriter, endpoint string, fault *Fault) {
+	if fault.DelayMS > 0 {
+		time.Sleep(time.Duration(fault.DelayMS) * time.Millisecond)
+	}
+
+	if strings.HasPrefix(endpoint, "/v1.0/") {
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"code":    fault.Code,
+			"message": fault.Message,
+		})
+		return
+	}
+
+	status := fault.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"errcode": fault.Errcode,
+		"errmsg":  fault.Errmsg,
+	})
+}
+
+// writeOK 写入旧版接口的成功响应
+func writeOK(w http.ResponseWriter, data map[string]interface{}) {
+	data["errcode"] = 0
+	data["errmsg"] = "ok"
+	writeJSON(w, http.StatusOK, data)
+}
+
+// writeError 写入旧版接口的错误响应，旧版接口出错时 HTTP 状态码仍为 200
+func writeError(w http.ResponseWriter, errcode int, errmsg string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errcode": errcode,
+		"errmsg":  errmsg,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// decodeBody 解析 JSON 请求体，请求体为空或格式错误时保持零值
+func decodeBody(r *http.Request, v interface{}) {
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(v)
+	}
+}
+
+// formatTime 将毫秒时间戳格式化为审批接口使用的时间格式
+func formatTime(ms int64) string {
+	return time.UnixMilli(ms).In(cst).Format("2006-01-02 15:04:05")
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/ddoalistdownload/backend/controller"
 	"github.com/ddoalistdownload/backend/database"
 	"github.com/ddoalistdownload/backend/middleware"
+	"github.com/ddoalistdownload/backend/secret"
+	"github.com/ddoalistdownload/backend/service"
+	"github.com/ddoalistdownload/backend/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -35,6 +38,15 @@ func main() {
 	// 加载配置
 	cfg := config.LoadConfig()
 
+	// 初始化敏感字段加密密钥
+	keyring, err := secret.Init(cfg.Security.SecretKeys, cfg.Security.SecretKeyVersion)
+	if err != nil {
+		logrus.Fatalf("初始化加密密钥失败: %v", err)
+	}
+	if !keyring.Enabled() {
+		logrus.Warn("未配置 SECRET_KEYS，AppSecret 等敏感字段将以明文保存")
+	}
+
 	// 初始化数据库连接
 	if err := database.InitMySQL(&cfg.MySQL, initDB); err != nil {
 		logrus.Fatalf("初始化MySQL失败: %v", err)
@@ -61,6 +73,42 @@ func main() {
 		logrus.Fatalf("初始化Redis失败: %v", err)
 	}
 
+	// 初始化文件存储
+	if err := storage.Init(&cfg.Storage); err != nil {
+		logrus.Fatalf("初始化文件存储失败: %v", err)
+	}
+
+	// 订阅下载任务事件
+	downloadEventHub := service.GetDownloadEventHub()
+	downloadEventHub.Start()
+
+	// 启动下载任务队列
+	downloadQueue := service.InitDownloadQueue(cfg.Download)
+	downloadQueue.Start()
+
+	// 启动定时任务调度器
+	downloadScheduler := service.InitDownloadScheduler()
+	downloadScheduler.Start()
+
+	// 启动过期导出文件清理任务
+	downloadCleaner := service.NewDownloadCleaner(cfg.Storage)
+	downloadCleaner.Start()
+
+	// 启动AccessToken后台刷新
+	tokenRefresher := service.NewAccessTokenRefresher()
+	tokenRefresher.Start()
+
+	// 启动组织架构定时同步
+	orgSyncer := service.NewOrgSyncer()
+	orgSyncer.Start()
+
+	// 启动旧版API服务健康检查
+	legacyHealthChecker := service.NewLegacyHealthChecker()
+	legacyHealthChecker.Start()
+
+	// 注册钉钉回调事件处理器
+	service.RegisterDefaultCallbackHandlers()
+
 	// 创建Gin引擎
 	router := gin.Default()
 
@@ -89,6 +137,15 @@ func main() {
 	<-quit
 	logrus.Info("正在关闭服务器...")
 
+	// 停止定时任务调度器和下载任务队列，未完成的任务会放回队列
+	downloadScheduler.Stop()
+	downloadQueue.Stop()
+	downloadCleaner.Stop()
+	downloadEventHub.Stop()
+	tokenRefresher.Stop()
+	orgSyncer.Stop()
+	legacyHealthChecker.Stop()
+
 	// 关闭数据库连接
 	sqlDB, _ := database.DB.DB()
 	if sqlDB != nil {
@@ -123,12 +180,24 @@ func registerRoutes(router *gin.Engine) {
 	dataDictionaryController := controller.NewDataDictionaryController()
 	downloadTaskController := controller.NewDownloadTaskController()
 	apiTestController := controller.NewAPITestController()
+	storageController := controller.NewStorageController()
+	downloadScheduleController := controller.NewDownloadScheduleController()
+	orgSyncController := controller.NewOrgSyncController()
+	callbackController := controller.NewCallbackController()
+	messageController := controller.NewMessageController()
 
 	// API分组
 	api := router.Group("/api/v1")
 	{
 		// 登录路由（不需要认证）
 		api.POST("/user/login", userController.Login)
+		api.POST("/user/sso-login", userController.SSOLogin)
+
+		// 签名下载地址（凭签名访问，不需要认证）
+		api.GET("/storage/file", storageController.File)
+
+		// 钉钉事件订阅回调（凭签名访问，不需要认证）
+		api.POST("/dingtalk/callback/:company_id", callbackController.Receive)
 
 		// 需要认证的路由分组
 		authAPI := api.Group("")
@@ -159,6 +228,15 @@ func registerRoutes(router *gin.Engine) {
 			sso.GET("/config", ssoController.GetConfig)
 			sso.POST("/config", ssoController.UpdateConfig)
 			sso.GET("/test", ssoController.TestSSO)
+			sso.GET("/bindings", ssoController.ListBindings)
+			sso.POST("/bindings", ssoController.CreateBinding)
+			sso.DELETE("/bindings/:id", ssoController.DeleteBinding)
+			sso.GET("/logs", ssoController.ListLogs)
+			sso.GET("/stats", ssoController.Stats)
+			sso.GET("/callback/events", callbackController.ListEvents)
+			sso.POST("/callback/events/:id/retry", callbackController.RetryEvent)
+			// H5微应用签名供所有登录用户获取本公司的签名，不需要免登配置管理权限
+			authAPI.POST("/sso/jsapi-signature", ssoController.JSAPISignature)
 
 			// AccessToken管理
 			accessToken := authAPI.Group("/access-token")
@@ -170,6 +248,29 @@ func registerRoutes(router *gin.Engine) {
 			accessToken.GET("/list", accessTokenController.GetAccessTokenList)
 			accessToken.POST("/refresh", accessTokenController.RefreshAccessToken)
 			accessToken.POST("/test", accessTokenController.TestAccessToken)
+			accessToken.GET("/health", accessTokenController.GetTokenHealth)
+			accessToken.GET("/refresh-logs", accessTokenController.GetRefreshLogs)
+
+			// 钉钉组织架构同步
+			org := authAPI.Group("/org")
+			org.Use(middleware.PermissionMiddleware("org:manage"))
+			org.POST("/sync", orgSyncController.Sync)
+			org.GET("/sync-runs", orgSyncController.ListRuns)
+			org.GET("/departments", orgSyncController.ListDepartments)
+			org.GET("/users", orgSyncController.ListUsers)
+
+			// 工作通知和机器人消息
+			message := authAPI.Group("/message")
+			message.Use(middleware.PermissionMiddleware("message:manage"))
+			message.GET("/templates", messageController.ListTemplates)
+			message.POST("/templates", messageController.SaveTemplate)
+			message.DELETE("/templates/:id", messageController.DeleteTemplate)
+			message.GET("/robots", messageController.ListRobots)
+			message.POST("/robots", messageController.CreateRobot)
+			message.PUT("/robots/:id", messageController.UpdateRobot)
+			message.DELETE("/robots/:id", messageController.DeleteRobot)
+			message.GET("/logs", messageController.ListLogs)
+			message.POST("/send", messageController.Send)
 
 			// API配置管理
 			apiConfig := authAPI.Group("/api-config")
@@ -180,6 +281,10 @@ func registerRoutes(router *gin.Engine) {
 			apiConfig.PUT("/:id", apiConfigController.Update)
 			apiConfig.DELETE("/:id", apiConfigController.Delete)
 			apiConfig.POST("/test", apiConfigController.Test)
+			apiConfig.GET("/legacy/health", apiConfigController.LegacyHealth)
+			apiConfig.POST("/mapping/preview", apiConfigController.PreviewMapping)
+			apiConfig.GET("/:id/sync-state", apiConfigController.GetSyncState)
+			apiConfig.DELETE("/:id/sync-state", apiConfigController.ResetSyncState)
 
 			// 用户管理
 			user := authAPI.Group("/user")
@@ -244,11 +349,30 @@ func registerRoutes(router *gin.Engine) {
 			downloadTask.Use(middleware.PermissionMiddleware("download_task:manage"))
 			downloadTask.GET("", downloadTaskController.List)
 			downloadTask.POST("", downloadTaskController.Create)
+			downloadTask.GET("/events", downloadTaskController.UserEvents)
 			downloadTask.GET("/user/:user_id", downloadTaskController.GetTaskByUserID)
 			downloadTask.GET("/result/:task_id", downloadTaskController.GetResult)
 			downloadTask.GET("/:id", downloadTaskController.Get)
+			downloadTask.GET("/:id/file", downloadTaskController.Download)
+			downloadTask.GET("/:id/events", downloadTaskController.Events)
+			downloadTask.POST("/:id/cancel", downloadTaskController.Cancel)
+			downloadTask.POST("/:id/retry", downloadTaskController.Retry)
+			downloadTask.POST("/:id/rerun", downloadTaskController.Rerun)
 			downloadTask.DELETE("/:id", downloadTaskController.Delete)
 
+			// 定时下载任务管理
+			downloadSchedule := authAPI.Group("/download-schedule")
+			downloadSchedule.Use(middleware.PermissionMiddleware("download_schedule:manage"))
+			downloadSchedule.GET("", downloadScheduleController.List)
+			downloadSchedule.POST("", downloadScheduleController.Create)
+			downloadSchedule.GET("/:id", downloadScheduleController.Get)
+			downloadSchedule.PUT("/:id", downloadScheduleController.Update)
+			downloadSchedule.DELETE("/:id", downloadScheduleController.Delete)
+			downloadSchedule.POST("/:id/pause", downloadScheduleController.Pause)
+			downloadSchedule.POST("/:id/resume", downloadScheduleController.Resume)
+			downloadSchedule.POST("/:id/run", downloadScheduleController.RunNow)
+			downloadSchedule.GET("/:id/runs", downloadScheduleController.Runs)
+
 			// API测试管理
 			apiTest := authAPI.Group("/api-test")
 			apiTest.Use(middleware.PermissionMiddleware("api_test:manage"))
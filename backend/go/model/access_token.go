@@ -2,19 +2,26 @@ package model
 
 import (
 	"time"
+
+	"github.com/ddoalistdownload/backend/secret"
 )
 
 // AccessToken accessToken 模型
 type AccessToken struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
-	CompanyID   uint      `gorm:"not null;uniqueIndex" json:"company_id"`
+	CompanyID   uint      `gorm:"not null;uniqueIndex:idx_access_token_company_type" json:"company_id"`
+	TokenType   string    `gorm:"size:20;not null;default:'legacy';uniqueIndex:idx_access_token_company_type" json:"token_type"` // 令牌类型：legacy 旧版 gettoken，oauth2 新版 oauth2/accessToken
 	AppKey      string    `gorm:"size:100;not null" json:"app_key"`
-	AppSecret   string    `gorm:"size:255;not null" json:"app_secret"`
+	AppSecret   secret.String `gorm:"size:512;not null" json:"app_secret"` // 加密保存，接口响应中脱敏
+	AgentID     int64     `gorm:"default:0" json:"agent_id"` // 应用的 AgentID，发送工作通知时使用
 	AccessToken string    `gorm:"size:500" json:"access_token"`
 	ExpiresIn   int       `gorm:"default:7200" json:"expires_in"` // 过期时间（秒）
 	ExpiresAt   time.Time `json:"expires_at"`                     // 过期时间
 	RefreshAt   time.Time `json:"refresh_at"`                     // 刷新时间
 	Status      int       `gorm:"default:1" json:"status"`       // 1: 有效, 0: 无效
+	LastRefreshStatus string `gorm:"size:20" json:"last_refresh_status"` // 最近一次刷新结果：success, failed
+	LastRefreshError  string `gorm:"type:text" json:"last_refresh_error"` // 最近一次刷新失败的原因
+	RefreshFailures   int    `gorm:"default:0" json:"refresh_failures"`   // 连续刷新失败次数
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   *time.Time `gorm:"index" json:"deleted_at,omitempty"`
@@ -27,3 +34,42 @@ type AccessToken struct {
 func (AccessToken) TableName() string {
 	return "access_token"
 }
+
+// AccessToken 类型，每个公司每种类型最多一条配置
+const (
+	AccessTokenTypeLegacy = "legacy" // 旧版接口 oapi.dingtalk.com/gettoken，用于 oapi 接口
+	AccessTokenTypeOAuth2 = "oauth2" // 新版接口 api.dingtalk.com/v1.0/oauth2/accessToken，用于 api 接口
+)
+
+// AccessToken 刷新触发方式
+const (
+	TokenRefreshTriggerScheduled = "scheduled" // 后台在过期前提前刷新
+	TokenRefreshTriggerExpired   = "expired"   // 调用时发现已过期
+	TokenRefreshTriggerManual    = "manual"    // 手动刷新
+	TokenRefreshTriggerRejected  = "rejected"  // 钉钉接口返回 AccessToken 失效
+)
+
+// AccessToken 刷新结果
+const (
+	TokenRefreshStatusSuccess = "success"
+	TokenRefreshStatusFailed  = "failed"
+)
+
+// AccessTokenRefreshLog AccessToken 刷新记录
+type AccessTokenRefreshLog struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CompanyID uint       `gorm:"not null;index" json:"company_id"` // 公司ID
+	TokenType string     `gorm:"size:20" json:"token_type"`        // 令牌类型：legacy, oauth2
+	Trigger   string     `gorm:"size:20" json:"trigger"`           // 触发方式：scheduled, expired, manual, rejected
+	Status    string     `gorm:"size:20" json:"status"`            // 刷新结果：success, failed
+	ErrorMsg  string     `gorm:"type:text" json:"error_msg"`       // 失败原因
+	ExpiresAt *time.Time `json:"expires_at"`                       // 刷新后的过期时间
+	Duration  int64      `json:"duration"`                         // 耗时（毫秒）
+	Instance  string     `gorm:"size:100" json:"instance"`         // 执行刷新的实例
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (AccessTokenRefreshLog) TableName() string {
+	return "access_token_refresh_log"
+}
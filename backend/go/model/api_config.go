@@ -17,6 +17,14 @@ type APIConfig struct {
 	Method      string    `gorm:"size:10" json:"method"` // GET, POST, PUT, DELETE
 	Params      string    `gorm:"type:text" json:"params"` // 参数配置，JSON 格式
 	Headers     string    `gorm:"type:text" json:"headers"` // 请求头配置，JSON 格式
+	AuthMode    string    `gorm:"size:20" json:"auth_mode"` // 鉴权方式：none, query, header，为空时按 Type 选择，见 AuthMode 常量
+	RecordPath  string    `gorm:"size:200" json:"record_path"` // 列表数据在响应中的路径，如 result.list
+	Module      string    `gorm:"size:50" json:"module"` // 导出时查询数据字典的模块名，为空时使用 Code
+	Pagination  string    `gorm:"type:text" json:"pagination"` // 分页配置，JSON 格式，见 APIPagination
+	Incremental string    `gorm:"type:text" json:"incremental"` // 增量同步配置，JSON 格式，见 APIIncremental
+	Mapping     string    `gorm:"type:text" json:"mapping"` // 响应到导出记录的映射，JSON 格式，见 APIMapping
+	QPS         float64   `gorm:"default:0" json:"qps"` // 每秒请求数上限，0 表示使用公司的默认限流
+	Burst       int       `gorm:"default:0" json:"burst"` // 允许的突发请求数，0 表示与 QPS 相同
 	Description string    `gorm:"type:text" json:"description"`
 	Status      int       `gorm:"default:1" json:"status"` // 1: 启用, 0: 禁用
 	CreatedAt   time.Time `json:"created_at"`
@@ -31,3 +39,78 @@ type APIConfig struct {
 func (APIConfig) TableName() string {
 	return "api_config"
 }
+
+// API 类型，对应 APIConfig.Type
+const (
+	APITypeNew    = 1 // 新版接口，由 Go 服务直接调用
+	APITypeLegacy = 2 // 旧版接口，由 backend/python 旧版API服务代理调用
+)
+
+// 调用钉钉接口时 AccessToken 的传递方式
+const (
+	AuthModeNone   = "none"   // 不附加 AccessToken
+	AuthModeQuery  = "query"  // 旧版接口，通过 access_token 查询参数传递
+	AuthModeHeader = "header" // 新版接口，通过 x-acs-dingtalk-access-token 请求头传递
+)
+
+// 分页方式
+const (
+	PaginationModeCursor = "cursor" // 游标分页，如 next_cursor
+	PaginationModeOffset = "offset" // 偏移量分页，如 offset + size
+	PaginationModePage   = "page"   // 页码分页，如 page_num + page_size
+)
+
+// APIPagination 列表接口的分页描述，存储在 APIConfig.Pagination 中
+type APIPagination struct {
+	Mode          string `json:"mode"`            // 分页方式：cursor, offset, page
+	CursorParam   string `json:"cursor_param"`    // 请求中的游标/偏移量/页码参数名
+	CursorPath    string `json:"cursor_path"`     // 响应中下一页游标的路径，仅 cursor 方式使用，如 result.next_cursor
+	StartValue    int    `json:"start_value"`     // offset/page 方式的起始值，page 方式默认为 1
+	PageSizeParam string `json:"page_size_param"` // 请求中的每页数量参数名
+	PageSize      int    `json:"page_size"`       // 每页数量
+	HasMorePath   string `json:"has_more_path"`   // 响应中是否还有下一页的路径，如 result.has_more
+	TotalPath     string `json:"total_path"`      // 响应中总记录数的路径（可选），用于计算进度
+	MaxPages      int    `json:"max_pages"`       // 最多请求的页数，防止死循环
+}
+
+// 增量同步水位来源
+const (
+	WatermarkSourceRecord = "record" // 取本次记录中 watermark_path 的最大值，如 start_time
+	WatermarkSourceCursor = "cursor" // 取最后一页返回的下一页游标
+)
+
+// APIIncremental 增量同步配置，存储在 APIConfig.Incremental 中
+type APIIncremental struct {
+	Param         string      `json:"param"`          // 接收水位的请求参数名，如 start_time、cursor
+	Source        string      `json:"source"`         // 水位来源：record, cursor，默认 record
+	WatermarkPath string      `json:"watermark_path"` // 记录中水位字段的路径，仅 record 方式使用，如 create_time
+	KeyPath       string      `json:"key_path"`       // 记录主键的路径，用于去重，如 process_instance_id
+	InitialValue  interface{} `json:"initial_value"`  // 首次同步时使用的水位，为空时使用任务参数中的值
+}
+
+// 映射列的类型转换
+const (
+	MappingTypeString = "string" // 字符串
+	MappingTypeInt    = "int"    // 整数
+	MappingTypeFloat  = "float"  // 浮点数
+	MappingTypeBool   = "bool"   // 布尔值
+	MappingTypeDate   = "date"   // 日期时间，按 date_format 格式化
+)
+
+// APIMapping 响应到导出记录的映射，存储在 APIConfig.Mapping 中，记录数组的路径使用 APIConfig.RecordPath
+// 配置了 columns 时只导出这些列，按配置顺序输出；未配置时导出记录中的所有字段
+type APIMapping struct {
+	Timezone string             `json:"timezone"` // 格式化日期使用的时区，默认 Asia/Shanghai
+	Columns  []APIMappingColumn `json:"columns"`  // 导出列
+}
+
+// APIMappingColumn 映射的导出列
+type APIMappingColumn struct {
+	Source     string      `json:"source"`      // 记录中的字段路径，如 originator_userid、form_values[0].value
+	Target     string      `json:"target"`      // 导出列名，为空时使用 source
+	Label      string      `json:"label"`       // 表头，为空时使用数据字典中的字段名称
+	Type       string      `json:"type"`        // 类型转换：string, int, float, bool, date，为空时保持原值
+	DateFormat string      `json:"date_format"` // 日期格式，如 YYYY-MM-DD HH:mm:ss，默认精确到秒
+	Dictionary string      `json:"dictionary"`  // 按数据字典中该字段的 Value→Label 翻译取值
+	Default    interface{} `json:"default"`     // 取不到值时使用的默认值
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// 回调事件的处理状态
+const (
+	CallbackEventStatusPending   = "pending"   // 已接收，等待处理
+	CallbackEventStatusProcessed = "processed" // 所有处理器执行成功
+	CallbackEventStatusFailed    = "failed"    // 有处理器执行失败
+	CallbackEventStatusIgnored   = "ignored"   // 没有对应的处理器
+)
+
+// CallbackEvent 钉钉事件订阅推送的回调事件
+type CallbackEvent struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CompanyID   uint       `gorm:"not null;uniqueIndex:uni_callback_event;index:idx_callback_event_created,priority:1" json:"company_id"` // 公司ID
+	EventType   string     `gorm:"size:64;index" json:"event_type"`                                                                       // 事件类型，如 bpms_instance_change, user_add_org
+	PayloadHash string     `gorm:"size:64;not null;uniqueIndex:uni_callback_event" json:"-"`                                              // 解密后内容的 sha256，钉钉重试推送时用于去重
+	Payload     string     `gorm:"type:text" json:"payload"`                                                                              // 解密后的事件内容（JSON格式）
+	Status      string     `gorm:"size:20;index" json:"status"`                                                                           // 处理状态：pending, processed, failed, ignored
+	Attempts    int        `json:"attempts"`                                                                                              // 处理次数
+	ErrorMsg    string     `gorm:"type:text" json:"error_msg"`                                                                            // 处理失败的原因
+	ProcessedAt *time.Time `json:"processed_at"`                                                                                          // 最近一次处理完成时间
+	CreatedAt   time.Time  `gorm:"index:idx_callback_event_created,priority:2" json:"created_at"`
+}
+
+// TableName 设置表名
+func (CallbackEvent) TableName() string {
+	return "dingtalk_callback_event"
+}
@@ -10,8 +10,10 @@ type Company struct {
 	ParentID  uint       `gorm:"default:0" json:"parent_id"` // 父公司ID，0表示集团总部
 	Name      string     `gorm:"size:100;not null" json:"name"`
 	Code      string     `gorm:"size:50;uniqueIndex:uni_company_code" json:"code"`
-	Type      int        `gorm:"default:1" json:"type"`   // 1: 集团总部, 2: 分子公司
-	Status    int        `gorm:"default:1" json:"status"` // 1: 启用, 0: 禁用
+	Type      int        `gorm:"default:1" json:"type"`                       // 1: 集团总部, 2: 分子公司
+	Status    int        `gorm:"default:1" json:"status"`                     // 1: 启用, 0: 禁用
+	APIQPS    float64    `gorm:"column:api_qps;default:0" json:"api_qps"`     // 调用钉钉接口的默认每秒请求数上限，按接口分别计算，0 表示不限流
+	APIBurst  int        `gorm:"column:api_burst;default:0" json:"api_burst"` // 默认允许的突发请求数，0 表示与 QPS 相同
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+)
+
+// 定时任务触发方式
+const (
+	ScheduleTriggerCron   = "cron"   // 按 Cron 表达式触发
+	ScheduleTriggerManual = "manual" // 手动立即执行
+	ScheduleTriggerEvent  = "event"  // 收到钉钉回调事件时执行
+)
+
+// 定时任务执行结果
+const (
+	ScheduleRunStatusSuccess = "success" // 已创建下载任务
+	ScheduleRunStatusFailed  = "failed"  // 创建下载任务失败
+)
+
+// DownloadSchedule 定时下载任务模型
+// 按 Cron 表达式定期创建 DownloadTask，参数模板中的相对日期在触发时计算
+type DownloadSchedule struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CompanyID   uint       `gorm:"not null" json:"company_id"`                      // 公司ID
+	UserID      uint       `gorm:"not null" json:"user_id"`                         // 创建人ID，生成的任务归属此用户
+	APIConfigID uint       `gorm:"not null" json:"api_config_id"`                   // API配置ID
+	Name        string     `gorm:"size:100;not null" json:"name"`                   // 定时任务名称
+	TaskType    string     `gorm:"size:20;default:'list'" json:"task_type"`         // 生成任务的类型：list, detail, approval
+	CronExpr    string     `gorm:"size:100;not null" json:"cron_expr"`              // Cron 表达式，如 0 8 * * *
+	Timezone    string     `gorm:"size:50;default:'Asia/Shanghai'" json:"timezone"` // 时区，如 Asia/Shanghai
+	Params      string     `gorm:"type:text" json:"params"`                         // 参数模板（JSON格式），支持 ${yesterday_start_ms} 等相对日期
+	FileFormat  string     `gorm:"size:10;default:'json'" json:"file_format"`       // 导出格式：json, csv, xlsx
+	SyncMode    string     `gorm:"size:20;default:'full'" json:"sync_mode"`         // 同步方式：full, incremental
+	Status      *int       `gorm:"default:1" json:"status"`                         // 1: 启用, 0: 暂停，未指定时为启用
+	EventTypes  string     `gorm:"size:500" json:"event_types"`                     // 收到这些钉钉回调事件时立即执行，逗号分隔，如 bpms_instance_change
+	NextRunAt   *time.Time `json:"next_run_at"`                                     // 下次执行时间
+	LastRunAt   *time.Time `json:"last_run_at"`                                     // 上次执行时间
+	LastTaskID  *uint      `json:"last_task_id"`                                    // 上次生成的下载任务ID
+	Description string     `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+
+	// 关联关系
+	Company   Company   `gorm:"foreignKey:CompanyID" json:"company"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	APIConfig APIConfig `gorm:"foreignKey:APIConfigID" json:"api_config"`
+}
+
+// TableName 设置表名
+func (DownloadSchedule) TableName() string {
+	return "download_schedule"
+}
+
+// DownloadScheduleRun 定时任务执行记录
+type DownloadScheduleRun struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID  uint      `gorm:"not null;index" json:"schedule_id"` // 定时任务ID
+	TaskID      *uint     `json:"task_id"`                           // 生成的下载任务ID
+	Trigger     string    `gorm:"size:20" json:"trigger"`            // 触发方式：cron, manual, event
+	ScheduledAt time.Time `json:"scheduled_at"`                      // 计划触发时间
+	Params      string    `gorm:"type:text" json:"params"`           // 计算后的请求参数（JSON格式）
+	Status      string    `gorm:"size:20" json:"status"`             // 执行结果：success, failed
+	ErrorMsg    string    `gorm:"type:text" json:"error_msg"`        // 错误信息
+	CreatedAt   time.Time `json:"created_at"`
+
+	// 关联关系
+	DownloadTask *DownloadTask `gorm:"foreignKey:TaskID" json:"download_task,omitempty"`
+}
+
+// TableName 设置表名
+func (DownloadScheduleRun) TableName() string {
+	return "download_schedule_run"
+}
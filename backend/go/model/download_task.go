@@ -4,6 +4,35 @@ import (
 	"time"
 )
 
+// 下载任务状态
+const (
+	DownloadTaskStatusPending = "pending"
+	DownloadTaskStatusRunning = "running"
+	DownloadTaskStatusSuccess = "success"
+	DownloadTaskStatusFailed  = "failed"
+	DownloadTaskStatusCancelled = "cancelled"
+)
+
+// 下载任务类型
+const (
+	DownloadTaskTypeList     = "list"     // 按API配置分页获取列表
+	DownloadTaskTypeDetail   = "detail"   // 按API配置获取详情
+	DownloadTaskTypeApproval = "approval" // 导出钉钉审批实例，参数见 service.ApprovalParams
+)
+
+// 同步方式
+const (
+	SyncModeFull        = "full"        // 全量，每次重新获取全部数据
+	SyncModeIncremental = "incremental" // 增量，从上次的水位继续并按主键去重
+)
+
+// 导出文件格式
+const (
+	FileFormatJSON  = "json"
+	FileFormatCSV   = "csv"
+	FileFormatExcel = "xlsx"
+)
+
 // DownloadTask 下载任务模型
 type DownloadTask struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -11,15 +40,24 @@ type DownloadTask struct {
 	UserID      uint      `gorm:"not null" json:"user_id"`           // 用户ID
 	APIConfigID uint      `gorm:"not null" json:"api_config_id"`     // API配置ID
 	TaskName    string    `gorm:"size:100;not null" json:"task_name"` // 任务名称
-	TaskType    string    `gorm:"size:20;not null" json:"task_type"`  // 任务类型：list, detail
+	TaskType    string    `gorm:"size:20;not null" json:"task_type"`  // 任务类型：list, detail, approval
 	Params      string    `gorm:"type:text" json:"params"`           // 请求参数（JSON格式）
-	Status      string    `gorm:"size:20;default:'pending'" json:"status"` // 任务状态：pending, running, success, failed
+	FileFormat  string    `gorm:"size:10;default:'json'" json:"file_format"` // 导出格式：json, csv, xlsx
+	SyncMode    string    `gorm:"size:20;default:'full'" json:"sync_mode"`  // 同步方式：full, incremental
+	Status      string    `gorm:"size:20;default:'pending';index" json:"status"` // 任务状态：pending, running, success, failed, cancelled
 	Progress    int       `gorm:"default:0" json:"progress"`         // 任务进度（0-100）
+	Attempts    int       `gorm:"default:0" json:"attempts"`         // 已执行次数
+	SourceTaskID *uint    `json:"source_task_id"`                    // 重新运行时复制的来源任务ID
+	PagesFetched   int    `gorm:"default:0" json:"pages_fetched"`   // 已获取页数
+	RecordsFetched int    `gorm:"default:0" json:"records_fetched"` // 已获取记录数
+	Checkpoint  string    `gorm:"type:text" json:"-"`                // 分页断点（JSON格式），重试时从上次完成的页继续
 	Result      string    `gorm:"type:text" json:"result"`           // 任务结果（JSON格式）
 	FileURL     string    `gorm:"size:255" json:"file_url"`          // 下载文件URL
 	FileName    string    `gorm:"size:100" json:"file_name"`         // 下载文件名称
 	FileSize    int64     `gorm:"default:0" json:"file_size"`         // 文件大小（字节）
 	ErrorMsg    string    `gorm:"type:text" json:"error_msg"`        // 错误信息
+	StartedAt   *time.Time `json:"started_at"`                        // 开始执行时间
+	FinishedAt  *time.Time `json:"finished_at"`                       // 执行结束时间
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   *time.Time `gorm:"index" json:"deleted_at,omitempty"`
@@ -36,15 +74,23 @@ func (DownloadTask) TableName() string {
 }
 
 // DownloadResult 下载结果模型
+// 导出文件保存在文件存储中，这里只记录元数据
 type DownloadResult struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	TaskID     uint      `gorm:"not null" json:"task_id"`           // 下载任务ID
-	Data       string    `gorm:"type:longtext" json:"data"`        // 下载数据（JSON格式）
-	DataType   string    `gorm:"size:20;not null" json:"data_type"`  // 数据类型：json, excel, csv
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	DeletedAt  *time.Time `gorm:"index" json:"deleted_at,omitempty"`
-	
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TaskID      uint       `gorm:"not null;index" json:"task_id"`       // 下载任务ID
+	DataType    string     `gorm:"size:20;not null" json:"data_type"`   // 数据类型：json, xlsx, csv
+	StorageType string     `gorm:"size:20" json:"storage_type"`         // 存储类型：local, s3
+	StorageKey  string     `gorm:"size:255" json:"storage_key"`         // 文件在存储中的路径
+	FileName    string     `gorm:"size:100" json:"file_name"`           // 下载文件名称
+	FileSize    int64      `gorm:"default:0" json:"file_size"`          // 文件大小（字节）
+	RecordCount int        `gorm:"default:0" json:"record_count"`       // 导出记录数
+	Redactions  string     `gorm:"type:text" json:"redactions"`         // 按字段权限移除或脱敏的列（JSON格式）
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`             // 文件过期时间，过期后由清理任务删除
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"`     // 带签名的下载地址，查询时生成
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+
 	// 关联关系
 	DownloadTask DownloadTask `gorm:"foreignKey:TaskID" json:"download_task"`
 }
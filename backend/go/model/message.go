@@ -0,0 +1,87 @@
+package model
+
+import (
+	"time"
+
+	"github.com/ddoalistdownload/backend/secret"
+)
+
+// 消息发送渠道
+const (
+	MessageChannelWorkNotice = "work_notice" // 钉钉工作通知，发送给用户
+	MessageChannelRobot      = "robot"       // 群自定义机器人
+)
+
+// 触发消息的事件，对应消息模板的 event
+const (
+	MessageEventDownloadSuccess    = "download_task_success" // 下载任务执行成功
+	MessageEventDownloadFailed     = "download_task_failed"  // 下载任务执行失败
+	MessageEventTokenRefreshFailed = "token_refresh_failed"  // AccessToken 刷新失败
+)
+
+// 消息发送结果
+const (
+	MessageStatusSuccess = "success"
+	MessageStatusFailed  = "failed"
+)
+
+// MessageTemplate 消息模板，每个公司每个事件一条
+// 标题和内容使用 Go text/template 语法，如 {{.TaskName}}，内容以 Markdown 发送
+type MessageTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CompanyID uint      `gorm:"not null;uniqueIndex:uni_message_template" json:"company_id"`    // 公司ID
+	Event     string    `gorm:"size:50;not null;uniqueIndex:uni_message_template" json:"event"` // 触发事件：download_task_success, download_task_failed, token_refresh_failed
+	Title     string    `gorm:"size:200;not null" json:"title"`                                 // 标题模板
+	Content   string    `gorm:"type:text" json:"content"`                                       // 内容模板（Markdown）
+	Channels  string    `gorm:"size:50;default:'work_notice'" json:"channels"`                  // 发送渠道，逗号分隔：work_notice, robot
+	RobotID   uint      `gorm:"default:0" json:"robot_id"`                                      // 发送到的机器人，渠道包含 robot 时必填
+	Receivers string    `gorm:"type:text" json:"receivers"`                                     // 工作通知的固定接收人钉钉 userid，逗号分隔，与事件相关的用户一起接收
+	Status    int       `gorm:"default:1" json:"status"`                                        // 1: 启用, 0: 禁用
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (MessageTemplate) TableName() string {
+	return "message_template"
+}
+
+// MessageRobot 群自定义机器人
+type MessageRobot struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	CompanyID uint          `gorm:"not null;index" json:"company_id"`  // 公司ID
+	Name      string        `gorm:"size:100;not null" json:"name"`     // 机器人名称
+	Webhook   secret.String `gorm:"size:1024;not null" json:"webhook"` // Webhook 地址，包含 access_token，加密保存
+	Secret    secret.String `gorm:"size:512" json:"secret"`            // 加签密钥，加密保存，为空时不加签
+	Status    int           `gorm:"default:1" json:"status"`           // 1: 启用, 0: 禁用
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (MessageRobot) TableName() string {
+	return "message_robot"
+}
+
+// MessageLog 消息发送记录
+type MessageLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CompanyID  uint      `gorm:"not null;index:idx_message_log_company_created,priority:1" json:"company_id"` // 公司ID
+	Event      string    `gorm:"size:50;index" json:"event"`                                                  // 触发事件，直接发送时为空
+	Channel    string    `gorm:"size:20" json:"channel"`                                                      // 发送渠道：work_notice, robot
+	RobotID    uint      `json:"robot_id"`                                                                    // 机器人ID
+	Receivers  string    `gorm:"type:text" json:"receivers"`                                                  // 工作通知的接收人 userid，逗号分隔
+	Title      string    `gorm:"size:200" json:"title"`                                                       // 渲染后的标题
+	Content    string    `gorm:"type:text" json:"content"`                                                    // 渲染后的内容
+	Status     string    `gorm:"size:20" json:"status"`                                                       // 发送结果：success, failed
+	Errcode    int       `json:"errcode"`                                                                     // 钉钉返回的错误码
+	ErrorMsg   string    `gorm:"type:text" json:"error_msg"`                                                  // 失败原因
+	DingTaskID int64     `gorm:"column:dingtalk_task_id" json:"dingtalk_task_id"`                             // 工作通知的异步发送任务ID
+	Duration   int64     `json:"duration"`                                                                    // 耗时（毫秒）
+	CreatedAt  time.Time `gorm:"index:idx_message_log_company_created,priority:2" json:"created_at"`
+}
+
+// TableName 设置表名
+func (MessageLog) TableName() string {
+	return "message_log"
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+)
+
+// Department 从钉钉同步的部门
+type Department struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CompanyID uint      `gorm:"not null;uniqueIndex:uni_department" json:"company_id"` // 公司ID
+	DeptID    int64     `gorm:"not null;uniqueIndex:uni_department" json:"dept_id"`    // 钉钉部门ID，根部门为 1
+	ParentID  int64     `gorm:"default:0" json:"parent_id"`                            // 钉钉父部门ID，根部门为 0
+	Name      string    `gorm:"size:100" json:"name"`                                  // 部门名称
+	SyncRunID uint      `json:"sync_run_id"`                                           // 最近一次变更该部门的同步记录ID
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联关系
+	Company Company `gorm:"foreignKey:CompanyID" json:"-"`
+}
+
+// TableName 设置表名
+func (Department) TableName() string {
+	return "department"
+}
+
+// DingTalkUser 从钉钉同步的企业成员
+type DingTalkUser struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CompanyID uint      `gorm:"not null;uniqueIndex:uni_dingtalk_user" json:"company_id"`                             // 公司ID
+	UserID    string    `gorm:"column:dingtalk_userid;size:100;not null;uniqueIndex:uni_dingtalk_user" json:"userid"` // 钉钉 userid
+	UnionID   string    `gorm:"column:dingtalk_unionid;size:100;index" json:"unionid"`                                // 钉钉 unionid
+	Name      string    `gorm:"size:100" json:"name"`                                                                 // 姓名
+	Mobile    string    `gorm:"size:20" json:"mobile"`                                                                // 手机号
+	Email     string    `gorm:"size:100" json:"email"`                                                                // 邮箱
+	JobNumber string    `gorm:"size:50" json:"job_number"`                                                            // 工号
+	Title     string    `gorm:"size:100" json:"title"`                                                                // 职位
+	DeptIDs   string    `gorm:"size:500" json:"dept_ids"`                                                             // 所属部门ID，逗号分隔并以逗号包围，如 ,1,3, 便于按部门查询
+	Active    bool      `json:"active"`                                                                               // 是否已激活钉钉
+	SyncRunID uint      `json:"sync_run_id"`                                                                          // 最近一次变更该成员的同步记录ID
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联关系
+	Company Company `gorm:"foreignKey:CompanyID" json:"-"`
+}
+
+// TableName 设置表名
+func (DingTalkUser) TableName() string {
+	return "dingtalk_user"
+}
+
+// 组织架构同步的触发方式
+const (
+	OrgSyncTriggerManual    = "manual"    // 手动触发
+	OrgSyncTriggerScheduled = "scheduled" // 后台定时同步
+	OrgSyncTriggerEvent     = "event"     // 收到通讯录变更回调事件
+)
+
+// 组织架构同步的状态
+const (
+	OrgSyncStatusRunning = "running"
+	OrgSyncStatusSuccess = "success"
+	OrgSyncStatusFailed  = "failed"
+)
+
+// OrgSyncRun 组织架构同步记录
+type OrgSyncRun struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CompanyID   uint       `gorm:"not null;index" json:"company_id"` // 公司ID
+	Trigger     string     `gorm:"size:20" json:"trigger"`           // 触发方式：manual, scheduled, event
+	Status      string     `gorm:"size:20" json:"status"`            // 同步状态：running, success, failed
+	DeptAdded   int        `json:"dept_added"`                       // 新增部门数
+	DeptUpdated int        `json:"dept_updated"`                     // 更新部门数
+	DeptDeleted int        `json:"dept_deleted"`                     // 删除部门数
+	UserAdded   int        `json:"user_added"`                       // 新增成员数
+	UserUpdated int        `json:"user_updated"`                     // 更新成员数
+	UserDeleted int        `json:"user_deleted"`                     // 删除成员数
+	Diff        string     `gorm:"type:longtext" json:"diff"`        // 变更明细（JSON格式），首次同步大型组织时可达数 MB
+	ErrorMsg    string     `gorm:"type:text" json:"error_msg"`       // 失败原因
+	Duration    int64      `json:"duration"`                         // 耗时（毫秒）
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName 设置表名
+func (OrgSyncRun) TableName() string {
+	return "org_sync_run"
+}
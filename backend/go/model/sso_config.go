@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/ddoalistdownload/backend/secret"
 )
 
 // SSOConfig 身份验证（免登）配置模型
@@ -10,9 +12,14 @@ type SSOConfig struct {
 	CompanyID   uint      `gorm:"not null" json:"company_id"`
 	AppID       string    `gorm:"size:100;not null" json:"app_id"`
 	AppKey      string    `gorm:"size:100;not null" json:"app_key"`
-	AppSecret   string    `gorm:"size:255;not null" json:"app_secret"`
+	AppSecret   secret.String `gorm:"size:512;not null" json:"app_secret"` // 加密保存，接口响应中脱敏
 	RedirectURL string    `gorm:"size:255" json:"redirect_url"`
 	Scope       string    `gorm:"size:100" json:"scope"`
+	BindBy      string    `gorm:"size:20;default:'userid'" json:"bind_by"` // 绑定钉钉账号的依据：userid, unionid
+	UnboundPolicy string  `gorm:"size:20;default:'reject'" json:"unbound_policy"` // 未绑定账号登录时的处理方式：reject, match, provision
+	DefaultRoleID uint    `gorm:"default:0" json:"default_role_id"` // 自动创建用户时分配的角色，0 表示不分配
+	CallbackToken  secret.String `gorm:"size:512" json:"callback_token"`   // 事件订阅的签名 token，加密保存
+	CallbackAESKey secret.String `gorm:"size:512" json:"callback_aes_key"` // 事件订阅的加密 aes_key（43位），加密保存
 	Status      int       `gorm:"default:1" json:"status"` // 1: 启用, 0: 禁用
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -26,3 +33,70 @@ type SSOConfig struct {
 func (SSOConfig) TableName() string {
 	return "sso_config"
 }
+
+// 钉钉账号的绑定依据
+const (
+	SSOBindByUserID  = "userid"  // 企业内的 userid，同一企业内唯一
+	SSOBindByUnionID = "unionid" // 开放平台 unionid，同一开发者的应用之间一致
+)
+
+// 未绑定的钉钉账号登录时的处理方式
+const (
+	SSOUnboundReject    = "reject"    // 拒绝登录，需要管理员先绑定
+	SSOUnboundMatch     = "match"     // 按手机号或邮箱匹配公司内已有用户并自动绑定，匹配不到时拒绝
+	SSOUnboundProvision = "provision" // 按手机号或邮箱匹配，匹配不到时自动创建用户并分配默认角色
+)
+
+// SSOBinding 钉钉账号与系统用户的绑定关系
+type SSOBinding struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CompanyID       uint       `gorm:"not null;uniqueIndex:idx_sso_binding_userid" json:"company_id"`                                         // 公司ID
+	UserID          uint       `gorm:"not null;index" json:"user_id"`                                                                          // 系统用户ID
+	DingTalkUserID  string     `gorm:"column:dingtalk_userid;size:100;not null;uniqueIndex:idx_sso_binding_userid" json:"dingtalk_userid"` // 钉钉 userid
+	DingTalkUnionID string     `gorm:"column:dingtalk_unionid;size:100;index" json:"dingtalk_unionid"`                                      // 钉钉 unionid
+	DingTalkName    string     `gorm:"column:dingtalk_name;size:100" json:"dingtalk_name"`                                                  // 钉钉姓名
+	LastLoginAt     *time.Time `json:"last_login_at"`                                                                                          // 最近一次免登时间
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User User `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// TableName 设置表名
+func (SSOBinding) TableName() string {
+	return "sso_binding"
+}
+
+// 免登记录的操作类型
+const (
+	SSOActionTest  = "test"  // 管理员测试免登配置
+	SSOActionLogin = "login" // 用户免登登录
+)
+
+// 免登记录的结果
+const (
+	SSOLogStatusSuccess = "success"
+	SSOLogStatusFailed  = "failed"
+)
+
+// SSOLoginLog 免登测试和免登登录记录
+type SSOLoginLog struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CompanyID      uint      `gorm:"not null;index:idx_sso_login_log_company_time" json:"company_id"` // 公司ID
+	Action         string    `gorm:"size:20" json:"action"`                                              // 操作类型：test, login
+	CodeHash       string    `gorm:"size:64" json:"code_hash"`                                           // 授权码的 SHA-256，不保存授权码原文
+	DingTalkUserID string    `gorm:"column:dingtalk_userid;size:100;index" json:"dingtalk_userid"`       // 解析出的钉钉 userid
+	UserID         uint      `gorm:"index" json:"user_id"`                                               // 匹配到的系统用户ID，0 表示未匹配
+	Status         string    `gorm:"size:20" json:"status"`                                              // 结果：success, failed
+	Errcode        int       `json:"errcode"`                                                            // 钉钉接口返回的错误码，非钉钉错误为 0
+	Errmsg         string    `gorm:"type:text" json:"errmsg"`                                            // 失败原因
+	Duration       int64     `json:"duration"`                                                           // 耗时（毫秒）
+	IP             string    `gorm:"size:50" json:"ip"`                                                  // 客户端IP
+	CreatedAt      time.Time `gorm:"index:idx_sso_login_log_company_time" json:"created_at"`
+}
+
+// TableName 设置表名
+func (SSOLoginLog) TableName() string {
+	return "sso_login_log"
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+)
+
+// SyncWatermark 增量同步水位，每个公司和API配置一条
+type SyncWatermark struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CompanyID   uint      `gorm:"not null;uniqueIndex:uni_sync_watermark" json:"company_id"`    // 公司ID
+	APIConfigID uint      `gorm:"not null;uniqueIndex:uni_sync_watermark" json:"api_config_id"` // API配置ID
+	Value       string    `gorm:"size:500" json:"value"`                                        // 水位值（JSON格式），下次同步时写入请求参数
+	TaskID      uint      `json:"task_id"`                                                      // 最近一次推进水位的下载任务ID
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (SyncWatermark) TableName() string {
+	return "sync_watermark"
+}
+
+// SyncRecord 增量同步已导出的记录，用于按主键去重
+type SyncRecord struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CompanyID   uint      `gorm:"not null;uniqueIndex:uni_sync_record" json:"company_id"`          // 公司ID
+	APIConfigID uint      `gorm:"not null;uniqueIndex:uni_sync_record" json:"api_config_id"`       // API配置ID
+	RecordKey   string    `gorm:"size:191;not null;uniqueIndex:uni_sync_record" json:"record_key"` // 记录主键
+	Hash        string    `gorm:"size:64" json:"hash"`                                             // 记录内容摘要，内容变化时重新导出
+	TaskID      uint      `json:"task_id"`                                                         // 最近一次导出该记录的下载任务ID
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 设置表名
+func (SyncRecord) TableName() string {
+	return "sync_record"
+}
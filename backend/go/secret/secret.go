@@ -0,0 +1,222 @@
+// Package secret 敏感字段的信封加密
+//
+// 每个值使用随机生成的数据密钥以 AES-GCM 加密，数据密钥再由主密钥以 AES-GCM 加密后与密文一起保存。
+// 保存格式为 enc:<密钥版本>:<加密的数据密钥>:<密文>，密钥版本用于轮换主密钥，
+// 旧版本的主密钥保留在配置中即可继续解密，再由重新加密命令迁移到新版本。
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix 加密值的前缀，没有前缀的值视为尚未加密的明文
+const prefix = "enc:"
+
+// dataKeySize 数据密钥长度，使用 AES-256
+const dataKeySize = 32
+
+// Keyring 主密钥集合
+type Keyring struct {
+	keys   map[string][]byte // 密钥版本 → 主密钥
+	active string            // 加密新数据使用的密钥版本
+}
+
+// ParseKeyring 解析密钥配置
+// keys 格式为 版本:base64主密钥，多个以逗号分隔，如 v1:xxx,v2:yyy；active 为空时使用最后一个版本
+func ParseKeyring(keys, active string) (*Keyring, error) {
+	keyring := &Keyring{keys: make(map[string][]byte)}
+	for _, item := range strings.Split(keys, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		version, encoded, ok := strings.Cut(item, ":")
+		if !ok || version == "" || strings.Contains(encoded, ":") {
+			return nil, fmt.Errorf("密钥格式错误，应为 版本:base64密钥: %s", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不是有效的 base64: %v", version, err)
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, fmt.Errorf("密钥 %s 长度应为 16、24 或 32 字节", version)
+		}
+		if _, exists := keyring.keys[version]; exists {
+			return nil, fmt.Errorf("密钥版本重复: %s", version)
+		}
+
+		keyring.keys[version] = key
+		keyring.active = version
+	}
+
+	if active != "" {
+		if _, ok := keyring.keys[active]; !ok {
+			return nil, fmt.Errorf("未配置密钥版本: %s", active)
+		}
+		keyring.active = active
+	}
+	return keyring, nil
+}
+
+// Enabled 是否配置了主密钥
+func (k *Keyring) Enabled() bool {
+	return k != nil && k.active != ""
+}
+
+// ActiveVersion 返回加密新数据使用的密钥版本
+func (k *Keyring) ActiveVersion() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// Encrypt 使用当前版本的主密钥加密，未配置主密钥时原样返回
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || !k.Enabled() {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.keys[k.active], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.active + ":" +
+		base64.StdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt 解密，没有加密前缀的值视为明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("加密数据格式错误")
+	}
+	if k == nil || k.keys[parts[0]] == nil {
+		return "", fmt.Errorf("未配置密钥版本: %s", parts[0])
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("加密数据格式错误")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("加密数据格式错误")
+	}
+
+	dataKey, err := open(k.keys[parts[0]], wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencrypt 值是否需要使用当前版本的主密钥重新加密
+func (k *Keyring) NeedsReencrypt(value string) bool {
+	if value == "" || !k.Enabled() {
+		return false
+	}
+	return Version(value) != k.active
+}
+
+// IsEncrypted 值是否为加密格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Version 返回加密值使用的密钥版本，明文返回空字符串
+func Version(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	version, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return version
+}
+
+// seal 使用 AES-GCM 加密，随机 nonce 放在密文前
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open 解密 seal 的结果
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("密文长度不足")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// Init 解析密钥配置并设置为默认密钥集合
+func Init(keys, active string) (*Keyring, error) {
+	keyring, err := ParseKeyring(keys, active)
+	if err != nil {
+		return nil, err
+	}
+	SetDefault(keyring)
+	return keyring, nil
+}
+
+// SetDefault 设置默认密钥集合
+func SetDefault(keyring *Keyring) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultKeyring = keyring
+}
+
+// Default 返回默认密钥集合，未初始化时返回 nil，此时不加密
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
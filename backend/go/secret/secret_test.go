@@ -0,0 +1,132 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var (
+	testKey1 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	testKey2 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+)
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		active string
+		want   string
+		ok     bool
+	}{
+		{"Empty", "", "", "", true},
+		{"LastIsActive", "v1:" + testKey1 + ", v2:" + testKey2, "", "v2", true},
+		{"ExplicitActive", "v1:" + testKey1 + ",v2:" + testKey2, "v1", "v1", true},
+		{"UnknownActive", "v1:" + testKey1, "v2", "", false},
+		{"MissingVersion", testKey1, "", "", false},
+		{"InvalidBase64", "v1:not-base64!", "", "", false},
+		{"InvalidLength", "v1:" + base64.StdEncoding.EncodeToString([]byte("short")), "", "", false},
+		{"DuplicateVersion", "v1:" + testKey1 + ",v1:" + testKey2, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := ParseKeyring(tt.keys, tt.active)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Expected ok %v, got %v", tt.ok, err)
+			}
+			if err == nil && keyring.ActiveVersion() != tt.want {
+				t.Errorf("Expected active version %q, got %q", tt.want, keyring.ActiveVersion())
+			}
+		})
+	}
+}
+
+func TestKeyringEncrypt(t *testing.T) {
+	v1, _ := ParseKeyring("v1:"+testKey1, "")
+	rotated, _ := ParseKeyring("v1:"+testKey1+",v2:"+testKey2, "v2")
+	v2Only, _ := ParseKeyring("v2:"+testKey2, "")
+
+	encrypted, err := v1.Encrypt("plain-secret")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:v1:") || strings.Contains(encrypted, "plain-secret") || Version(encrypted) != "v1" {
+		t.Fatalf("Unexpected encrypted value: %s", encrypted)
+	}
+	if again, _ := v1.Encrypt("plain-secret"); again == encrypted {
+		t.Error("Expected a fresh data key and nonce per value")
+	}
+
+	t.Run("Decrypt", func(t *testing.T) {
+		if plaintext, err := rotated.Decrypt(encrypted); err != nil || plaintext != "plain-secret" {
+			t.Errorf("Expected old version readable after rotation, got %q %v", plaintext, err)
+		}
+		if plaintext, err := v1.Decrypt("legacy-secret"); err != nil || plaintext != "legacy-secret" {
+			t.Errorf("Expected plaintext returned as is, got %q %v", plaintext, err)
+		}
+		if _, err := v2Only.Decrypt(encrypted); err == nil {
+			t.Error("Expected error when key version is not configured")
+		}
+
+		parts := strings.Split(encrypted, ":")
+		ciphertext, _ := base64.StdEncoding.DecodeString(parts[3])
+		ciphertext[len(ciphertext)-1] ^= 1
+		parts[3] = base64.StdEncoding.EncodeToString(ciphertext)
+		if _, err := v1.Decrypt(strings.Join(parts, ":")); err == nil {
+			t.Error("Expected tampered ciphertext to fail")
+		}
+	})
+
+	t.Run("NeedsReencrypt", func(t *testing.T) {
+		if v1.NeedsReencrypt(encrypted) || !rotated.NeedsReencrypt(encrypted) || !rotated.NeedsReencrypt("legacy-secret") {
+			t.Error("Expected only values not under the active version to need reencryption")
+		}
+		var disabled *Keyring
+		if disabled.NeedsReencrypt(encrypted) || rotated.NeedsReencrypt("") {
+			t.Error("Expected nothing to reencrypt without keys or value")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var disabled *Keyring
+		if value, err := disabled.Encrypt("plain-secret"); err != nil || value != "plain-secret" {
+			t.Errorf("Expected plaintext without keys, got %q %v", value, err)
+		}
+	})
+}
+
+func TestString(t *testing.T) {
+	keyring, _ := ParseKeyring("v1:"+testKey1, "")
+	SetDefault(keyring)
+	defer SetDefault(nil)
+
+	value, err := String("plain-secret").Value()
+	if err != nil || Version(value.(string)) != "v1" {
+		t.Fatalf("Expected encrypted value, got %v %v", value, err)
+	}
+
+	var scanned String
+	if err := scanned.Scan([]byte(value.(string))); err != nil || scanned != "plain-secret" {
+		t.Errorf("Expected decrypted scan, got %q %v", scanned, err)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned != "" {
+		t.Errorf("Expected empty scan for NULL, got %q %v", scanned, err)
+	}
+	if err := scanned.Scan(1); err == nil {
+		t.Error("Expected unsupported type to fail")
+	}
+
+	data, _ := json.Marshal(struct {
+		Secret String `json:"secret"`
+		Empty  String `json:"empty"`
+	}{Secret: "plain-secret"})
+	if string(data) != `{"secret":"******","empty":""}` {
+		t.Errorf("Expected masked JSON, got %s", data)
+	}
+
+	if !String(Masked).IsMasked() || !String("").IsMasked() || String("plain-secret").IsMasked() {
+		t.Error("Unexpected IsMasked result")
+	}
+}
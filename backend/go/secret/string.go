@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Masked 接口响应中代替敏感值返回的内容，提交表单时原样提交表示不修改
+const Masked = "******"
+
+// String 加密保存的字符串字段
+// 写入数据库时使用默认密钥集合加密，读取时解密；序列化为 JSON 时始终脱敏
+type String string
+
+// Value 写入数据库前加密
+func (s String) Value() (driver.Value, error) {
+	return Default().Encrypt(string(s))
+}
+
+// Scan 从数据库读取后解密
+func (s *String) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		raw = ""
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("不支持的加密字段类型: %T", value)
+	}
+
+	plaintext, err := Default().Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = String(plaintext)
+	return nil
+}
+
+// MarshalJSON 序列化时脱敏，避免在接口响应中返回明文
+func (s String) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return json.Marshal("")
+	}
+	return json.Marshal(Masked)
+}
+
+// IsMasked 是否为脱敏后的值或空值，更新时应保留原值
+func (s String) IsMasked() bool {
+	return s == "" || s == Masked
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -31,10 +33,10 @@ func NewAccessTokenService() *AccessTokenService {
 }
 
 // GetAccessToken 获取AccessToken
-func (s *AccessTokenService) GetAccessToken(c *gin.Context, companyID uint) (*model.AccessToken, error) {
+func (s *AccessTokenService) GetAccessToken(c *gin.Context, companyID uint, tokenType string) (*model.AccessToken, error) {
 	// 先从数据库查询
 	var accessToken model.AccessToken
-	result := s.DB.Where("company_id = ? AND status = 1", companyID).First(&accessToken)
+	result := s.DB.Where("company_id = ? AND token_type = ? AND status = 1", companyID, tokenType).First(&accessToken)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, errors.New("未找到有效的AccessToken配置")
@@ -44,28 +46,26 @@ func (s *AccessTokenService) GetAccessToken(c *gin.Context, companyID uint) (*mo
 
 	// 检查是否过期，过期则刷新
 	if time.Now().After(accessToken.ExpiresAt) {
-		return s.RefreshAccessToken(c, companyID)
+		return s.refresh(context.Background(), companyID, tokenType, model.TokenRefreshTriggerExpired)
 	}
 
 	return &accessToken, nil
 }
 
 // RefreshAccessToken 刷新AccessToken
-func (s *AccessTokenService) RefreshAccessToken(c *gin.Context, companyID uint) (*model.AccessToken, error) {
-	// 获取AccessToken配置
-	var accessToken model.AccessToken
-	result := s.DB.Where("company_id = ? AND status = 1", companyID).First(&accessToken)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("未找到有效的AccessToken配置")
-		}
-		return nil, result.Error
-	}
+func (s *AccessTokenService) RefreshAccessToken(c *gin.Context, companyID uint, tokenType string) (*model.AccessToken, error) {
+	return s.refresh(context.Background(), companyID, tokenType, model.TokenRefreshTriggerManual)
+}
+
+// doRefresh 调用钉钉接口刷新AccessToken并保存，同时记录刷新结果
+func (s *AccessTokenService) doRefresh(accessToken *model.AccessToken, trigger string) error {
+	started := time.Now()
 
 	// 调用钉钉API刷新AccessToken
-	newToken, expiresIn, err := s.getAccessTokenFromDingTalk(accessToken.AppKey, accessToken.AppSecret)
+	newToken, expiresIn, err := s.getAccessTokenFromDingTalk(accessToken.TokenType, accessToken.AppKey, string(accessToken.AppSecret))
 	if err != nil {
-		return nil, err
+		s.recordRefresh(accessToken, trigger, started, err)
+		return err
 	}
 
 	// 更新AccessToken信息
@@ -76,16 +76,24 @@ func (s *AccessTokenService) RefreshAccessToken(c *gin.Context, companyID uint)
 	accessToken.RefreshAt = now
 
 	// 保存到数据库
-	result = s.DB.Save(&accessToken)
+	result := s.DB.Model(&model.AccessToken{}).Where("id = ?", accessToken.ID).Updates(map[string]interface{}{
+		"access_token": accessToken.AccessToken,
+		"expires_in":   accessToken.ExpiresIn,
+		"expires_at":   accessToken.ExpiresAt,
+		"refresh_at":   accessToken.RefreshAt,
+	})
 	if result.Error != nil {
-		return nil, result.Error
+		s.recordRefresh(accessToken, trigger, started, result.Error)
+		return result.Error
 	}
 
 	// 保存到Redis
-	redisKey := fmt.Sprintf("access_token:%d", companyID)
-	s.Redis.Set(context.Background(), redisKey, newToken, time.Duration(expiresIn)*time.Second)
+	if s.Redis != nil {
+		s.Redis.Set(context.Background(), accessTokenCacheKey(accessToken.CompanyID, accessToken.TokenType), newToken, time.Duration(expiresIn)*time.Second)
+	}
 
-	return &accessToken, nil
+	s.recordRefresh(accessToken, trigger, started, nil)
+	return nil
 }
 
 // CreateAccessToken 创建AccessToken配置
@@ -99,17 +107,29 @@ func (s *AccessTokenService) CreateAccessToken(c *gin.Context, req *model.Access
 		return nil, err
 	}
 
-	// 检查是否已存在AccessToken配置
+	if req.AppSecret.IsMasked() {
+		return nil, errors.New("AppSecret不能为空")
+	}
+
+	// 校验令牌类型
+	if req.TokenType == "" {
+		req.TokenType = model.AccessTokenTypeLegacy
+	}
+	if err := validateTokenType(req.TokenType); err != nil {
+		return nil, err
+	}
+
+	// 检查是否已存在同类型的AccessToken配置
 	var existingToken model.AccessToken
-	result := s.DB.Where("company_id = ?", req.CompanyID).First(&existingToken)
+	result := s.DB.Where("company_id = ? AND token_type = ?", req.CompanyID, req.TokenType).First(&existingToken)
 	if result.Error == nil {
-		return nil, errors.New("该公司已存在AccessToken配置")
+		return nil, errors.New("该公司已存在该类型的AccessToken配置")
 	} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return nil, result.Error
 	}
 
 	// 初始获取AccessToken
-	accessToken, expiresIn, err := s.getAccessTokenFromDingTalk(req.AppKey, req.AppSecret)
+	accessToken, expiresIn, err := s.getAccessTokenFromDingTalk(req.TokenType, req.AppKey, string(req.AppSecret))
 	if err != nil {
 		return nil, err
 	}
@@ -128,8 +148,9 @@ func (s *AccessTokenService) CreateAccessToken(c *gin.Context, req *model.Access
 	}
 
 	// 保存到Redis
-	redisKey := fmt.Sprintf("access_token:%d", req.CompanyID)
-	s.Redis.Set(context.Background(), redisKey, accessToken, time.Duration(expiresIn)*time.Second)
+	if s.Redis != nil {
+		s.Redis.Set(context.Background(), accessTokenCacheKey(req.CompanyID, req.TokenType), accessToken, time.Duration(expiresIn)*time.Second)
+	}
 
 	return req, nil
 }
@@ -148,12 +169,16 @@ func (s *AccessTokenService) UpdateAccessToken(c *gin.Context, id uint, req *mod
 
 	// 更新字段
 	accessToken.AppKey = req.AppKey
-	accessToken.AppSecret = req.AppSecret
+	// 提交脱敏后的值表示不修改 AppSecret
+	if !req.AppSecret.IsMasked() {
+		accessToken.AppSecret = req.AppSecret
+	}
+	accessToken.AgentID = req.AgentID
 	accessToken.Status = req.Status
 
 	// 如果状态为有效，重新获取AccessToken
 	if req.Status == 1 {
-		newToken, expiresIn, err := s.getAccessTokenFromDingTalk(req.AppKey, req.AppSecret)
+		newToken, expiresIn, err := s.getAccessTokenFromDingTalk(accessToken.TokenType, accessToken.AppKey, string(accessToken.AppSecret))
 		if err != nil {
 			return nil, err
 		}
@@ -171,13 +196,8 @@ func (s *AccessTokenService) UpdateAccessToken(c *gin.Context, id uint, req *mod
 		return nil, result.Error
 	}
 
-	// 更新或删除Redis
-	redisKey := fmt.Sprintf("access_token:%d", accessToken.CompanyID)
-	if accessToken.Status == 1 {
-		s.Redis.Set(context.Background(), redisKey, accessToken.AccessToken, time.Until(accessToken.ExpiresAt))
-	} else {
-		s.Redis.Del(context.Background(), redisKey)
-	}
+	// 删除Redis缓存，下次获取时从数据库回填
+	invalidateAccessToken(accessToken.CompanyID, accessToken.TokenType)
 
 	return &accessToken, nil
 }
@@ -194,16 +214,15 @@ func (s *AccessTokenService) DeleteAccessToken(c *gin.Context, id uint) error {
 		return result.Error
 	}
 
-	// 删除Redis中的AccessToken
-	redisKey := fmt.Sprintf("access_token:%d", accessToken.CompanyID)
-	s.Redis.Del(context.Background(), redisKey)
-
 	// 软删除
 	result = s.DB.Delete(&accessToken)
 	if result.Error != nil {
 		return result.Error
 	}
 
+	// 删除Redis中的AccessToken
+	invalidateAccessToken(accessToken.CompanyID, accessToken.TokenType)
+
 	return nil
 }
 
@@ -231,15 +250,25 @@ func (s *AccessTokenService) GetAccessTokenList(c *gin.Context, page, pageSize i
 }
 
 // TestAccessToken 测试AccessToken有效性
-func (s *AccessTokenService) TestAccessToken(c *gin.Context, companyID uint) error {
+func (s *AccessTokenService) TestAccessToken(c *gin.Context, companyID uint, tokenType string) error {
 	// 获取AccessToken
-	accessToken, err := s.GetAccessToken(c, companyID)
+	accessToken, err := NewTokenProvider().Token(context.Background(), companyID, tokenType)
 	if err != nil {
 		return err
 	}
 
+	// 新版接口没有通用的校验接口，使用应用凭证重新调用 oauth2/accessToken 校验应用凭证有效
+	if tokenType == model.AccessTokenTypeOAuth2 {
+		record, err := s.GetAccessToken(c, companyID, tokenType)
+		if err != nil {
+			return err
+		}
+		_, _, err = s.getOAuth2AccessToken(record.AppKey, string(record.AppSecret))
+		return err
+	}
+
 	// 调用钉钉API测试
-	url := fmt.Sprintf("https://oapi.dingtalk.com/topapi/v2/user/get?access_token=%s", accessToken.AccessToken)
+	url := dingTalkOAPIURL(fmt.Sprintf("/topapi/v2/user/get?access_token=%s", accessToken))
 	resp, err := http.Get(url)
 	if err != nil {
 		return err
@@ -253,9 +282,27 @@ func (s *AccessTokenService) TestAccessToken(c *gin.Context, companyID uint) err
 	return nil
 }
 
-// getAccessTokenFromDingTalk 从钉钉API获取AccessToken
-func (s *AccessTokenService) getAccessTokenFromDingTalk(appKey, appSecret string) (string, int, error) {
-	url := fmt.Sprintf("https://oapi.dingtalk.com/gettoken?appkey=%s&appsecret=%s", appKey, appSecret)
+// validateTokenType 校验AccessToken类型
+func validateTokenType(tokenType string) error {
+	switch tokenType {
+	case model.AccessTokenTypeLegacy, model.AccessTokenTypeOAuth2:
+		return nil
+	default:
+		return fmt.Errorf("不支持的AccessToken类型: %s", tokenType)
+	}
+}
+
+// getAccessTokenFromDingTalk 按令牌类型从钉钉API获取AccessToken
+func (s *AccessTokenService) getAccessTokenFromDingTalk(tokenType, appKey, appSecret string) (string, int, error) {
+	if tokenType == model.AccessTokenTypeOAuth2 {
+		return s.getOAuth2AccessToken(appKey, appSecret)
+	}
+	return s.getLegacyAccessToken(appKey, appSecret)
+}
+
+// getLegacyAccessToken 从旧版 gettoken 接口获取AccessToken
+func (s *AccessTokenService) getLegacyAccessToken(appKey, appSecret string) (string, int, error) {
+	url := dingTalkOAPIURL(fmt.Sprintf("/gettoken?appkey=%s&appsecret=%s", appKey, appSecret))
 	resp, err := http.Get(url)
 	if err != nil {
 		return "", 0, err
@@ -288,3 +335,45 @@ func (s *AccessTokenService) getAccessTokenFromDingTalk(appKey, appSecret string
 
 	return accessToken, int(expiresIn), nil
 }
+
+// getOAuth2AccessToken 从新版 oauth2/accessToken 接口获取AccessToken
+func (s *AccessTokenService) getOAuth2AccessToken(appKey, appSecret string) (string, int, error) {
+	body, err := json.Marshal(map[string]string{
+		"appKey":    appKey,
+		"appSecret": appSecret,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	resp, err := http.Post(dingTalkAPIURL("/v1.0/oauth2/accessToken"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	// 解析响应，失败时返回 code 和 message
+	var result struct {
+		AccessToken string  `json:"accessToken"`
+		ExpireIn    float64 `json:"expireIn"`
+		Code        string  `json:"code"`
+		Message     string  `json:"message"`
+	}
+	if err := utils.ParseJSON(resp.Body, &result); err != nil {
+		return "", 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		if result.Message == "" {
+			result.Message = fmt.Sprintf("HTTP状态码：%d", resp.StatusCode)
+		}
+		return "", 0, fmt.Errorf("获取AccessToken失败：%s", result.Message)
+	}
+
+	expiresIn := int(result.ExpireIn)
+	if expiresIn <= 0 {
+		expiresIn = 7200 // 默认2小时
+	}
+
+	return result.AccessToken, expiresIn, nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// tokenRefreshLockTTL 刷新 AccessToken 的分布式锁有效期
+const tokenRefreshLockTTL = 30 * time.Second
+
+// tokenRefreshWait 其他实例正在刷新时等待结果的最长时间
+const tokenRefreshWait = 5 * time.Second
+
+// tokenRefreshGroup 合并本实例内同一公司同一类型的并发刷新
+var tokenRefreshGroup singleflight.Group
+
+// tokenRefreshInterval 后台检查 AccessToken 的间隔
+func tokenRefreshInterval() time.Duration {
+	if config.GlobalConfig != nil && config.GlobalConfig.DingTalk.TokenRefreshInterval > 0 {
+		return config.GlobalConfig.DingTalk.TokenRefreshInterval
+	}
+	return time.Minute
+}
+
+// tokenRefreshPercent 在有效期过去百分之多少时提前刷新
+func tokenRefreshPercent() int {
+	if config.GlobalConfig != nil {
+		if percent := config.GlobalConfig.DingTalk.TokenRefreshPercent; percent > 0 && percent < 100 {
+			return percent
+		}
+	}
+	return 80
+}
+
+// tokenNextRefreshAt 返回 AccessToken 应当提前刷新的时间
+func tokenNextRefreshAt(accessToken *model.AccessToken) time.Time {
+	if accessToken.RefreshAt.IsZero() {
+		return accessToken.ExpiresAt
+	}
+	lifetime := time.Duration(accessToken.ExpiresIn) * time.Second
+	return accessToken.RefreshAt.Add(lifetime * time.Duration(tokenRefreshPercent()) / 100)
+}
+
+// tokenRefreshDue AccessToken 是否需要刷新
+func tokenRefreshDue(accessToken *model.AccessToken, now time.Time) bool {
+	if accessToken.AccessToken == "" || !now.Before(accessToken.ExpiresAt) {
+		return true
+	}
+	return !now.Before(tokenNextRefreshAt(accessToken))
+}
+
+// refresh 刷新公司指定类型的 AccessToken
+// 本实例内同一公司的并发刷新只请求一次钉钉，多实例之间由 Redis 锁保证同一时间只有一个实例刷新
+func (s *AccessTokenService) refresh(ctx context.Context, companyID uint, tokenType, trigger string) (*model.AccessToken, error) {
+	return s.refreshToken(ctx, companyID, tokenType, trigger, "")
+}
+
+// refreshRejected 钉钉接口拒绝 AccessToken 时刷新，数据库中的 AccessToken 已不是被拒绝的那个时直接返回
+func (s *AccessTokenService) refreshRejected(ctx context.Context, companyID uint, tokenType, rejected string) (*model.AccessToken, error) {
+	invalidateAccessToken(companyID, tokenType)
+	return s.refreshToken(ctx, companyID, tokenType, model.TokenRefreshTriggerRejected, rejected)
+}
+
+// refreshToken 合并同一公司同一类型同一触发方式的并发刷新
+// 合并的调用共用一次刷新，刷新不随发起者的 ctx 取消，以锁的有效期为超时
+func (s *AccessTokenService) refreshToken(ctx context.Context, companyID uint, tokenType, trigger, rejected string) (*model.AccessToken, error) {
+	key := tokenRefreshKey(companyID, tokenType, trigger, rejected)
+	value, err, _ := tokenRefreshGroup.Do(key, func() (interface{}, error) {
+		flightCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRefreshLockTTL)
+		defer cancel()
+		return s.refreshLocked(flightCtx, companyID, tokenType, trigger, rejected)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*model.AccessToken), nil
+}
+
+// tokenRefreshKey 返回合并刷新的键
+// 触发方式决定是否强制刷新，被拒绝的 AccessToken 不同时也不能共用结果，都需要区分
+func tokenRefreshKey(companyID uint, tokenType, trigger, rejected string) string {
+	return fmt.Sprintf("%d:%s:%s:%s", companyID, tokenType, trigger, rejected)
+}
+
+// refreshLocked 获取分布式锁后刷新，锁被其他实例持有时等待其刷新结果
+func (s *AccessTokenService) refreshLocked(ctx context.Context, companyID uint, tokenType, trigger, rejected string) (*model.AccessToken, error) {
+	lockKey := fmt.Sprintf("access_token:refresh_lock:%d:%s", companyID, tokenType)
+	locked, err := database.TryLock(ctx, lockKey, tokenRefreshLockTTL)
+	if err != nil {
+		// Redis 不可用时仍然刷新，最多多请求一次钉钉
+		logrus.Errorf("获取AccessToken刷新锁失败: %v", err)
+		locked = true
+	}
+	if !locked {
+		return s.waitForRefresh(ctx, companyID, tokenType, rejected)
+	}
+	defer database.Unlock(context.Background(), lockKey)
+
+	var accessToken model.AccessToken
+	if err := s.DB.Where("company_id = ? AND token_type = ? AND status = 1", companyID, tokenType).First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("未找到有效的AccessToken配置")
+		}
+		return nil, err
+	}
+
+	// 等锁期间其他实例可能已经刷新过
+	switch trigger {
+	case model.TokenRefreshTriggerManual:
+	case model.TokenRefreshTriggerRejected:
+		if accessToken.AccessToken != rejected {
+			return &accessToken, nil
+		}
+	default:
+		if !tokenRefreshDue(&accessToken, time.Now()) {
+			return &accessToken, nil
+		}
+	}
+
+	if err := s.doRefresh(&accessToken, trigger); err != nil {
+		return nil, err
+	}
+	return &accessToken, nil
+}
+
+// waitForRefresh 等待其他实例刷新完成，返回未过期且不是被拒绝的 AccessToken
+func (s *AccessTokenService) waitForRefresh(ctx context.Context, companyID uint, tokenType, rejected string) (*model.AccessToken, error) {
+	deadline := time.Now().Add(tokenRefreshWait)
+	for {
+		var accessToken model.AccessToken
+		if err := s.DB.Where("company_id = ? AND token_type = ? AND status = 1", companyID, tokenType).First(&accessToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("未找到有效的AccessToken配置")
+			}
+			return nil, err
+		}
+		if accessToken.AccessToken != "" && accessToken.AccessToken != rejected && time.Now().Before(accessToken.ExpiresAt) {
+			return &accessToken, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("AccessToken正在刷新，请稍后重试")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// recordRefresh 记录刷新结果，更新连续失败次数
+func (s *AccessTokenService) recordRefresh(accessToken *model.AccessToken, trigger string, started time.Time, refreshErr error) {
+	instance, _ := os.Hostname()
+	log := model.AccessTokenRefreshLog{
+		CompanyID: accessToken.CompanyID,
+		TokenType: accessToken.TokenType,
+		Trigger:   trigger,
+		Status:    model.TokenRefreshStatusSuccess,
+		Duration:  time.Since(started).Milliseconds(),
+		Instance:  instance,
+	}
+	updates := map[string]interface{}{
+		"last_refresh_status": model.TokenRefreshStatusSuccess,
+		"last_refresh_error":  "",
+		"refresh_failures":    0,
+	}
+
+	if refreshErr != nil {
+		log.Status = model.TokenRefreshStatusFailed
+		log.ErrorMsg = refreshErr.Error()
+		updates["last_refresh_status"] = model.TokenRefreshStatusFailed
+		updates["last_refresh_error"] = refreshErr.Error()
+		updates["refresh_failures"] = gorm.Expr("refresh_failures + 1")
+		logrus.Errorf("刷新AccessToken失败，公司ID: %d, 类型: %s, 触发方式: %s, 错误: %v", accessToken.CompanyID, accessToken.TokenType, trigger, refreshErr)
+
+		// 只在从成功变为失败时通知，避免持续失败时重复通知
+		if accessToken.LastRefreshStatus != model.TokenRefreshStatusFailed {
+			notifyAsync(&Notification{
+				CompanyID: accessToken.CompanyID,
+				Event:     model.MessageEventTokenRefreshFailed,
+				Data: map[string]interface{}{
+					"CompanyID": accessToken.CompanyID,
+					"TokenType": accessToken.TokenType,
+					"Trigger":   trigger,
+					"ErrorMsg":  refreshErr.Error(),
+				},
+			})
+		}
+	} else {
+		log.ExpiresAt = &accessToken.ExpiresAt
+		logrus.Infof("刷新AccessToken成功，公司ID: %d, 类型: %s, 触发方式: %s, 过期时间: %s", accessToken.CompanyID, accessToken.TokenType, trigger, accessToken.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if err := s.DB.Create(&log).Error; err != nil {
+		logrus.Errorf("保存AccessToken刷新记录失败: %v", err)
+	}
+	if err := s.DB.Model(&model.AccessToken{}).Where("id = ?", accessToken.ID).Updates(updates).Error; err != nil {
+		logrus.Errorf("更新AccessToken刷新状态失败: %v", err)
+	}
+}
+
+// AccessTokenRefresher 后台提前刷新所有启用公司的 AccessToken
+type AccessTokenRefresher struct {
+	service  *AccessTokenService
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewAccessTokenRefresher 创建 AccessToken 后台刷新任务
+func NewAccessTokenRefresher() *AccessTokenRefresher {
+	return &AccessTokenRefresher{
+		service:  NewAccessTokenService(),
+		interval: tokenRefreshInterval(),
+	}
+}
+
+// Start 启动后台刷新
+func (r *AccessTokenRefresher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.RefreshDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	logrus.Infof("AccessToken后台刷新已启动，间隔: %s, 提前刷新比例: %d%%", r.interval, tokenRefreshPercent())
+}
+
+// Stop 停止后台刷新
+func (r *AccessTokenRefresher) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// RefreshDue 刷新所有到期的 AccessToken，返回成功刷新的数量
+func (r *AccessTokenRefresher) RefreshDue(ctx context.Context) int {
+	var accessTokens []model.AccessToken
+	if err := r.service.DB.
+		Joins("JOIN companies ON companies.id = access_token.company_id AND companies.status = 1 AND companies.deleted_at IS NULL").
+		Where("access_token.status = 1").
+		Find(&accessTokens).Error; err != nil {
+		logrus.Errorf("查询待刷新的AccessToken失败: %v", err)
+		return 0
+	}
+
+	refreshed := 0
+	now := time.Now()
+	for i := range accessTokens {
+		if ctx.Err() != nil {
+			break
+		}
+		if !tokenRefreshDue(&accessTokens[i], now) {
+			continue
+		}
+		if _, err := r.service.refresh(ctx, accessTokens[i].CompanyID, accessTokens[i].TokenType, model.TokenRefreshTriggerScheduled); err == nil {
+			refreshed++
+		}
+	}
+	return refreshed
+}
+
+// 公司 AccessToken 健康状态
+const (
+	TokenHealthHealthy  = "healthy"  // 有效且最近一次刷新成功
+	TokenHealthFailing  = "failing"  // 仍有效但最近刷新失败
+	TokenHealthExpired  = "expired"  // 已过期
+	TokenHealthDisabled = "disabled" // 配置已停用
+)
+
+// TokenHealth 公司 AccessToken 的健康状态
+type TokenHealth struct {
+	CompanyID         uint      `json:"company_id"`
+	CompanyName       string    `json:"company_name"`
+	TokenType         string    `json:"token_type"` // legacy, oauth2
+	State             string    `json:"state"`      // healthy, failing, expired, disabled
+	ExpiresAt         time.Time `json:"expires_at"`
+	RefreshAt         time.Time `json:"refresh_at"`
+	NextRefreshAt     time.Time `json:"next_refresh_at"` // 计划提前刷新的时间
+	LastRefreshStatus string    `json:"last_refresh_status"`
+	LastRefreshError  string    `json:"last_refresh_error"`
+	RefreshFailures   int       `json:"refresh_failures"`
+}
+
+// GetTokenHealth 获取所有公司 AccessToken 的健康状态
+func (s *AccessTokenService) GetTokenHealth() ([]TokenHealth, error) {
+	var accessTokens []model.AccessToken
+	if err := s.DB.Preload("Company").Order("company_id, token_type").Find(&accessTokens).Error; err != nil {
+		logrus.Errorf("获取AccessToken列表失败: %v", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	health := make([]TokenHealth, 0, len(accessTokens))
+	for i := range accessTokens {
+		accessToken := &accessTokens[i]
+		item := TokenHealth{
+			CompanyID:         accessToken.CompanyID,
+			CompanyName:       accessToken.Company.Name,
+			TokenType:         accessToken.TokenType,
+			State:             TokenHealthHealthy,
+			ExpiresAt:         accessToken.ExpiresAt,
+			RefreshAt:         accessToken.RefreshAt,
+			NextRefreshAt:     tokenNextRefreshAt(accessToken),
+			LastRefreshStatus: accessToken.LastRefreshStatus,
+			LastRefreshError:  accessToken.LastRefreshError,
+			RefreshFailures:   accessToken.RefreshFailures,
+		}
+		switch {
+		case accessToken.Status != 1:
+			item.State = TokenHealthDisabled
+		case !now.Before(accessToken.ExpiresAt):
+			item.State = TokenHealthExpired
+		case accessToken.LastRefreshStatus == model.TokenRefreshStatusFailed:
+			item.State = TokenHealthFailing
+		}
+		health = append(health, item)
+	}
+
+	return health, nil
+}
+
+// GetRefreshLogs 分页获取 AccessToken 刷新记录，companyID 为 0 时返回所有公司
+func (s *AccessTokenService) GetRefreshLogs(companyID uint, page, pageSize int) ([]model.AccessTokenRefreshLog, int64, error) {
+	var logs []model.AccessTokenRefreshLog
+	var total int64
+
+	query := s.DB.Model(&model.AccessTokenRefreshLog{})
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取AccessToken刷新记录总数失败: %v", err)
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		logrus.Errorf("获取AccessToken刷新记录失败: %v", err)
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+// tokenRoundTripper 模拟钉钉 gettoken 接口
+type tokenRoundTripper struct {
+	calls int32
+}
+
+func (t *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	time.Sleep(50 * time.Millisecond)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"errcode":0,"access_token":"new-token","expires_in":7200}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	setupTestModels(&model.AccessToken{}, &model.AccessTokenRefreshLog{}, &model.Company{})
+	db := database.GetDB()
+
+	transport := &tokenRoundTripper{}
+	original := http.DefaultTransport
+	http.DefaultTransport = transport
+	defer func() { http.DefaultTransport = original }()
+
+	now := time.Now()
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	db.Create(&model.AccessToken{
+		CompanyID:   1,
+		AppKey:      "key",
+		AppSecret:   "secret",
+		AccessToken: "old-token",
+		ExpiresIn:   7200,
+		RefreshAt:   now.Add(-100 * time.Minute),
+		ExpiresAt:   now.Add(20 * time.Minute),
+		Status:      1,
+	})
+
+	t.Run("Due", func(t *testing.T) {
+		token := &model.AccessToken{AccessToken: "t", ExpiresIn: 7200, RefreshAt: now, ExpiresAt: now.Add(2 * time.Hour)}
+		if tokenRefreshDue(token, now.Add(90*time.Minute)) {
+			t.Error("Expected token not due before 80% of lifetime")
+		}
+		if !tokenRefreshDue(token, now.Add(97*time.Minute)) {
+			t.Error("Expected token due after 80% of lifetime")
+		}
+	})
+
+	t.Run("SingleFlight", func(t *testing.T) {
+		svc := NewAccessTokenService()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := svc.refresh(context.Background(), 1, model.AccessTokenTypeLegacy, model.TokenRefreshTriggerScheduled)
+				if err != nil || token.AccessToken != "new-token" {
+					t.Errorf("Expected refreshed token, got %v %v", token, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if calls := atomic.LoadInt32(&transport.calls); calls != 1 {
+			t.Errorf("Expected 1 gettoken call, got %d", calls)
+		}
+		var logs int64
+		db.Model(&model.AccessTokenRefreshLog{}).Where("company_id = ? AND status = ?", 1, model.TokenRefreshStatusSuccess).Count(&logs)
+		if logs != 1 {
+			t.Errorf("Expected 1 refresh log, got %d", logs)
+		}
+	})
+
+	t.Run("RejectedDuringScheduled", func(t *testing.T) {
+		// 定时刷新正在进行且判断无需刷新时，被拒绝的 AccessToken 仍然需要刷新，不能共用定时刷新的结果
+		svc := NewAccessTokenService()
+		var current model.AccessToken
+		db.Where("company_id = ?", 1).First(&current)
+
+		release := make(chan struct{})
+		started := make(chan struct{})
+		scheduled := make(chan *model.AccessToken, 1)
+		go func() {
+			value, _, _ := tokenRefreshGroup.Do(tokenRefreshKey(1, model.AccessTokenTypeLegacy, model.TokenRefreshTriggerScheduled, ""), func() (interface{}, error) {
+				close(started)
+				<-release
+				return svc.refreshLocked(context.Background(), 1, model.AccessTokenTypeLegacy, model.TokenRefreshTriggerScheduled, "")
+			})
+			token, _ := value.(*model.AccessToken)
+			scheduled <- token
+		}()
+		<-started
+
+		// 发起者的 ctx 已取消也不影响刷新
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		before := atomic.LoadInt32(&transport.calls)
+		type result struct {
+			token *model.AccessToken
+			err   error
+		}
+		rejected := make(chan result, 1)
+		go func() {
+			token, err := svc.refreshRejected(ctx, 1, model.AccessTokenTypeLegacy, current.AccessToken)
+			rejected <- result{token, err}
+		}()
+
+		var got result
+		select {
+		case got = <-rejected:
+			close(release)
+		case <-time.After(time.Second):
+			close(release)
+			got = <-rejected
+			t.Error("Expected rejected refresh not to wait for the scheduled refresh")
+		}
+		token, err := got.token, got.err
+		if err != nil {
+			t.Fatalf("refreshRejected failed: %v", err)
+		}
+		if calls := atomic.LoadInt32(&transport.calls) - before; calls != 1 {
+			t.Errorf("Expected rejected token refreshed, got %d gettoken calls", calls)
+		}
+		if token.RefreshAt.Equal(current.RefreshAt) {
+			t.Errorf("Expected a newly refreshed token, got %+v", token)
+		}
+		if <-scheduled == nil {
+			t.Error("Expected scheduled refresh to return the current token")
+		}
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		health, err := NewAccessTokenService().GetTokenHealth()
+		if err != nil {
+			t.Fatalf("GetTokenHealth failed: %v", err)
+		}
+		if len(health) != 1 || health[0].State != TokenHealthHealthy || health[0].CompanyName != "c1" {
+			t.Errorf("Unexpected health: %+v", health)
+		}
+	})
+}
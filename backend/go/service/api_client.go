@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/utils"
+)
+
+// maxResponseSize 单次响应体的大小上限（100MB）
+const maxResponseSize = 100 * 1024 * 1024
+
+// APIClient 按API配置发起HTTP请求
+type APIClient struct {
+	httpClient *http.Client
+	legacy     *LegacyClient
+	tokens     *TokenProvider
+}
+
+// APIResponse API调用结果
+type APIResponse struct {
+	URL        string                 // 实际请求的URL
+	StatusCode int                    // HTTP状态码
+	Status     string                 // HTTP状态描述
+	Body       []byte                 // 原始响应体
+	Data       map[string]interface{} // 解析后的响应，非JSON响应时为 {"raw_response": ...}
+	Duration   time.Duration          // 请求耗时
+}
+
+// NewAPIClient 创建API调用客户端
+func NewAPIClient() *APIClient {
+	return &APIClient{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		legacy: NewLegacyClient(),
+		tokens: NewTokenProvider(),
+	}
+}
+
+// Do 按API配置发送一次请求
+// GET/DELETE 请求的参数拼接到URL，其它请求以JSON格式放在请求体中，旧版接口通过旧版API服务发送
+func (c *APIClient) Do(ctx context.Context, apiConfig *model.APIConfig, params map[string]interface{}, headers map[string]string) (*APIResponse, error) {
+	requestURL := buildAPIURL(apiConfig)
+	method := strings.ToUpper(apiConfig.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var jsonParams []byte
+	if method == http.MethodGet || method == http.MethodDelete {
+		if len(params) > 0 {
+			query := url.Values{}
+			for k, v := range params {
+				query.Set(k, utils.ToString(v))
+			}
+			if strings.Contains(requestURL, "?") {
+				requestURL += "&" + query.Encode()
+			} else {
+				requestURL += "?" + query.Encode()
+			}
+		}
+	} else {
+		var err error
+		jsonParams, err = json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("参数转换为JSON失败: %v", err)
+		}
+	}
+
+	newRequest := func() (*http.Request, error) {
+		var body io.Reader
+		if jsonParams != nil {
+			body = bytes.NewReader(jsonParams)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+		if err != nil {
+			return nil, fmt.Errorf("创建请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	}
+
+	// 附加AccessToken发送请求，AccessToken失效时刷新后重试一次
+	client := c.httpClient
+	if apiConfig.Type == model.APITypeLegacy {
+		client = legacyHTTPClient(c.legacy, apiConfig)
+	}
+	startTime := time.Now()
+	resp, respBody, err := c.tokens.Do(ctx, client, apiConfig, newRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var respData map[string]interface{}
+	if err := json.Unmarshal(respBody, &respData); err != nil {
+		// 如果响应不是JSON格式，直接返回原始响应
+		respData = map[string]interface{}{
+			"raw_response": string(respBody),
+		}
+	}
+
+	return &APIResponse{
+		URL:        requestURL,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       respBody,
+		Data:       respData,
+		Duration:   time.Since(startTime),
+	}, nil
+}
+
+// CheckError 检查HTTP状态码及钉钉业务错误码
+// 旧版接口通过 errcode/errmsg 返回错误，新版接口通过 HTTP 状态码和 code/message 返回错误
+func (r *APIResponse) CheckError() error {
+	if errcode, ok := r.Data["errcode"].(float64); ok && errcode != 0 {
+		errmsg, _ := r.Data["errmsg"].(string)
+		return fmt.Errorf("接口返回错误: errcode=%d, errmsg=%s", int(errcode), errmsg)
+	}
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		if message, ok := r.Data["message"].(string); ok && message != "" {
+			return fmt.Errorf("接口返回错误: HTTP %d, %s", r.StatusCode, message)
+		}
+		return fmt.Errorf("接口返回错误: HTTP %d", r.StatusCode)
+	}
+	return nil
+}
+
+// buildAPIURL 拼接 BaseURL 与 Path
+func buildAPIURL(apiConfig *model.APIConfig) string {
+	baseURL := strings.TrimSuffix(apiConfig.BaseURL, "/")
+	path := strings.TrimPrefix(apiConfig.Path, "/")
+	return baseURL + "/" + path
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -102,6 +104,26 @@ func (s *APIConfigService) Create(apiConfig *model.APIConfig) error {
 		return err
 	}
 	
+	// 校验增量同步配置
+	if _, err := parseAPIIncremental(apiConfig.Incremental); err != nil {
+		return err
+	}
+	
+	// 校验映射配置
+	if _, err := parseAPIMapping(apiConfig.Mapping); err != nil {
+		return err
+	}
+	
+	// 校验鉴权方式
+	if err := validateAuthMode(apiConfig.AuthMode); err != nil {
+		return err
+	}
+	
+	// 校验限流配置
+	if err := validateRateLimit(apiConfig.QPS, apiConfig.Burst); err != nil {
+		return err
+	}
+	
 	// 设置默认值
 	if apiConfig.Status == 0 {
 		apiConfig.Status = 1
@@ -149,6 +171,26 @@ func (s *APIConfigService) Update(apiConfig *model.APIConfig) error {
 		return err
 	}
 	
+	// 校验增量同步配置
+	if _, err := parseAPIIncremental(apiConfig.Incremental); err != nil {
+		return err
+	}
+	
+	// 校验映射配置
+	if _, err := parseAPIMapping(apiConfig.Mapping); err != nil {
+		return err
+	}
+	
+	// 校验鉴权方式
+	if err := validateAuthMode(apiConfig.AuthMode); err != nil {
+		return err
+	}
+	
+	// 校验限流配置
+	if err := validateRateLimit(apiConfig.QPS, apiConfig.Burst); err != nil {
+		return err
+	}
+	
 	// 更新API配置
 	if err := db.Save(apiConfig).Error; err != nil {
 		logrus.Errorf("更新API配置失败: %v", err)
@@ -215,48 +257,43 @@ func (s *APIConfigService) Test(apiConfig *model.APIConfig) (map[string]interfac
 		url = strings.TrimSuffix(url, "&")
 	}
 	
-	// 创建请求
-	var req *http.Request
-	var err error
-	
-	// 记录开始时间
-	startTime := time.Now()
-	
-	if apiConfig.Method == "GET" {
-		req, err = http.NewRequest(apiConfig.Method, url, nil)
-	} else {
-		// 对于非GET请求，将参数转换为JSON
-		jsonParams, err := json.Marshal(params)
+	// 对于非GET请求，将参数转换为JSON
+	var jsonParams []byte
+	if apiConfig.Method != "GET" {
+		var err error
+		jsonParams, err = json.Marshal(params)
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("参数转换为JSON失败: %v", err))
 		}
-		req, err = http.NewRequest(apiConfig.Method, url, strings.NewReader(string(jsonParams)))
-	}
-	
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("创建请求失败: %v", err))
 	}
 	
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	// 创建请求，AccessToken失效重试时需要重新创建
+	newRequest := func() (*http.Request, error) {
+		var body io.Reader
+		if jsonParams != nil {
+			body = bytes.NewReader(jsonParams)
+		}
+		req, err := http.NewRequest(apiConfig.Method, url, body)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("创建请求失败: %v", err))
+		}
+		
+		// 设置请求头
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
 	}
 	
-	// 发送请求
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("发送请求失败: %v", err))
-	}
-	defer resp.Body.Close()
+	// 记录开始时间
+	startTime := time.Now()
 	
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
+	// 发送请求，自动附加AccessToken，旧版接口通过旧版API服务发送
+	client := newAPIHTTPClient(apiConfig, 30*time.Second)
+	resp, respBody, err := NewTokenProvider().Do(context.Background(), client, apiConfig, newRequest)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("读取响应失败: %v", err))
+		return nil, err
 	}
 	
 	// 解析响应
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -128,7 +130,7 @@ func (s *APITestService) RunTestCase(userID uint, testCase *model.APITestCase) (
 	}
 
 	// 4. 构建 HTTP 请求
-	var bodyReader io.Reader
+	var bodyBytes []byte
 	method := strings.ToUpper(apiConfig.Method)
 	if method == "" {
 		method = "GET"
@@ -147,28 +149,35 @@ func (s *APITestService) RunTestCase(userID uint, testCase *model.APITestCase) (
 			fullURL = strings.TrimSuffix(fullURL, "&")
 		}
 	} else {
-		jsonBytes, _ := json.Marshal(params)
-		bodyReader = strings.NewReader(string(jsonBytes))
+		bodyBytes, _ = json.Marshal(params)
 	}
 
-	req, err := http.NewRequest(method, fullURL, bodyReader)
-	if err != nil {
-		logrus.Errorf("创建请求失败: %v", err)
-		return nil, err
-	}
+	// AccessToken失效重试时需要重新创建请求
+	newRequest := func() (*http.Request, error) {
+		var bodyReader io.Reader
+		if bodyBytes != nil {
+			bodyReader = bytes.NewReader(bodyBytes)
+		}
+		req, err := http.NewRequest(method, fullURL, bodyReader)
+		if err != nil {
+			logrus.Errorf("创建请求失败: %v", err)
+			return nil, err
+		}
 
-	// 设置请求头
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-	if method != "GET" && req.Header.Get("Content-Type") == "" {
-		req.Header.Set("Content-Type", "application/json")
+		// 设置请求头
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if method != "GET" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
 	}
 
-	// 5. 执行请求并计时
-	client := &http.Client{Timeout: 30 * time.Second}
+	// 5. 执行请求并计时，自动附加AccessToken，旧版接口通过旧版API服务发送
+	client := newAPIHTTPClient(&apiConfig, 30*time.Second)
 	startTime := time.Now()
-	resp, err := client.Do(req)
+	resp, respBody, err := NewTokenProvider().Do(context.Background(), client, &apiConfig, newRequest)
 	duration := time.Since(startTime).Milliseconds()
 
 	// 6. 构造历史记录
@@ -189,10 +198,8 @@ func (s *APITestService) RunTestCase(userID uint, testCase *model.APITestCase) (
 		testHistory.Status = "failed"
 		testHistory.ErrorMessage = err.Error()
 	} else {
-		defer resp.Body.Close()
 		testHistory.StatusCode = resp.StatusCode
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		testHistory.ActualResult = string(bodyBytes)
+		testHistory.ActualResult = string(respBody)
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			testHistory.Status = "success"
 		} else {
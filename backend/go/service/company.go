@@ -55,6 +55,11 @@ func (s *CompanyService) List(page, pageSize int, name, code string, companyType
 func (s *CompanyService) Create(company *model.Company) error {
 	db := database.GetDB()
 	
+	// 校验默认限流配置
+	if err := validateRateLimit(company.APIQPS, company.APIBurst); err != nil {
+		return err
+	}
+	
 	if err := db.Create(company).Error; err != nil {
 		logrus.Errorf("创建集团公司失败: %v", err)
 		return err
@@ -80,6 +85,11 @@ func (s *CompanyService) Get(id uint) (*model.Company, error) {
 func (s *CompanyService) Update(company *model.Company) error {
 	db := database.GetDB()
 	
+	// 校验默认限流配置
+	if err := validateRateLimit(company.APIQPS, company.APIBurst); err != nil {
+		return err
+	}
+	
 	if err := db.Save(company).Error; err != nil {
 		logrus.Errorf("更新集团公司失败: %v", err)
 		return err
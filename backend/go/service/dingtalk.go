@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+// 钉钉接口默认地址
+const (
+	defaultDingTalkOAPIBaseURL = "https://oapi.dingtalk.com"
+	defaultDingTalkAPIBaseURL  = "https://api.dingtalk.com"
+)
+
+// dingTalkOAPIURL 返回旧版接口的完整地址，path 以 / 开头
+func dingTalkOAPIURL(path string) string {
+	baseURL := defaultDingTalkOAPIBaseURL
+	if config.GlobalConfig != nil && config.GlobalConfig.DingTalk.OAPIBaseURL != "" {
+		baseURL = config.GlobalConfig.DingTalk.OAPIBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+// dingTalkAPIURL 返回新版接口的完整地址，path 以 / 开头
+func dingTalkAPIURL(path string) string {
+	baseURL := defaultDingTalkAPIBaseURL
+	if config.GlobalConfig != nil && config.GlobalConfig.DingTalk.APIBaseURL != "" {
+		baseURL = config.GlobalConfig.DingTalk.APIBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+// DingTalkError 钉钉接口返回的业务错误
+type DingTalkError struct {
+	Op      string // 调用的操作，如 获取access_token
+	Errcode int
+	Errmsg  string
+}
+
+func (e *DingTalkError) Error() string {
+	return fmt.Sprintf("%s失败: %s", e.Op, e.Errmsg)
+}
+
+// callOAPI 使用公司的旧版 AccessToken 以 POST JSON 调用旧版接口，并将响应解析到 result
+// errcode 非 0 时返回 *DingTalkError，op 用于错误信息，如 获取子部门列表
+func callOAPI(ctx context.Context, tokens *TokenProvider, companyID uint, op, path string, payload, result interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	apiConfig := &model.APIConfig{CompanyID: companyID, Type: model.APITypeLegacy, AuthMode: model.AuthModeQuery}
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, dingTalkOAPIURL(path), bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	_, body, err := tokens.Do(ctx, client, apiConfig, newRequest)
+	if err != nil {
+		return err
+	}
+
+	var status struct {
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("解析%s响应失败: %v", op, err)
+	}
+	if status.Errcode != 0 {
+		return &DingTalkError{Op: op, Errcode: status.Errcode, Errmsg: status.Errmsg}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("解析%s响应失败: %v", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// callbackAESKeyLength 钉钉事件订阅的 aes_key 长度，补齐 = 后 base64 解码为 32 字节
+const callbackAESKeyLength = 43
+
+// callbackAck 处理成功后需要加密返回给钉钉的内容
+const callbackAck = "success"
+
+// callbackDispatchTimeout 单个事件所有处理器的执行超时
+const callbackDispatchTimeout = 10 * time.Minute
+
+// callbackEventTypeAll 注册时使用该事件类型表示处理所有事件
+const callbackEventTypeAll = "*"
+
+// 接收回调时返回给调用方的错误
+var (
+	ErrCallbackNotConfigured = errors.New("未配置事件订阅")
+	ErrCallbackSignature     = errors.New("回调签名校验失败")
+)
+
+// orgCallbackEventTypes 通讯录变更事件，收到后同步组织架构
+var orgCallbackEventTypes = []string{
+	"user_add_org", "user_modify_org", "user_leave_org", "user_active_org",
+	"org_dept_create", "org_dept_modify", "org_dept_remove",
+}
+
+// decodeCallbackAESKey 解码事件订阅的 aes_key
+func decodeCallbackAESKey(encodingAESKey string) ([]byte, error) {
+	if len(encodingAESKey) != callbackAESKeyLength {
+		return nil, fmt.Errorf("事件订阅的 aes_key 长度应为 %d 位", callbackAESKeyLength)
+	}
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("事件订阅的 aes_key 格式错误: %v", err)
+	}
+	return key, nil
+}
+
+// callbackCrypto 钉钉事件订阅的签名和加解密
+// 明文格式为 16字节随机数 + 4字节消息长度（大端） + 消息 + ownerKey，使用 AES-256-CBC 加密，IV 为密钥前 16 字节
+type callbackCrypto struct {
+	token    string
+	key      []byte
+	ownerKey string // 企业内部应用为 AppKey
+}
+
+// newCallbackCrypto 创建回调加解密器
+func newCallbackCrypto(token, encodingAESKey, ownerKey string) (*callbackCrypto, error) {
+	key, err := decodeCallbackAESKey(encodingAESKey)
+	if err != nil {
+		return nil, err
+	}
+	return &callbackCrypto{token: token, key: key, ownerKey: ownerKey}, nil
+}
+
+// signature 计算签名：token、时间戳、随机串和密文按字典序排序后拼接做 sha1
+func (c *callbackCrypto) signature(timestamp, nonce, encrypt string) string {
+	parts := []string{c.token, timestamp, nonce, encrypt}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify 校验签名
+func (c *callbackCrypto) verify(signature, timestamp, nonce, encrypt string) bool {
+	expected := c.signature(timestamp, nonce, encrypt)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
+
+// encrypt 加密消息
+func (c *callbackCrypto) encrypt(message string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(message)))
+
+	plaintext := bytes.Join([][]byte{random, length, []byte(message), []byte(c.ownerKey)}, nil)
+	padding := len(c.key) - len(plaintext)%len(c.key)
+	plaintext = append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, c.key[:aes.BlockSize]).CryptBlocks(ciphertext, plaintext)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decrypt 解密消息并校验 ownerKey
+func (c *callbackCrypto) decrypt(encrypt string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypt)
+	if err != nil {
+		return "", fmt.Errorf("回调密文格式错误: %v", err)
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return "", errors.New("回调密文长度错误")
+	}
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, c.key[:aes.BlockSize]).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding < 1 || padding > len(c.key) || padding > len(plaintext) {
+		return "", errors.New("回调密文填充错误")
+	}
+	plaintext = plaintext[:len(plaintext)-padding]
+	if len(plaintext) < 20 {
+		return "", errors.New("回调密文长度错误")
+	}
+
+	length := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if length > len(plaintext)-20 {
+		return "", errors.New("回调消息长度错误")
+	}
+	if string(plaintext[20+length:]) != c.ownerKey {
+		return "", errors.New("回调消息不属于当前应用")
+	}
+	return string(plaintext[20 : 20+length]), nil
+}
+
+// CallbackHandler 回调事件处理器，payload 为解密后的事件内容
+type CallbackHandler func(ctx context.Context, event *model.CallbackEvent, payload map[string]interface{}) error
+
+// callbackHandlers 按事件类型注册的处理器
+var callbackHandlers = struct {
+	sync.RWMutex
+	handlers map[string][]CallbackHandler
+}{handlers: make(map[string][]CallbackHandler)}
+
+// RegisterCallbackHandler 注册回调事件处理器，eventType 为 * 时处理所有事件
+func RegisterCallbackHandler(eventType string, handler CallbackHandler) {
+	callbackHandlers.Lock()
+	defer callbackHandlers.Unlock()
+	callbackHandlers.handlers[eventType] = append(callbackHandlers.handlers[eventType], handler)
+}
+
+// RegisterDefaultCallbackHandlers 注册内置的事件处理器：
+// 通讯录变更时同步组织架构，任意事件触发订阅了该事件的定时下载任务
+func RegisterDefaultCallbackHandlers() {
+	for _, eventType := range orgCallbackEventTypes {
+		RegisterCallbackHandler(eventType, syncOrgOnCallback)
+	}
+	RegisterCallbackHandler(callbackEventTypeAll, func(ctx context.Context, event *model.CallbackEvent, payload map[string]interface{}) error {
+		return NewDownloadScheduleService().fireEvent(ctx, event.CompanyID, event.EventType)
+	})
+}
+
+// syncOrgOnCallback 收到通讯录变更事件时同步组织架构，已有同步在进行时跳过，由定时同步兜底
+func syncOrgOnCallback(ctx context.Context, event *model.CallbackEvent, payload map[string]interface{}) error {
+	_, err := NewOrgSyncService().Sync(ctx, event.CompanyID, model.OrgSyncTriggerEvent)
+	if errors.Is(err, errOrgSyncRunning) {
+		return nil
+	}
+	return err
+}
+
+// lookupCallbackHandlers 获取事件类型对应的处理器
+func lookupCallbackHandlers(eventType string) []CallbackHandler {
+	callbackHandlers.RLock()
+	defer callbackHandlers.RUnlock()
+
+	handlers := append([]CallbackHandler(nil), callbackHandlers.handlers[eventType]...)
+	return append(handlers, callbackHandlers.handlers[callbackEventTypeAll]...)
+}
+
+// CallbackReply 返回给钉钉的加密应答
+type CallbackReply struct {
+	MsgSignature string `json:"msg_signature"`
+	TimeStamp    string `json:"timeStamp"`
+	Nonce        string `json:"nonce"`
+	Encrypt      string `json:"encrypt"`
+}
+
+// CallbackService 钉钉事件订阅服务
+type CallbackService struct{}
+
+// NewCallbackService 创建钉钉事件订阅服务实例
+func NewCallbackService() *CallbackService {
+	return &CallbackService{}
+}
+
+// Receive 接收钉钉推送的回调：校验签名、解密、保存事件后返回加密的 success 应答
+// 事件在后台异步分发给处理器，钉钉要求在 1.5 秒内应答；重复推送的事件只保存一次
+func (s *CallbackService) Receive(companyID uint, signature, timestamp, nonce, encrypt string) (*CallbackReply, error) {
+	crypto, err := s.crypto(companyID)
+	if err != nil {
+		return nil, err
+	}
+	if !crypto.verify(signature, timestamp, nonce, encrypt) {
+		logrus.Warnf("回调签名校验失败，公司ID: %d", companyID)
+		return nil, ErrCallbackSignature
+	}
+
+	plaintext, err := crypto.decrypt(encrypt)
+	if err != nil {
+		logrus.Errorf("解密回调失败，公司ID: %d, 错误: %v", companyID, err)
+		return nil, err
+	}
+	var payload struct {
+		EventType string `json:"EventType"`
+	}
+	if err := json.Unmarshal([]byte(plaintext), &payload); err != nil {
+		logrus.Errorf("解析回调事件失败，公司ID: %d, 错误: %v", companyID, err)
+		return nil, fmt.Errorf("回调事件格式错误: %v", err)
+	}
+
+	hash := sha256.Sum256([]byte(plaintext))
+	event := &model.CallbackEvent{
+		CompanyID:   companyID,
+		EventType:   payload.EventType,
+		PayloadHash: hex.EncodeToString(hash[:]),
+		Payload:     plaintext,
+		Status:      model.CallbackEventStatusPending,
+	}
+	result := database.GetDB().Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		logrus.Errorf("保存回调事件失败: %v", result.Error)
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		logrus.Infof("收到回调事件，公司ID: %d, 事件类型: %s, 事件ID: %d", companyID, event.EventType, event.ID)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), callbackDispatchTimeout)
+			defer cancel()
+			s.Dispatch(ctx, event)
+		}()
+	}
+
+	return s.reply(crypto)
+}
+
+// crypto 根据公司的事件订阅配置创建加解密器
+func (s *CallbackService) crypto(companyID uint) (*callbackCrypto, error) {
+	var config model.SSOConfig
+	if err := database.GetDB().Where("company_id = ?", companyID).First(&config).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCallbackNotConfigured
+		}
+		logrus.Errorf("获取身份验证（免登）配置失败: %v", err)
+		return nil, err
+	}
+	if config.Status != 1 || config.CallbackToken == "" || config.CallbackAESKey == "" {
+		return nil, ErrCallbackNotConfigured
+	}
+	return newCallbackCrypto(string(config.CallbackToken), string(config.CallbackAESKey), config.AppKey)
+}
+
+// reply 生成加密的 success 应答
+func (s *CallbackService) reply(crypto *callbackCrypto) (*CallbackReply, error) {
+	encrypt, err := crypto.encrypt(callbackAck)
+	if err != nil {
+		logrus.Errorf("加密回调应答失败: %v", err)
+		return nil, err
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	reply := &CallbackReply{
+		TimeStamp: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Nonce:     hex.EncodeToString(nonce),
+		Encrypt:   encrypt,
+	}
+	reply.MsgSignature = crypto.signature(reply.TimeStamp, reply.Nonce, reply.Encrypt)
+	return reply, nil
+}
+
+// Dispatch 将事件分发给注册的处理器并保存处理结果，处理器之间互不影响
+func (s *CallbackService) Dispatch(ctx context.Context, event *model.CallbackEvent) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		logrus.Errorf("解析回调事件失败，事件ID: %d, 错误: %v", event.ID, err)
+	}
+
+	handlers := lookupCallbackHandlers(event.EventType)
+	var errMsgs []string
+	for _, handler := range handlers {
+		if err := runCallbackHandler(ctx, handler, event, payload); err != nil {
+			logrus.Errorf("处理回调事件失败，事件ID: %d, 事件类型: %s, 错误: %v", event.ID, event.EventType, err)
+			errMsgs = append(errMsgs, err.Error())
+		}
+	}
+
+	now := time.Now()
+	event.Attempts++
+	event.ErrorMsg = strings.Join(errMsgs, "; ")
+	event.ProcessedAt = &now
+	switch {
+	case len(handlers) == 0:
+		event.Status = model.CallbackEventStatusIgnored
+	case len(errMsgs) > 0:
+		event.Status = model.CallbackEventStatusFailed
+	default:
+		event.Status = model.CallbackEventStatusProcessed
+	}
+
+	if err := database.GetDB().Model(event).Updates(map[string]interface{}{
+		"status":       event.Status,
+		"attempts":     event.Attempts,
+		"error_msg":    event.ErrorMsg,
+		"processed_at": event.ProcessedAt,
+	}).Error; err != nil {
+		logrus.Errorf("更新回调事件状态失败: %v", err)
+	}
+}
+
+// runCallbackHandler 执行处理器，处理器 panic 时视为失败
+func runCallbackHandler(ctx context.Context, handler CallbackHandler, event *model.CallbackEvent, payload map[string]interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理器异常: %v", r)
+		}
+	}()
+	return handler(ctx, event, payload)
+}
+
+// Retry 重新分发回调事件，用于处理失败或服务重启时未处理完的事件
+func (s *CallbackService) Retry(ctx context.Context, id uint) (*model.CallbackEvent, error) {
+	var event model.CallbackEvent
+	if err := database.GetDB().First(&event, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("回调事件不存在")
+		}
+		logrus.Errorf("获取回调事件失败: %v", err)
+		return nil, err
+	}
+
+	s.Dispatch(ctx, &event)
+	return &event, nil
+}
+
+// ListEvents 分页获取回调事件
+func (s *CallbackService) ListEvents(companyID uint, eventType, status string, page, pageSize int) ([]model.CallbackEvent, int64, error) {
+	db := database.GetDB()
+
+	var events []model.CallbackEvent
+	var total int64
+
+	query := db.Model(&model.CallbackEvent{})
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取回调事件总数失败: %v", err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Order("id DESC").Find(&events).Error; err != nil {
+		logrus.Errorf("获取回调事件失败: %v", err)
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
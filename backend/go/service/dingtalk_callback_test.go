@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+const testCallbackAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+
+func TestCallbackCrypto(t *testing.T) {
+	crypto, err := newCallbackCrypto("token", testCallbackAESKey, "app-key")
+	if err != nil {
+		t.Fatalf("newCallbackCrypto failed: %v", err)
+	}
+
+	encrypt, err := crypto.encrypt(`{"EventType":"check_url"}`)
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if plaintext, err := crypto.decrypt(encrypt); err != nil || plaintext != `{"EventType":"check_url"}` {
+		t.Errorf("Unexpected decrypt result: %q %v", plaintext, err)
+	}
+
+	signature := crypto.signature("1700000000000", "nonce", encrypt)
+	if !crypto.verify(signature, "1700000000000", "nonce", encrypt) || crypto.verify(signature, "1700000000001", "nonce", encrypt) {
+		t.Error("Unexpected signature verification result")
+	}
+
+	other, _ := newCallbackCrypto("token", testCallbackAESKey, "other-app")
+	if _, err := other.decrypt(encrypt); err == nil {
+		t.Error("Expected message of another app to fail")
+	}
+	if _, err := newCallbackCrypto("token", "short", "app-key"); err == nil {
+		t.Error("Expected invalid aes_key to fail")
+	}
+}
+
+func TestCallbackReceive(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.CallbackEvent{}, &model.SSOConfig{})
+	db := database.GetDB()
+	svc := NewCallbackService()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	db.Create(&model.SSOConfig{CompanyID: 1, AppID: "app", AppKey: "app-key", AppSecret: "secret", CallbackToken: "token", CallbackAESKey: testCallbackAESKey, Status: 1})
+
+	handled := make(chan uint, 2)
+	RegisterCallbackHandler("test_receive", func(ctx context.Context, event *model.CallbackEvent, payload map[string]interface{}) error {
+		if payload["processInstanceId"] != "proc-inst-001" {
+			t.Errorf("Unexpected payload: %v", payload)
+		}
+		handled <- event.ID
+		return nil
+	})
+
+	crypto, _ := newCallbackCrypto("token", testCallbackAESKey, "app-key")
+	encrypt, _ := crypto.encrypt(`{"EventType":"test_receive","processInstanceId":"proc-inst-001"}`)
+	signature := crypto.signature("1700000000000", "nonce", encrypt)
+
+	t.Run("Success", func(t *testing.T) {
+		reply, err := svc.Receive(1, signature, "1700000000000", "nonce", encrypt)
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if !crypto.verify(reply.MsgSignature, reply.TimeStamp, reply.Nonce, reply.Encrypt) {
+			t.Error("Unexpected reply signature")
+		}
+		if ack, err := crypto.decrypt(reply.Encrypt); err != nil || ack != "success" {
+			t.Errorf("Unexpected ack: %q %v", ack, err)
+		}
+
+		var id uint
+		select {
+		case id = <-handled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Handler was not called")
+		}
+
+		var event model.CallbackEvent
+		for i := 0; i < 50; i++ {
+			db.First(&event, id)
+			if event.Status != model.CallbackEventStatusPending {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if event.Status != model.CallbackEventStatusProcessed || event.Attempts != 1 || event.EventType != "test_receive" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		if _, err := svc.Receive(1, signature, "1700000000000", "nonce", encrypt); err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if _, total, _ := svc.ListEvents(1, "test_receive", "", 1, 10); total != 1 {
+			t.Errorf("Expected duplicate event to be saved once, got %d", total)
+		}
+		select {
+		case <-handled:
+			t.Error("Expected duplicate event not to be dispatched")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("InvalidSignature", func(t *testing.T) {
+		if _, err := svc.Receive(1, "bad", "1700000000000", "nonce", encrypt); err != ErrCallbackSignature {
+			t.Errorf("Expected signature error, got %v", err)
+		}
+		if _, err := svc.Receive(2, signature, "1700000000000", "nonce", encrypt); err != ErrCallbackNotConfigured {
+			t.Errorf("Expected not configured error, got %v", err)
+		}
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		event := &model.CallbackEvent{CompanyID: 1, EventType: "unknown_event", PayloadHash: "x", Payload: `{}`, Status: model.CallbackEventStatusPending}
+		db.Create(event)
+		svc.Dispatch(context.Background(), event)
+		if event.Status != model.CallbackEventStatusIgnored {
+			t.Errorf("Expected ignored event, got %s", event.Status)
+		}
+	})
+}
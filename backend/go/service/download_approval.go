@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+)
+
+// approvalListSize listids 接口每页最多返回的数量
+const approvalListSize = 20
+
+// approvalMaxRange listids 接口允许的最大时间范围
+const approvalMaxRange = 120 * 24 * time.Hour
+
+// 获取审批详情的默认和最大并发数
+const (
+	approvalDefaultConcurrency = 5
+	approvalMaxConcurrency     = 20
+)
+
+// approvalMaxErrors 任务结果中最多保留的失败实例数
+const approvalMaxErrors = 100
+
+// approvalErrorField 导出行中记录单个实例失败原因的列
+const approvalErrorField = "error"
+
+// approvalBaseColumns 审批实例的固定导出列，表单控件按首次出现的顺序排在其后
+var approvalBaseColumns = []exportColumn{
+	{Field: "process_instance_id", Label: "审批实例ID"},
+	{Field: "business_id", Label: "审批编号"},
+	{Field: "title", Label: "标题"},
+	{Field: "status", Label: "状态"},
+	{Field: "result", Label: "结果"},
+	{Field: "originator_userid", Label: "发起人"},
+	{Field: "originator_dept_id", Label: "发起部门"},
+	{Field: "create_time", Label: "发起时间"},
+	{Field: "finish_time", Label: "结束时间"},
+}
+
+// ApprovalParams 审批导出任务的参数，保存在 DownloadTask.Params 中
+// 时间支持毫秒时间戳或 2006-01-02 15:04:05、2006-01-02 格式的字符串
+type ApprovalParams struct {
+	ProcessCode string      `json:"process_code"` // 审批模板的 process_code
+	StartTime   interface{} `json:"start_time"`   // 发起时间的开始，必填
+	EndTime     interface{} `json:"end_time"`     // 发起时间的结束，为空时为当前时间
+	Statuses    []string    `json:"statuses"`     // 只导出这些状态的实例：NEW, RUNNING, TERMINATED, COMPLETED, CANCELED，为空时导出全部
+	UserIDList  string      `json:"userid_list"`  // 只导出这些发起人的实例，逗号分隔
+	Concurrency int         `json:"concurrency"`  // 获取详情的并发数，默认 5，最大 20
+
+	start time.Time
+	end   time.Time
+}
+
+// parseApprovalParams 解析并校验审批导出参数
+func parseApprovalParams(raw string) (*ApprovalParams, error) {
+	var params ApprovalParams
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &params); err != nil {
+			return nil, fmt.Errorf("审批导出参数格式错误: %v", err)
+		}
+	}
+	if params.ProcessCode == "" {
+		return nil, errors.New("审批导出参数缺少 process_code")
+	}
+
+	var err error
+	if params.start, err = parseApprovalTime(params.StartTime); err != nil || params.start.IsZero() {
+		return nil, errors.New("审批导出参数 start_time 格式错误或为空")
+	}
+	if params.end, err = parseApprovalTime(params.EndTime); err != nil {
+		return nil, errors.New("审批导出参数 end_time 格式错误")
+	}
+	if params.end.IsZero() {
+		params.end = time.Now()
+	}
+	if !params.start.Before(params.end) {
+		return nil, errors.New("审批导出的开始时间需早于结束时间")
+	}
+	if params.end.Sub(params.start) > approvalMaxRange {
+		return nil, errors.New("审批导出的时间范围不能超过120天")
+	}
+
+	for i, status := range params.Statuses {
+		params.Statuses[i] = strings.ToUpper(strings.TrimSpace(status))
+	}
+	if params.Concurrency <= 0 {
+		params.Concurrency = approvalDefaultConcurrency
+	}
+	if params.Concurrency > approvalMaxConcurrency {
+		params.Concurrency = approvalMaxConcurrency
+	}
+	return &params, nil
+}
+
+// parseApprovalTime 解析毫秒时间戳或日期字符串，为空时返回零值
+func parseApprovalTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.UnixMilli(int64(v)), nil
+	case string:
+		if v == "" {
+			return time.Time{}, nil
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Parse(time.RFC3339, v)
+	default:
+		return time.Time{}, fmt.Errorf("不支持的时间类型: %T", value)
+	}
+}
+
+// wantStatus 实例状态是否在过滤条件中
+func (p *ApprovalParams) wantStatus(status string) bool {
+	if len(p.Statuses) == 0 {
+		return true
+	}
+	for _, want := range p.Statuses {
+		if want == status {
+			return true
+		}
+	}
+	return false
+}
+
+// approvalInstance 审批实例详情中导出用到的字段
+type approvalInstance struct {
+	Title               string `json:"title"`
+	Status              string `json:"status"`
+	Result              string `json:"result"`
+	BusinessID          string `json:"business_id"`
+	OriginatorUserID    string `json:"originator_userid"`
+	OriginatorDeptID    string `json:"originator_dept_id"`
+	CreateTime          string `json:"create_time"`
+	FinishTime          string `json:"finish_time"`
+	FormComponentValues []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"form_component_values"`
+}
+
+// approvalFetchResult 单个实例的获取结果
+type approvalFetchResult struct {
+	index    int
+	instance *approvalInstance
+	err      error
+}
+
+// ApprovalInstanceError 获取失败的审批实例
+type ApprovalInstanceError struct {
+	ProcessInstanceID string `json:"process_instance_id"`
+	Error             string `json:"error"`
+}
+
+// executeApproval 执行审批导出任务：分页获取实例ID，并发获取详情，按表单控件展开为列后导出
+// 单个实例获取失败时在导出行中记录原因并继续，全部失败时任务失败
+func (s *DownloadTaskService) executeApproval(ctx context.Context, cancel context.CancelCauseFunc, task *model.DownloadTask, apiConfig *model.APIConfig) {
+	params, err := parseApprovalParams(task.Params)
+	if err != nil {
+		s.failTask(ctx, task, err.Error())
+		return
+	}
+	tokens := NewTokenProvider()
+
+	// 审批导出没有断点，重试或回收后重新执行时从头计数
+	task.PagesFetched = 0
+	task.RecordsFetched = 0
+
+	// 1. 分页获取实例ID，占进度的 10%-20%
+	ids, err := s.listApprovalIDs(ctx, cancel, tokens, task, params)
+	if err != nil {
+		logrus.Errorf("获取审批实例列表失败，任务ID: %d, 错误: %v", task.ID, err)
+		s.failTask(ctx, task, err.Error())
+		return
+	}
+
+	// 2. 并发获取详情，占进度的 20%-90%
+	instances := make([]*approvalInstance, len(ids))
+	instanceErrs := make([]error, len(ids))
+	jobs := make(chan int)
+	results := make(chan approvalFetchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < params.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				instance, err := getApprovalInstance(ctx, tokens, task.CompanyID, ids[index])
+				results <- approvalFetchResult{index: index, instance: instance, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for index := range ids {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	done, failed := 0, 0
+	for result := range results {
+		instances[result.index], instanceErrs[result.index] = result.instance, result.err
+		done++
+		if result.err != nil {
+			failed++
+			logrus.Warnf("获取审批实例详情失败，任务ID: %d, 实例ID: %s, 错误: %v", task.ID, ids[result.index], result.err)
+		}
+		if done%approvalListSize == 0 || done == len(ids) {
+			task.RecordsFetched = done
+			task.Progress = 20 + done*70/len(ids)
+			if err := s.updateApprovalProgress(task); err != nil {
+				cancel(err)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		s.failTask(ctx, task, err.Error())
+		return
+	}
+	if failed > 0 && failed == len(ids) {
+		s.failTask(ctx, task, fmt.Sprintf("获取审批实例详情全部失败: %v", instanceErrs[0]))
+		return
+	}
+
+	// 3. 展开表单控件并按状态过滤
+	columns, rows, instanceErrors := flattenApprovalInstances(ids, instances, instanceErrs, params)
+
+	var downloadResult *model.DownloadResult
+	var redactions []exportRedaction
+	var errMsg string
+	if mapping, err := parseAPIMapping(apiConfig.Mapping); err != nil {
+		errMsg = err.Error()
+	} else if mapping != nil && len(mapping.Columns) > 0 {
+		// 配置了映射时按映射输出
+		records := make([]interface{}, len(rows))
+		for i, row := range rows {
+			records[i] = row
+		}
+		downloadResult, columns, redactions, errMsg = s.exportRecords(ctx, task, apiConfig, records)
+	} else {
+		labelColumns(exportModule(apiConfig), columns)
+		downloadResult, columns, redactions, errMsg = s.saveExportRows(ctx, task, apiConfig, columns, rows)
+	}
+	if errMsg != "" {
+		s.failTask(ctx, task, errMsg)
+		return
+	}
+
+	resultSummary := exportSummary(task, downloadResult, columns, redactions)
+	resultSummary["instances"] = len(ids)
+	resultSummary["failed"] = failed
+	resultSummary["filtered"] = len(ids) - len(rows)
+	if len(instanceErrors) > 0 {
+		resultSummary["errors"] = instanceErrors
+	}
+	if !s.completeTask(task, downloadResult, resultSummary) {
+		return
+	}
+	publishTaskEvent(task)
+
+	logrus.Infof("审批导出任务执行成功，任务ID: %d, 实例数: %d, 失败: %d, 文件名: %s", task.ID, len(ids), failed, task.FileName)
+}
+
+// listApprovalIDs 分页获取时间范围内的审批实例ID
+func (s *DownloadTaskService) listApprovalIDs(ctx context.Context, cancel context.CancelCauseFunc, tokens *TokenProvider, task *model.DownloadTask, params *ApprovalParams) ([]string, error) {
+	var ids []string
+	cursor := 0
+	for {
+		payload := map[string]interface{}{
+			"process_code": params.ProcessCode,
+			"start_time":   params.start.UnixMilli(),
+			"end_time":     params.end.UnixMilli(),
+			"size":         approvalListSize,
+			"cursor":       cursor,
+		}
+		if params.UserIDList != "" {
+			payload["userid_list"] = params.UserIDList
+		}
+
+		var page struct {
+			Result struct {
+				List       []string `json:"list"`
+				NextCursor *int     `json:"next_cursor"`
+			} `json:"result"`
+		}
+		if err := callOAPI(ctx, tokens, task.CompanyID, "获取审批实例ID列表", "/topapi/processinstance/listids", payload, &page); err != nil {
+			return nil, err
+		}
+		ids = append(ids, page.Result.List...)
+
+		task.PagesFetched++
+		task.Progress = 10 + min(task.PagesFetched, 10)
+		if err := s.updateApprovalProgress(task); err != nil {
+			cancel(err)
+			return nil, err
+		}
+
+		if page.Result.NextCursor == nil || len(page.Result.List) == 0 {
+			return ids, nil
+		}
+		cursor = *page.Result.NextCursor
+	}
+}
+
+// updateApprovalProgress 保存执行中任务的进度，更新不到说明任务已被取消或删除
+func (s *DownloadTaskService) updateApprovalProgress(task *model.DownloadTask) error {
+	result := database.GetDB().Model(&model.DownloadTask{}).
+		Where("id = ? AND status = ?", task.ID, model.DownloadTaskStatusRunning).
+		Updates(map[string]interface{}{
+			"pages_fetched":   task.PagesFetched,
+			"records_fetched": task.RecordsFetched,
+			"progress":        task.Progress,
+		})
+	if result.Error != nil {
+		logrus.Errorf("更新任务进度失败: %v", result.Error)
+		return nil
+	}
+	if result.RowsAffected == 0 {
+		return errTaskCancelled
+	}
+	publishTaskEvent(task)
+	return nil
+}
+
+// getApprovalInstance 获取单个审批实例的详情
+func getApprovalInstance(ctx context.Context, tokens *TokenProvider, companyID uint, processInstanceID string) (*approvalInstance, error) {
+	var resp struct {
+		ProcessInstance approvalInstance `json:"process_instance"`
+	}
+	err := callOAPI(ctx, tokens, companyID, "获取审批实例详情", "/topapi/processinstance/get",
+		map[string]interface{}{"process_instance_id": processInstanceID}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.ProcessInstance, nil
+}
+
+// flattenApprovalInstances 将审批实例展开为导出行，每个表单控件一列，列名为控件名称
+// 同一实例中控件名称重复时以 名称(控件ID) 区分；获取失败的实例保留一行并在 error 列记录原因
+func flattenApprovalInstances(ids []string, instances []*approvalInstance, instanceErrs []error, params *ApprovalParams) ([]exportColumn, []map[string]interface{}, []ApprovalInstanceError) {
+	columns := append([]exportColumn(nil), approvalBaseColumns...)
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		seen[column.Field] = true
+	}
+
+	rows := make([]map[string]interface{}, 0, len(ids))
+	instanceErrors := []ApprovalInstanceError{}
+	for i, id := range ids {
+		if instanceErrs[i] != nil {
+			rows = append(rows, map[string]interface{}{"process_instance_id": id, approvalErrorField: instanceErrs[i].Error()})
+			if len(instanceErrors) < approvalMaxErrors {
+				instanceErrors = append(instanceErrors, ApprovalInstanceError{ProcessInstanceID: id, Error: instanceErrs[i].Error()})
+			}
+			continue
+		}
+
+		instance := instances[i]
+		if !params.wantStatus(instance.Status) {
+			continue
+		}
+
+		row := map[string]interface{}{
+			"process_instance_id": id,
+			"business_id":         instance.BusinessID,
+			"title":               instance.Title,
+			"status":              instance.Status,
+			"result":              instance.Result,
+			"originator_userid":   instance.OriginatorUserID,
+			"originator_dept_id":  instance.OriginatorDeptID,
+			"create_time":         instance.CreateTime,
+			"finish_time":         instance.FinishTime,
+		}
+		used := make(map[string]bool)
+		for _, component := range instance.FormComponentValues {
+			field := component.Name
+			if field == "" {
+				field = component.ID
+			}
+			if used[field] || (seen[field] && isApprovalBaseField(field)) {
+				field = fmt.Sprintf("%s(%s)", field, component.ID)
+			}
+			used[field] = true
+			row[field] = component.Value
+
+			if !seen[field] {
+				seen[field] = true
+				columns = append(columns, exportColumn{Field: field, Label: field})
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(instanceErrors) > 0 {
+		columns = append(columns, exportColumn{Field: approvalErrorField, Label: "失败原因"})
+	}
+	return columns, rows, instanceErrors
+}
+
+// isApprovalBaseField 字段是否为审批实例的固定列
+func isApprovalBaseField(field string) bool {
+	for _, column := range approvalBaseColumns {
+		if column.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
+)
+
+func TestParseApprovalParams(t *testing.T) {
+	params, err := parseApprovalParams(`{"process_code":"PROC-LEAVE","start_time":"2026-01-01","end_time":1767312000000,"statuses":["completed"]}`)
+	if err != nil {
+		t.Fatalf("parseApprovalParams failed: %v", err)
+	}
+	if params.end.UnixMilli() != 1767312000000 || params.Concurrency != approvalDefaultConcurrency {
+		t.Errorf("Unexpected params: %+v", params)
+	}
+	if !params.wantStatus("COMPLETED") || params.wantStatus("RUNNING") {
+		t.Errorf("Unexpected status filter: %v", params.Statuses)
+	}
+
+	for _, raw := range []string{
+		`{"start_time":"2026-01-01"}`,
+		`{"process_code":"PROC-LEAVE"}`,
+		`{"process_code":"PROC-LEAVE","start_time":"2026-01-02","end_time":"2026-01-01"}`,
+		`{"process_code":"PROC-LEAVE","start_time":"2025-01-01","end_time":"2026-01-01"}`,
+	} {
+		if _, err := parseApprovalParams(raw); err == nil {
+			t.Errorf("Expected %s to fail", raw)
+		}
+	}
+}
+
+func TestApprovalExportWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.APIConfig{}, &model.DownloadTask{}, &model.DataDictionary{}, &model.MessageTemplate{}, &model.UserRole{}, &model.FieldPermission{}, &model.DownloadResult{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+	config.GlobalConfig.Download.ExportDir = t.TempDir()
+
+	localStorage, err := storage.NewLocalStorage(t.TempDir(), "/api/v1/storage/file", "secret")
+	if err != nil {
+		t.Fatalf("NewLocalStorage failed: %v", err)
+	}
+	storage.Set(localStorage)
+	defer storage.Set(nil)
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	if _, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret"}); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+	apiConfig := &model.APIConfig{Name: "approval", Code: "approval", CompanyID: 1}
+	db.Create(apiConfig)
+
+	run := func(params string) (*model.DownloadTask, map[string]interface{}) {
+		task := &model.DownloadTask{
+			CompanyID:   1,
+			APIConfigID: apiConfig.ID,
+			TaskType:    model.DownloadTaskTypeApproval,
+			Params:      params,
+			FileFormat:  model.FileFormatCSV,
+			Status:      model.DownloadTaskStatusRunning,
+		}
+		db.Create(task)
+		NewDownloadTaskService().ExecuteTask(context.Background(), task)
+		db.First(task, task.ID)
+
+		var summary map[string]interface{}
+		json.Unmarshal([]byte(task.Result), &summary)
+		return task, summary
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		task, summary := run(`{"process_code":"PROC-LEAVE","start_time":1767139200000,"end_time":1767484800000}`)
+		if task.Status != model.DownloadTaskStatusSuccess {
+			t.Fatalf("Expected success, got %s: %s", task.Status, task.ErrorMsg)
+		}
+		if summary["instances"] != 2.0 || summary["record_count"] != 2.0 || summary["failed"] != 0.0 {
+			t.Errorf("Unexpected summary: %v", summary)
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		// 重试或回收后重新执行时，上次执行的进度计数不累加
+		fresh, _ := run(`{"process_code":"PROC-LEAVE","start_time":1767139200000,"end_time":1767484800000}`)
+		task := &model.DownloadTask{
+			CompanyID:      1,
+			APIConfigID:    apiConfig.ID,
+			TaskType:       model.DownloadTaskTypeApproval,
+			Params:         fresh.Params,
+			FileFormat:     model.FileFormatCSV,
+			Status:         model.DownloadTaskStatusRunning,
+			PagesFetched:   7,
+			RecordsFetched: 50,
+		}
+		db.Create(task)
+		NewDownloadTaskService().ExecuteTask(context.Background(), task)
+		db.First(task, task.ID)
+		if task.Status != model.DownloadTaskStatusSuccess || task.PagesFetched != fresh.PagesFetched || task.RecordsFetched != fresh.RecordsFetched {
+			t.Errorf("Expected counters %d/%d, got %d/%d", fresh.PagesFetched, fresh.RecordsFetched, task.PagesFetched, task.RecordsFetched)
+		}
+	})
+
+	t.Run("StatusFilter", func(t *testing.T) {
+		task, summary := run(`{"process_code":"PROC-LEAVE","start_time":1767139200000,"end_time":1767484800000,"statuses":["COMPLETED"]}`)
+		if task.Status != model.DownloadTaskStatusSuccess || summary["record_count"] != 1.0 || summary["filtered"] != 1.0 {
+			t.Errorf("Unexpected result: %s %v", task.Status, summary)
+		}
+	})
+
+	t.Run("InstanceError", func(t *testing.T) {
+		mock.InjectFault(dingtalkmock.EndpointProcessInstanceGet, dingtalkmock.Fault{Errcode: dingtalkmock.ErrcodeInstanceNotFound, Errmsg: "审批实例不存在", Times: 1})
+		defer mock.ClearFaults()
+
+		task, summary := run(`{"process_code":"PROC-LEAVE","start_time":1767139200000,"end_time":1767484800000}`)
+		if task.Status != model.DownloadTaskStatusSuccess || summary["failed"] != 1.0 || summary["record_count"] != 2.0 {
+			t.Fatalf("Unexpected result: %s %v", task.Status, summary)
+		}
+		if errs, _ := summary["errors"].([]interface{}); len(errs) != 1 {
+			t.Errorf("Expected 1 instance error, got %v", summary["errors"])
+		}
+	})
+}
+
+func TestFlattenApprovalInstances(t *testing.T) {
+	var instance approvalInstance
+	json.Unmarshal([]byte(`{"status":"COMPLETED","form_component_values":[
+		{"id":"a","name":"天数","value":"2"},
+		{"id":"b","name":"天数","value":"3"},
+		{"id":"c","name":"","value":"x"}]}`), &instance)
+
+	columns, rows, _ := flattenApprovalInstances([]string{"p1"}, []*approvalInstance{&instance}, []error{nil}, &ApprovalParams{})
+	row := rows[0]
+	if row["天数"] != "2" || row["天数(b)"] != "3" || row["c"] != "x" {
+		t.Errorf("Unexpected row: %v", row)
+	}
+	if len(columns) != len(approvalBaseColumns)+3 {
+		t.Errorf("Unexpected columns: %v", columns)
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// cleanupBatchSize 每批清理的下载结果数量
+const cleanupBatchSize = 100
+
+// DownloadCleaner 定期清理过期的导出文件
+type DownloadCleaner struct {
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewDownloadCleaner 创建导出文件清理任务
+func NewDownloadCleaner(cfg config.StorageConfig) *DownloadCleaner {
+	interval := cfg.CleanupInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &DownloadCleaner{interval: interval}
+}
+
+// Start 启动清理任务
+func (c *DownloadCleaner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			c.Cleanup(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	logrus.Infof("导出文件清理任务已启动，间隔: %s", c.interval)
+}
+
+// Stop 停止清理任务
+func (c *DownloadCleaner) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// Cleanup 删除已过期的导出文件及下载结果，返回清理数量
+// 按 ID 顺序分批处理，删除失败的记录跳过，留到下次清理时重试
+func (c *DownloadCleaner) Cleanup(ctx context.Context) int {
+	db := database.GetDB()
+	cleaned := 0
+
+	var lastID uint
+	for ctx.Err() == nil {
+		var results []model.DownloadResult
+		if err := db.Where("id > ? AND expires_at IS NOT NULL AND expires_at < ?", lastID, time.Now()).
+			Order("id").
+			Limit(cleanupBatchSize).
+			Find(&results).Error; err != nil {
+			logrus.Errorf("查询过期下载结果失败: %v", err)
+			break
+		}
+		if len(results) == 0 {
+			break
+		}
+
+		for i := range results {
+			lastID = results[i].ID
+			if !deleteArtifact(ctx, &results[i]) {
+				continue
+			}
+			if err := db.Delete(&results[i]).Error; err != nil {
+				logrus.Errorf("删除下载结果失败: %v", err)
+				continue
+			}
+			db.Model(&model.DownloadTask{}).Where("id = ?", results[i].TaskID).Update("file_url", "")
+			cleaned++
+		}
+		if len(results) < cleanupBatchSize {
+			break
+		}
+	}
+
+	if cleaned > 0 {
+		logrus.Infof("已清理过期导出文件 %d 个", cleaned)
+	}
+
+	c.cleanupCheckpoints(ctx)
+	return cleaned
+}
+
+// cleanupCheckpoints 删除超过保留时长仍未重试的失败或已取消任务的断点数据
+func (c *DownloadCleaner) cleanupCheckpoints(ctx context.Context) {
+	db := database.GetDB()
+	cleaned := 0
+
+	var lastID uint
+	for ctx.Err() == nil {
+		var tasks []model.DownloadTask
+		if err := db.Where("id > ? AND status IN ? AND checkpoint <> '' AND finished_at < ?", lastID,
+			[]string{model.DownloadTaskStatusFailed, model.DownloadTaskStatusCancelled}, time.Now().Add(-storageRetention())).
+			Order("id").
+			Limit(cleanupBatchSize).
+			Find(&tasks).Error; err != nil {
+			logrus.Errorf("查询过期断点失败: %v", err)
+			break
+		}
+		if len(tasks) == 0 {
+			break
+		}
+
+		for _, task := range tasks {
+			lastID = task.ID
+			clearCheckpoint(ctx, task.ID, task.PagesFetched)
+			if err := db.Model(&task).Update("checkpoint", "").Error; err != nil {
+				logrus.Errorf("清除任务断点失败: %v", err)
+				continue
+			}
+			cleaned++
+		}
+		if len(tasks) < cleanupBatchSize {
+			break
+		}
+	}
+
+	if cleaned > 0 {
+		logrus.Infof("已清理过期任务断点 %d 个", cleaned)
+	}
+}
+
+// deleteArtifact 从文件存储中删除下载结果对应的导出文件
+func deleteArtifact(ctx context.Context, result *model.DownloadResult) bool {
+	if result.StorageKey == "" {
+		return true
+	}
+
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		logrus.Errorf("文件存储未初始化，无法删除导出文件: %s", result.StorageKey)
+		return false
+	}
+	if fileStorage.Type() != result.StorageType {
+		logrus.Warnf("导出文件存储类型(%s)与当前存储(%s)不一致，跳过删除: %s", result.StorageType, fileStorage.Type(), result.StorageKey)
+		return true
+	}
+
+	if err := fileStorage.Delete(ctx, result.StorageKey); err != nil {
+		logrus.Errorf("删除导出文件失败: %s, 错误: %v", result.StorageKey, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
+)
+
+func TestDownloadCleaner(t *testing.T) {
+	setupTestModels(&model.DownloadResult{}, &model.DownloadTask{})
+	db := database.GetDB()
+
+	localStorage, err := storage.NewLocalStorage(t.TempDir(), "/api/v1/storage/file", "secret")
+	if err != nil {
+		t.Fatalf("NewLocalStorage failed: %v", err)
+	}
+	storage.Set(localStorage)
+	defer storage.Set(nil)
+
+	ctx := context.Background()
+	put := func(taskID uint, key string, expiresAt time.Time) *model.DownloadResult {
+		if err := localStorage.Put(ctx, key, strings.NewReader("[]"), 2, "application/json"); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		db.Create(&model.DownloadTask{ID: taskID, TaskName: "t", Status: model.DownloadTaskStatusSuccess, FileURL: "/file"})
+		result := &model.DownloadResult{TaskID: taskID, StorageType: storage.TypeLocal, StorageKey: key, FileName: "a.json", ExpiresAt: &expiresAt}
+		db.Create(result)
+		return result
+	}
+	expired := put(1, "exports/1/expired.json", time.Now().Add(-time.Hour))
+	valid := put(2, "exports/1/valid.json", time.Now().Add(time.Hour))
+
+	t.Run("SignedURL", func(t *testing.T) {
+		signed, _ := localStorage.SignedURL(ctx, valid.StorageKey, valid.FileName, time.Minute)
+		query, _ := url.ParseQuery(signed[strings.Index(signed, "?")+1:])
+		if _, err := localStorage.Verify(query.Get("key"), query.Get("filename"), query.Get("expires"), query.Get("signature")); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+		if _, err := localStorage.Verify(query.Get("key"), "other.json", query.Get("expires"), query.Get("signature")); err == nil {
+			t.Error("Expected tampered filename to be rejected")
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		if cleaned := NewDownloadCleaner(config.StorageConfig{}).Cleanup(ctx); cleaned != 1 {
+			t.Fatalf("Expected 1 cleaned result, got %d", cleaned)
+		}
+		if _, err := localStorage.Open(ctx, expired.StorageKey); err != storage.ErrNotFound {
+			t.Errorf("Expected expired file to be deleted, got %v", err)
+		}
+		if reader, err := localStorage.Open(ctx, valid.StorageKey); err != nil {
+			t.Errorf("Expected valid file to be kept, got %v", err)
+		} else {
+			reader.Close()
+		}
+
+		var task model.DownloadTask
+		db.First(&task, expired.TaskID)
+		if task.FileURL != "" {
+			t.Errorf("Expected file_url to be cleared, got %s", task.FileURL)
+		}
+	})
+
+	t.Run("FailedBatch", func(t *testing.T) {
+		// 第一批的导出文件无法删除，跳过后仍继续清理后面的记录
+		past := time.Now().Add(-time.Hour)
+		for i := 0; i < cleanupBatchSize; i++ {
+			db.Create(&model.DownloadResult{TaskID: uint(100 + i), StorageType: storage.TypeLocal, StorageKey: "../outside.json", ExpiresAt: &past})
+		}
+		after := put(300, "exports/300/expired.json", past)
+		db.Create(&model.DownloadResult{TaskID: 301, ExpiresAt: &past})
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if cleaned := NewDownloadCleaner(config.StorageConfig{}).Cleanup(timeoutCtx); cleaned != 2 || timeoutCtx.Err() != nil {
+			t.Errorf("Expected 2 cleaned after the failed batch, got %d (%v)", cleaned, timeoutCtx.Err())
+		}
+		if _, err := localStorage.Open(ctx, after.StorageKey); err != storage.ErrNotFound {
+			t.Errorf("Expected file after the failed batch to be deleted, got %v", err)
+		}
+
+		var remaining int64
+		db.Model(&model.DownloadResult{}).Where("expires_at < ?", time.Now()).Count(&remaining)
+		if remaining != cleanupBatchSize {
+			t.Errorf("Expected failed results kept for the next run, got %d", remaining)
+		}
+	})
+}
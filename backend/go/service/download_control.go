@@ -0,0 +1,204 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// errTaskCancelled 任务被用户取消
+var errTaskCancelled = errors.New("任务已被取消")
+
+// runningTasks 本实例正在执行的任务，用于取消时中断进行中的请求
+var runningTasks = struct {
+	sync.Mutex
+	cancels map[uint]context.CancelCauseFunc
+}{cancels: make(map[uint]context.CancelCauseFunc)}
+
+// trackRunningTask 登记正在执行的任务，返回注销函数
+func trackRunningTask(taskID uint, cancel context.CancelCauseFunc) func() {
+	runningTasks.Lock()
+	runningTasks.cancels[taskID] = cancel
+	runningTasks.Unlock()
+
+	return func() {
+		runningTasks.Lock()
+		delete(runningTasks.cancels, taskID)
+		runningTasks.Unlock()
+	}
+}
+
+// cancelRunningTask 中断本实例上正在执行的任务，任务不在本实例时返回 false
+// 其他实例上的任务会在下一页更新进度时发现状态已变更而停止
+func cancelRunningTask(taskID uint) bool {
+	runningTasks.Lock()
+	cancel, ok := runningTasks.cancels[taskID]
+	runningTasks.Unlock()
+
+	if ok {
+		cancel(errTaskCancelled)
+	}
+	return ok
+}
+
+// Cancel 取消等待中或执行中的任务
+func (s *DownloadTaskService) Cancel(id uint) (*model.DownloadTask, error) {
+	db := database.GetDB()
+
+	result := db.Model(&model.DownloadTask{}).
+		Where("id = ? AND status IN ?", id, []string{model.DownloadTaskStatusPending, model.DownloadTaskStatusRunning}).
+		Updates(map[string]interface{}{
+			"status":      model.DownloadTaskStatusCancelled,
+			"error_msg":   errTaskCancelled.Error(),
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		logrus.Errorf("取消下载任务失败: %v", result.Error)
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("只能取消等待中或执行中的任务")
+	}
+
+	cancelRunningTask(id)
+	logrus.Infof("下载任务已取消，任务ID: %d", id)
+
+	task, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	publishTaskEvent(task)
+	return task, nil
+}
+
+// Retry 重试失败或已取消的任务，从上次完成的页继续请求
+func (s *DownloadTaskService) Retry(id uint) (*model.DownloadTask, error) {
+	db := database.GetDB()
+
+	result := db.Model(&model.DownloadTask{}).
+		Where("id = ? AND status IN ?", id, []string{model.DownloadTaskStatusFailed, model.DownloadTaskStatusCancelled}).
+		Updates(map[string]interface{}{
+			"status":      model.DownloadTaskStatusPending,
+			"error_msg":   "",
+			"finished_at": nil,
+		})
+	if result.Error != nil {
+		logrus.Errorf("重试下载任务失败: %v", result.Error)
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("只能重试失败或已取消的任务")
+	}
+
+	if queue := GetDownloadQueue(); queue != nil {
+		queue.Enqueue(id)
+	}
+
+	task, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	publishTaskEvent(task)
+	return task, nil
+}
+
+// Rerun 以相同参数复制任务并重新执行
+func (s *DownloadTaskService) Rerun(id, userID uint) (*model.DownloadTask, error) {
+	source, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &model.DownloadTask{
+		CompanyID:    source.CompanyID,
+		UserID:       userID,
+		APIConfigID:  source.APIConfigID,
+		TaskName:     source.TaskName,
+		TaskType:     source.TaskType,
+		Params:       source.Params,
+		FileFormat:   source.FileFormat,
+		SyncMode:     source.SyncMode,
+		SourceTaskID: &source.ID,
+	}
+	if err := s.Create(task); err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// checkpointKey 返回分页断点数据在文件存储中的路径
+func checkpointKey(taskID uint, page int) string {
+	return fmt.Sprintf("spool/task_%d/page_%d.json", taskID, page)
+}
+
+// saveCheckpointPage 保存一页记录，重试时用于恢复已获取的数据
+func saveCheckpointPage(ctx context.Context, taskID uint, page int, records []interface{}) error {
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		return errors.New("文件存储未初始化")
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return fileStorage.Put(ctx, checkpointKey(taskID, page), bytes.NewReader(data), int64(len(data)), "application/json")
+}
+
+// loadCheckpoint 读取任务的分页断点及此前已获取的记录，没有断点时返回 nil
+func loadCheckpoint(ctx context.Context, task *model.DownloadTask) (*pageProgress, []interface{}, error) {
+	if task.Checkpoint == "" {
+		return nil, nil, nil
+	}
+
+	var checkpoint pageProgress
+	if err := json.Unmarshal([]byte(task.Checkpoint), &checkpoint); err != nil {
+		return nil, nil, fmt.Errorf("断点格式错误: %v", err)
+	}
+
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		return nil, nil, errors.New("文件存储未初始化")
+	}
+
+	var records []interface{}
+	for page := 1; page <= checkpoint.Pages; page++ {
+		reader, err := fileStorage.Open(ctx, checkpointKey(task.ID, page))
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取第%d页断点数据失败: %v", page, err)
+		}
+		var pageRecords []interface{}
+		err = json.NewDecoder(reader).Decode(&pageRecords)
+		reader.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("解析第%d页断点数据失败: %v", page, err)
+		}
+		records = append(records, pageRecords...)
+	}
+
+	return &checkpoint, records, nil
+}
+
+// clearCheckpoint 删除任务的分页断点数据
+func clearCheckpoint(ctx context.Context, taskID uint, pages int) {
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		return
+	}
+
+	for page := 1; page <= pages; page++ {
+		if err := fileStorage.Delete(ctx, checkpointKey(taskID, page)); err != nil {
+			logrus.Errorf("删除断点数据失败，任务ID: %d, 页: %d, 错误: %v", taskID, page, err)
+		}
+	}
+}
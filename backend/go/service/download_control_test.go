@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestCancelAndRetry(t *testing.T) {
+	setupTestModels(&model.DownloadTask{})
+	db := database.GetDB()
+	svc := NewDownloadTaskService()
+
+	task := &model.DownloadTask{TaskName: "t", Status: model.DownloadTaskStatusRunning, Checkpoint: `{"pages":2}`}
+	db.Create(task)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer trackRunningTask(task.ID, cancel)()
+
+	t.Run("Cancel", func(t *testing.T) {
+		if _, err := svc.Cancel(task.ID); err != nil {
+			t.Fatalf("Cancel failed: %v", err)
+		}
+		if !errors.Is(context.Cause(ctx), errTaskCancelled) {
+			t.Error("Expected running context to be cancelled")
+		}
+
+		var saved model.DownloadTask
+		db.First(&saved, task.ID)
+		if saved.Status != model.DownloadTaskStatusCancelled {
+			t.Errorf("Expected status cancelled, got %s", saved.Status)
+		}
+		if _, err := svc.Cancel(task.ID); err == nil {
+			t.Error("Expected cancelling a cancelled task to fail")
+		}
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		if _, err := svc.Retry(task.ID); err != nil {
+			t.Fatalf("Retry failed: %v", err)
+		}
+
+		var saved model.DownloadTask
+		db.First(&saved, task.ID)
+		if saved.Status != model.DownloadTaskStatusPending || saved.Checkpoint == "" {
+			t.Errorf("Expected pending task with checkpoint kept, got %s %q", saved.Status, saved.Checkpoint)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+)
+
+// downloadEventChannel 下载任务事件的 Redis 发布订阅频道
+const downloadEventChannel = "download_task:events"
+
+// downloadEventBuffer 每个订阅者的事件缓冲，写满时丢弃旧的进度事件
+const downloadEventBuffer = 32
+
+// DownloadTaskEvent 下载任务状态变更事件
+type DownloadTaskEvent struct {
+	TaskID         uint      `json:"task_id"`
+	UserID         uint      `json:"user_id"`
+	Status         string    `json:"status"`
+	Progress       int       `json:"progress"`
+	PagesFetched   int       `json:"pages_fetched"`
+	RecordsFetched int       `json:"records_fetched"`
+	Attempts       int       `json:"attempts"`
+	ErrorMsg       string    `json:"error_msg,omitempty"`
+	FileURL        string    `json:"file_url,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// Finished 任务是否已结束
+func (e *DownloadTaskEvent) Finished() bool {
+	switch e.Status {
+	case model.DownloadTaskStatusSuccess, model.DownloadTaskStatusFailed, model.DownloadTaskStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewDownloadTaskEvent 根据任务当前状态生成事件
+func NewDownloadTaskEvent(task *model.DownloadTask) DownloadTaskEvent {
+	return DownloadTaskEvent{
+		TaskID:         task.ID,
+		UserID:         task.UserID,
+		Status:         task.Status,
+		Progress:       task.Progress,
+		PagesFetched:   task.PagesFetched,
+		RecordsFetched: task.RecordsFetched,
+		Attempts:       task.Attempts,
+		ErrorMsg:       task.ErrorMsg,
+		FileURL:        task.FileURL,
+		Time:           time.Now(),
+	}
+}
+
+// DownloadEventHub 下载任务事件分发
+// 连接 Redis 时事件经发布订阅广播到所有实例，再分发给本实例的订阅者；
+// 未连接 Redis 时只在本实例内分发。
+type DownloadEventHub struct {
+	mu          sync.RWMutex
+	subscribers map[*downloadEventSubscriber]struct{}
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// downloadEventSubscriber 事件订阅者
+type downloadEventSubscriber struct {
+	events chan DownloadTaskEvent
+	filter func(event *DownloadTaskEvent) bool
+}
+
+var downloadEventHub = &DownloadEventHub{
+	subscribers: make(map[*downloadEventSubscriber]struct{}),
+}
+
+// GetDownloadEventHub 获取全局下载任务事件分发器
+func GetDownloadEventHub() *DownloadEventHub {
+	return downloadEventHub
+}
+
+// Start 订阅 Redis 频道，未连接 Redis 时不做任何事
+func (h *DownloadEventHub) Start() {
+	redisClient := database.GetRedis()
+	if redisClient == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	pubsub := redisClient.Subscribe(ctx, downloadEventChannel)
+	messages := pubsub.Channel()
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer pubsub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event DownloadTaskEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					logrus.Errorf("解析下载任务事件失败: %v", err)
+					continue
+				}
+				h.dispatch(&event)
+			}
+		}
+	}()
+
+	logrus.Info("下载任务事件订阅已启动")
+}
+
+// Stop 停止订阅 Redis 频道
+func (h *DownloadEventHub) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+}
+
+// Publish 发布事件
+func (h *DownloadEventHub) Publish(event DownloadTaskEvent) {
+	redisClient := database.GetRedis()
+	if redisClient == nil || h.cancel == nil {
+		h.dispatch(&event)
+		return
+	}
+
+	data, _ := json.Marshal(event)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := redisClient.Publish(ctx, downloadEventChannel, data).Err(); err != nil {
+		logrus.Errorf("发布下载任务事件失败: %v", err)
+		h.dispatch(&event)
+	}
+}
+
+// Subscribe 订阅满足条件的事件，返回事件通道和取消订阅函数
+func (h *DownloadEventHub) Subscribe(filter func(event *DownloadTaskEvent) bool) (<-chan DownloadTaskEvent, func()) {
+	sub := &downloadEventSubscriber{
+		events: make(chan DownloadTaskEvent, downloadEventBuffer),
+		filter: filter,
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub.events, func() {
+		h.mu.Lock()
+		delete(h.subscribers, sub)
+		h.mu.Unlock()
+	}
+}
+
+// dispatch 将事件分发给本实例的订阅者，订阅者处理不过来时丢弃事件
+func (h *DownloadEventHub) dispatch(event *DownloadTaskEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- *event:
+		default:
+		}
+	}
+}
+
+// publishTaskEvent 发布任务当前状态
+func publishTaskEvent(task *model.DownloadTask) {
+	downloadEventHub.Publish(NewDownloadTaskEvent(task))
+}
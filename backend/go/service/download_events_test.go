@@ -0,0 +1,43 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestDownloadEventHub(t *testing.T) {
+	setupTestModels(&model.DownloadTask{})
+	db := database.GetDB()
+
+	task := &model.DownloadTask{UserID: 7, TaskName: "t", Status: model.DownloadTaskStatusPending}
+	db.Create(task)
+	other := &model.DownloadTask{UserID: 8, TaskName: "other", Status: model.DownloadTaskStatusPending}
+	db.Create(other)
+
+	events, unsubscribe := GetDownloadEventHub().Subscribe(func(event *DownloadTaskEvent) bool {
+		return event.UserID == 7
+	})
+	defer unsubscribe()
+
+	svc := NewDownloadTaskService()
+	svc.Cancel(other.ID)
+	svc.Cancel(task.ID)
+
+	select {
+	case event := <-events:
+		if event.TaskID != task.ID || event.Status != model.DownloadTaskStatusCancelled || !event.Finished() {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a cancelled event")
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no more events, got %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,409 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
+	"github.com/ddoalistdownload/backend/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/xuri/excelize/v2"
+)
+
+// exportDir 获取导出文件目录
+func exportDir() string {
+	if config.GlobalConfig != nil && config.GlobalConfig.Download.ExportDir != "" {
+		return config.GlobalConfig.Download.ExportDir
+	}
+	return "./exports"
+}
+
+// storageRetention 获取导出文件保留时长
+func storageRetention() time.Duration {
+	if config.GlobalConfig != nil && config.GlobalConfig.Storage.Retention > 0 {
+		return config.GlobalConfig.Storage.Retention
+	}
+	return 7 * 24 * time.Hour
+}
+
+// signedURLExpire 获取签名下载地址的有效期
+func signedURLExpire() time.Duration {
+	if config.GlobalConfig != nil && config.GlobalConfig.Storage.SignedURLExpire > 0 {
+		return config.GlobalConfig.Storage.SignedURLExpire
+	}
+	return 15 * time.Minute
+}
+
+// exportContentTypes 导出格式对应的 Content-Type
+var exportContentTypes = map[string]string{
+	model.FileFormatJSON:  "application/json",
+	model.FileFormatCSV:   "text/csv; charset=utf-8",
+	model.FileFormatExcel: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// saveExport 在临时目录生成导出文件并上传到文件存储，返回下载结果元数据
+func saveExport(ctx context.Context, task *model.DownloadTask, columns []exportColumn, rows []map[string]interface{}) (*model.DownloadResult, error) {
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		return nil, errors.New("文件存储未初始化")
+	}
+
+	ext, err := exportFileExt(task.FileFormat)
+	if err != nil {
+		return nil, err
+	}
+	fileName := fmt.Sprintf("download_%d.%s", task.ID, ext)
+
+	// 先写入本地临时文件
+	tmpPath := filepath.Join(exportDir(), fmt.Sprintf("%d_%d.%s", task.ID, time.Now().UnixNano(), ext))
+	fileSize, err := writeExportFile(tmpPath, task.FileFormat, columns, rows)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	file, err := os.Open(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	now := time.Now()
+	key := fmt.Sprintf("exports/%d/%s/%s", task.CompanyID, now.Format("20060102"), fileName)
+	if err := fileStorage.Put(ctx, key, file, fileSize, exportContentTypes[task.FileFormat]); err != nil {
+		return nil, fmt.Errorf("上传导出文件失败: %v", err)
+	}
+
+	expiresAt := now.Add(storageRetention())
+	return &model.DownloadResult{
+		TaskID:      task.ID,
+		DataType:    task.FileFormat,
+		StorageType: fileStorage.Type(),
+		StorageKey:  key,
+		FileName:    fileName,
+		FileSize:    fileSize,
+		RecordCount: len(rows),
+		ExpiresAt:   &expiresAt,
+	}, nil
+}
+
+// exportColumn 导出列
+type exportColumn struct {
+	Field  string `json:"field"`            // 记录中的字段名
+	Label  string `json:"label"`            // 表头显示名称
+	Masked bool   `json:"masked,omitempty"` // 是否脱敏输出
+}
+
+// exportMaskValue 脱敏列输出的值
+const exportMaskValue = "******"
+
+// value 返回行中该列的导出值
+func (c exportColumn) value(row map[string]interface{}) interface{} {
+	if c.Masked {
+		return exportMaskValue
+	}
+	return row[c.Field]
+}
+
+// 按字段权限处理导出列的方式和原因
+const (
+	redactActionRemoved      = "removed"       // 移除列
+	redactActionMasked       = "masked"        // 保留列但脱敏
+	redactReasonReportHidden = "report_hidden" // 用户角色设置为不在报表中显示
+	redactReasonNotViewable  = "not_viewable"  // 用户角色设置为不可查看
+)
+
+// exportRedaction 按字段权限移除或脱敏的列
+type exportRedaction struct {
+	Field  string `json:"field"`
+	Label  string `json:"label"`
+	Action string `json:"action"` // removed, masked
+	Reason string `json:"reason"` // report_hidden, not_viewable
+}
+
+// hiddenFieldAction 返回不可查看字段的处理方式
+func hiddenFieldAction() string {
+	if config.GlobalConfig != nil && config.GlobalConfig.Download.HiddenField == "drop" {
+		return redactActionRemoved
+	}
+	return redactActionMasked
+}
+
+// redactColumns 按任务创建人的字段权限处理导出列：
+// 不在报表中显示的列移除，不可查看的列按配置脱敏或移除
+func redactColumns(userID uint, module string, columns []exportColumn) ([]exportColumn, []exportRedaction, error) {
+	if module == "" || len(columns) == 0 {
+		return columns, nil, nil
+	}
+
+	restricted, err := NewFieldPermissionService().ResolveFieldAccess(userID, module)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(restricted) == 0 {
+		return columns, nil, nil
+	}
+
+	kept := make([]exportColumn, 0, len(columns))
+	var redactions []exportRedaction
+	for _, column := range columns {
+		access, ok := restricted[column.Field]
+		switch {
+		case !ok:
+			kept = append(kept, column)
+			continue
+		case !access.ReportVisible:
+			redactions = append(redactions, exportRedaction{Field: column.Field, Label: column.Label, Action: redactActionRemoved, Reason: redactReasonReportHidden})
+			continue
+		}
+
+		action := hiddenFieldAction()
+		redactions = append(redactions, exportRedaction{Field: column.Field, Label: column.Label, Action: action, Reason: redactReasonNotViewable})
+		if action == redactActionMasked {
+			column.Masked = true
+			kept = append(kept, column)
+		}
+	}
+
+	return kept, redactions, nil
+}
+
+// normalizeRecords 将记录统一为对象，非对象记录放在 value 列中
+func normalizeRecords(records []interface{}) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		if row, ok := record.(map[string]interface{}); ok {
+			rows = append(rows, row)
+		} else {
+			rows = append(rows, map[string]interface{}{"value": record})
+		}
+	}
+	return rows
+}
+
+// buildExportColumns 汇总所有记录出现过的字段作为导出列，按字段名排序保证列顺序稳定
+func buildExportColumns(rows []map[string]interface{}) []exportColumn {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, row := range rows {
+		for field := range row {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	columns := make([]exportColumn, 0, len(fields))
+	for _, field := range fields {
+		columns = append(columns, exportColumn{Field: field, Label: field})
+	}
+	return columns
+}
+
+// labelColumns 使用数据字典中字段定义（Value 为空）的 Label 作为表头
+func labelColumns(module string, columns []exportColumn) {
+	if module == "" || len(columns) == 0 {
+		return
+	}
+
+	var dicts []model.DataDictionary
+	if err := database.GetDB().
+		Where("module = ? AND status = 1 AND (value = '' OR value IS NULL)", module).
+		Find(&dicts).Error; err != nil {
+		logrus.Errorf("查询数据字典失败: %v", err)
+		return
+	}
+
+	labels := make(map[string]string, len(dicts))
+	for _, dict := range dicts {
+		labels[dict.Field] = dict.Label
+	}
+	for i := range columns {
+		if label, ok := labels[columns[i].Field]; ok && label != "" {
+			columns[i].Label = label
+		}
+	}
+}
+
+// exportModule 返回API配置对应的数据字典模块名
+func exportModule(apiConfig *model.APIConfig) string {
+	if apiConfig.Module != "" {
+		return apiConfig.Module
+	}
+	return apiConfig.Code
+}
+
+// exportFileExt 返回导出格式对应的文件扩展名，不支持的格式返回错误
+func exportFileExt(format string) (string, error) {
+	switch format {
+	case model.FileFormatJSON, model.FileFormatCSV, model.FileFormatExcel:
+		return format, nil
+	default:
+		return "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// writeExportFile 按格式写出导出文件，返回文件大小
+func writeExportFile(path, format string, columns []exportColumn, rows []map[string]interface{}) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("创建导出目录失败: %v", err)
+	}
+
+	var err error
+	switch format {
+	case model.FileFormatCSV:
+		err = writeCSVFile(path, columns, rows)
+	case model.FileFormatExcel:
+		err = writeExcelFile(path, columns, rows)
+	default:
+		err = writeJSONFile(path, columns, rows)
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// writeJSONFile 以对象数组写出，对象只包含导出列
+func writeJSONFile(path string, columns []exportColumn, rows []map[string]interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	writer.WriteString("[")
+	for i, row := range rows {
+		item := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			item[column.Field] = column.value(row)
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			writer.WriteString(",\n")
+		}
+		writer.Write(data)
+	}
+	writer.WriteString("]\n")
+	return writer.Flush()
+}
+
+// writeCSVFile 写出带 UTF-8 BOM 的 CSV，保证 Excel 打开中文不乱码
+func writeCSVFile(path string, columns []exportColumn, rows []map[string]interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString("\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Label
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = exportCellString(column.value(row))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeExcelFile 使用流式写入生成 xlsx，避免大数据量时占用过多内存
+func writeExcelFile(path string, columns []exportColumn, rows []map[string]interface{}) error {
+	file := excelize.NewFile()
+	defer file.Close()
+
+	sheet := file.GetSheetName(0)
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.Label
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	for r, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = exportCellValue(column.value(row))
+		}
+		cell, err := excelize.CoordinatesToCellName(1, r+2)
+		if err != nil {
+			return err
+		}
+		if err := stream.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Flush(); err != nil {
+		return err
+	}
+	return file.SaveAs(path)
+}
+
+// exportCellValue 返回写入 Excel 的单元格值，数字和布尔保持原类型
+func exportCellValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64, int, bool:
+		return v
+	default:
+		return exportCellString(v)
+	}
+}
+
+// exportCellString 返回单元格的字符串形式，对象和数组序列化为 JSON
+func exportCellString(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return utils.ToString(v)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestWriteExportFile(t *testing.T) {
+	rows := normalizeRecords([]interface{}{
+		map[string]interface{}{"name": "张三", "age": float64(30), "tags": []interface{}{"a"}},
+		map[string]interface{}{"name": "李四", "dept": "研发部"},
+	})
+	columns := buildExportColumns(rows)
+	columns[2].Label = "姓名"
+
+	t.Run("CSV", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.csv")
+		if _, err := writeExportFile(path, model.FileFormatCSV, columns, rows); err != nil {
+			t.Fatalf("writeExportFile failed: %v", err)
+		}
+
+		file, _ := os.Open(path)
+		defer file.Close()
+		lines, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			t.Fatalf("read csv failed: %v", err)
+		}
+		header := strings.TrimPrefix(strings.Join(lines[0], ","), "\xEF\xBB\xBF")
+		if header != "age,dept,姓名,tags" {
+			t.Errorf("Unexpected header: %s", header)
+		}
+		if strings.Join(lines[1], ",") != `30,,张三,["a"]` {
+			t.Errorf("Unexpected row: %v", lines[1])
+		}
+	})
+
+	t.Run("Excel", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.xlsx")
+		if _, err := writeExportFile(path, model.FileFormatExcel, columns, rows); err != nil {
+			t.Fatalf("writeExportFile failed: %v", err)
+		}
+
+		file, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatalf("open xlsx failed: %v", err)
+		}
+		defer file.Close()
+		sheetRows, _ := file.GetRows(file.GetSheetName(0))
+		if len(sheetRows) != 3 || sheetRows[2][2] != "李四" || sheetRows[2][1] != "研发部" {
+			t.Errorf("Unexpected rows: %v", sheetRows)
+		}
+	})
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultMappingDateFormat 未配置 date_format 时的日期格式
+const defaultMappingDateFormat = "2006-01-02 15:04:05"
+
+// maxMappingWarnings 最多保留的转换警告条数
+const maxMappingWarnings = 20
+
+// mappingDateFormatReplacer 将 YYYY-MM-DD HH:mm:ss 风格的格式转为 Go 的时间格式
+var mappingDateFormatReplacer = strings.NewReplacer(
+	"YYYY", "2006", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05",
+)
+
+// mappingDateLayouts 解析日期字符串时依次尝试的格式
+var mappingDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102",
+}
+
+// mappingPreviewRows 映射预览最多返回的行数
+const mappingPreviewRows = 50
+
+// MappingPreview 映射预览结果
+type MappingPreview struct {
+	RecordPath string                   `json:"record_path"` // 记录数组的路径
+	Columns    []exportColumn           `json:"columns"`     // 导出列
+	Rows       []map[string]interface{} `json:"rows"`        // 映射后的行，最多 50 行
+	Total      int                      `json:"total"`       // 示例响应中的记录数
+	Warnings   []string                 `json:"warnings"`    // 值转换失败的提示
+}
+
+// PreviewMapping 将API配置中的映射应用到示例响应，用于保存前检查映射效果
+func (s *APIConfigService) PreviewMapping(apiConfig *model.APIConfig, sample map[string]interface{}) (*MappingPreview, error) {
+	mapping, err := parseAPIMapping(apiConfig.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &MappingPreview{RecordPath: apiConfig.RecordPath}
+	records := extractRecords(sample, preview.RecordPath)
+	preview.Total = len(records)
+	if len(records) > mappingPreviewRows {
+		records = records[:mappingPreviewRows]
+	}
+
+	preview.Columns, preview.Rows, preview.Warnings, err = buildExport(exportModule(apiConfig), mapping, records)
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// parseAPIMapping 解析映射配置，未配置时返回 nil
+func parseAPIMapping(raw string) (*model.APIMapping, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var mapping model.APIMapping
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("映射配置格式错误: %v", err)
+	}
+
+	if mapping.Timezone == "" {
+		mapping.Timezone = "Asia/Shanghai"
+	}
+	if _, err := time.LoadLocation(mapping.Timezone); err != nil {
+		return nil, fmt.Errorf("映射配置时区无效: %s", mapping.Timezone)
+	}
+
+	targets := make(map[string]bool, len(mapping.Columns))
+	for i := range mapping.Columns {
+		column := &mapping.Columns[i]
+		if column.Source == "" {
+			return nil, fmt.Errorf("映射第%d列未配置 source", i+1)
+		}
+		if column.Target == "" {
+			column.Target = column.Source
+		}
+		if targets[column.Target] {
+			return nil, fmt.Errorf("映射列名重复: %s", column.Target)
+		}
+		targets[column.Target] = true
+
+		switch column.Type {
+		case "", model.MappingTypeString, model.MappingTypeInt, model.MappingTypeFloat, model.MappingTypeBool, model.MappingTypeDate:
+		default:
+			return nil, fmt.Errorf("映射列 %s 的类型不支持: %s", column.Target, column.Type)
+		}
+	}
+
+	return &mapping, nil
+}
+
+// buildExport 将记录转为导出列和行，配置了映射列时按映射输出，同时返回转换警告
+func buildExport(module string, mapping *model.APIMapping, records []interface{}) ([]exportColumn, []map[string]interface{}, []string, error) {
+	if mapping == nil || len(mapping.Columns) == 0 {
+		rows := normalizeRecords(records)
+		columns := buildExportColumns(rows)
+		labelColumns(module, columns)
+		return columns, rows, nil, nil
+	}
+
+	mapper, err := newRecordMapper(module, mapping)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(records))
+	for i, record := range records {
+		rows = append(rows, mapper.mapRecord(i, record))
+	}
+	return mapper.columns(), rows, mapper.warnings, nil
+}
+
+// recordMapper 按映射配置将原始记录转为导出行
+type recordMapper struct {
+	module       string
+	mapping      *model.APIMapping
+	location     *time.Location
+	dictionaries map[string]map[string]string // 字段 → Value → Label
+	warnings     []string
+}
+
+// newRecordMapper 创建映射器并加载列用到的数据字典
+func newRecordMapper(module string, mapping *model.APIMapping) (*recordMapper, error) {
+	location, err := time.LoadLocation(mapping.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("映射配置时区无效: %s", mapping.Timezone)
+	}
+
+	mapper := &recordMapper{
+		module:       module,
+		mapping:      mapping,
+		location:     location,
+		dictionaries: make(map[string]map[string]string),
+	}
+
+	var fields []string
+	for _, column := range mapping.Columns {
+		if column.Dictionary != "" {
+			fields = append(fields, column.Dictionary)
+		}
+	}
+	if len(fields) == 0 {
+		return mapper, nil
+	}
+	if module == "" {
+		return nil, errors.New("字典翻译需要配置API的模块名")
+	}
+
+	var dicts []model.DataDictionary
+	if err := database.GetDB().
+		Where("module = ? AND field IN ? AND status = 1 AND value <> ''", module, fields).
+		Order("sort").
+		Find(&dicts).Error; err != nil {
+		logrus.Errorf("查询数据字典失败: %v", err)
+		return nil, err
+	}
+	for _, dict := range dicts {
+		if mapper.dictionaries[dict.Field] == nil {
+			mapper.dictionaries[dict.Field] = make(map[string]string)
+		}
+		mapper.dictionaries[dict.Field][dict.Value] = dict.Label
+	}
+
+	return mapper, nil
+}
+
+// columns 返回映射的导出列，未配置 label 时使用数据字典中的字段名称
+func (m *recordMapper) columns() []exportColumn {
+	columns := make([]exportColumn, len(m.mapping.Columns))
+	for i, column := range m.mapping.Columns {
+		columns[i] = exportColumn{Field: column.Target, Label: column.Target}
+	}
+	labelColumns(m.module, columns)
+	for i, column := range m.mapping.Columns {
+		if column.Label != "" {
+			columns[i].Label = column.Label
+		}
+	}
+	return columns
+}
+
+// mapRecord 按映射列取值并转换，转换失败时保留原值并记录警告
+func (m *recordMapper) mapRecord(index int, record interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(m.mapping.Columns))
+	for _, column := range m.mapping.Columns {
+		value, ok := utils.GetPath(record, column.Source)
+		if !ok || value == nil {
+			row[column.Target] = column.Default
+			continue
+		}
+
+		converted, err := m.convert(&column, value)
+		if err != nil {
+			m.warn(fmt.Sprintf("第%d条记录 %s: %v", index+1, column.Target, err))
+			converted = value
+		}
+		row[column.Target] = converted
+	}
+	return row
+}
+
+// warn 记录转换警告
+func (m *recordMapper) warn(message string) {
+	if len(m.warnings) < maxMappingWarnings {
+		m.warnings = append(m.warnings, message)
+	}
+}
+
+// convert 按字典翻译或类型转换处理取到的值
+func (m *recordMapper) convert(column *model.APIMappingColumn, value interface{}) (interface{}, error) {
+	if column.Dictionary != "" {
+		if label, ok := m.translate(column.Dictionary, value); ok {
+			return label, nil
+		}
+	}
+
+	switch column.Type {
+	case model.MappingTypeString:
+		return utils.ToString(value), nil
+	case model.MappingTypeInt:
+		f, ok := utils.ToFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("无法转换为数字: %v", value)
+		}
+		return int(math.Trunc(f)), nil
+	case model.MappingTypeFloat:
+		f, ok := utils.ToFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("无法转换为数字: %v", value)
+		}
+		return f, nil
+	case model.MappingTypeBool:
+		b, ok := utils.ParseBool(value)
+		if !ok {
+			return nil, fmt.Errorf("无法转换为布尔值: %v", value)
+		}
+		return b, nil
+	case model.MappingTypeDate:
+		t, err := m.toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		layout := defaultMappingDateFormat
+		if column.DateFormat != "" {
+			layout = mappingDateFormatReplacer.Replace(column.DateFormat)
+		}
+		return t.In(m.location).Format(layout), nil
+	default:
+		return value, nil
+	}
+}
+
+// translate 按数据字典翻译取值，数组中的每个值分别翻译后以逗号连接
+func (m *recordMapper) translate(field string, value interface{}) (string, bool) {
+	labels := m.dictionaries[field]
+	if len(labels) == 0 {
+		return "", false
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		translated := make([]string, 0, len(list))
+		for _, item := range list {
+			label, ok := labels[utils.ToString(item)]
+			if !ok {
+				return "", false
+			}
+			translated = append(translated, label)
+		}
+		return strings.Join(translated, ","), true
+	}
+
+	label, ok := labels[utils.ToString(value)]
+	return label, ok
+}
+
+// toTime 解析毫秒/秒时间戳或常见格式的日期字符串，不带时区的字符串按映射时区解析
+// 只有数字以及 10 位或 13 位的数字字符串视为时间戳，20240105 等按日期格式解析
+func (m *recordMapper) toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case bool:
+		return time.Time{}, fmt.Errorf("无法解析日期: %v", v)
+	case float64, json.Number:
+		f, _ := utils.ToFloat(v)
+		return unixTime(f), nil
+	}
+
+	s := strings.TrimSpace(utils.ToString(value))
+	if isTimestampString(s) {
+		f, _ := utils.ToFloat(s)
+		return unixTime(f), nil
+	}
+	for _, layout := range mappingDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, m.location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析日期: %s", s)
+}
+
+// unixTime 将秒或毫秒时间戳转换为时间，大于 1e11 视为毫秒时间戳
+func unixTime(f float64) time.Time {
+	if math.Abs(f) > 1e11 {
+		return time.UnixMilli(int64(f))
+	}
+	return time.Unix(int64(f), 0)
+}
+
+// isTimestampString 是否为 10 位秒或 13 位毫秒时间戳字符串
+func isTimestampString(s string) bool {
+	if len(s) != 10 && len(s) != 13 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestPreviewMapping(t *testing.T) {
+	setupTestModels(&model.DataDictionary{})
+	db := database.GetDB()
+
+	db.Create(&model.DataDictionary{Module: "approval", Field: "status", Label: "审批状态"})
+	db.Create(&model.DataDictionary{Module: "approval", Field: "status", Label: "已完成", Value: "COMPLETED"})
+	db.Create(&model.DataDictionary{Module: "approval", Field: "status", Label: "审批中", Value: "RUNNING"})
+
+	apiConfig := &model.APIConfig{
+		Module:     "approval",
+		RecordPath: "result.data_list",
+		Mapping: `{
+			"columns": [
+				{"source": "process_instance_id", "target": "id", "label": "实例ID"},
+				{"source": "status", "dictionary": "status"},
+				{"source": "create_time", "target": "created", "type": "date", "date_format": "YYYY-MM-DD HH:mm"},
+				{"source": "form.amount", "target": "amount", "type": "float"},
+				{"source": "missing", "default": "-"}
+			]
+		}`,
+	}
+	sample := map[string]interface{}{
+		"result": map[string]interface{}{
+			"data_list": []interface{}{
+				map[string]interface{}{"process_instance_id": "p1", "status": "COMPLETED", "create_time": 1700000000000.0, "form": map[string]interface{}{"amount": "12.5"}},
+				map[string]interface{}{"process_instance_id": "p2", "status": "RUNNING", "create_time": "2023-11-15 06:13:20", "form": map[string]interface{}{"amount": "n/a"}},
+			},
+		},
+	}
+
+	preview, err := NewAPIConfigService().PreviewMapping(apiConfig, sample)
+	if err != nil {
+		t.Fatalf("PreviewMapping failed: %v", err)
+	}
+	if preview.Total != 2 || len(preview.Columns) != 5 {
+		t.Fatalf("Expected 2 records and 5 columns, got %d %d", preview.Total, len(preview.Columns))
+	}
+	if preview.Columns[0].Label != "实例ID" || preview.Columns[1].Label != "审批状态" {
+		t.Errorf("Unexpected column labels: %+v", preview.Columns)
+	}
+
+	first := preview.Rows[0]
+	if first["status"] != "已完成" || first["created"] != "2023-11-15 06:13" || first["amount"] != 12.5 || first["missing"] != "-" {
+		t.Errorf("Unexpected first row: %+v", first)
+	}
+	if second := preview.Rows[1]; second["status"] != "审批中" || second["created"] != "2023-11-15 06:13" || second["amount"] != "n/a" {
+		t.Errorf("Unexpected second row: %+v", second)
+	}
+	if len(preview.Warnings) != 1 {
+		t.Errorf("Expected 1 conversion warning, got %v", preview.Warnings)
+	}
+
+	if _, err := parseAPIMapping(`{"columns":[{"source":"a","type":"money"}]}`); err == nil {
+		t.Error("Expected unsupported type to fail")
+	}
+}
+
+func TestMappingToTime(t *testing.T) {
+	mapper := &recordMapper{location: time.UTC}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		ok    bool
+	}{
+		{"Seconds", 1700000000.0, "2023-11-14 22:13:20", true},
+		{"Milliseconds", 1700000000000.0, "2023-11-14 22:13:20", true},
+		{"JSONNumber", json.Number("1700000000"), "2023-11-14 22:13:20", true},
+		{"SecondsString", "1700000000", "2023-11-14 22:13:20", true},
+		{"MillisecondsString", "1700000000000", "2023-11-14 22:13:20", true},
+		{"CompactDate", "20240105", "2024-01-05 00:00:00", true},
+		{"DateTime", "2024-01-05 08:30:00", "2024-01-05 08:30:00", true},
+		{"Bool", true, "", false},
+		{"Invalid", "yesterday", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapper.toTime(tt.value)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Expected ok %v, got %v", tt.ok, err)
+			}
+			if err == nil && got.In(time.UTC).Format("2006-01-02 15:04:05") != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got.In(time.UTC))
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultMaxPages 未配置 MaxPages 时最多请求的页数
+const defaultMaxPages = 100
+
+// pageProgress 分页进度，同时作为断点保存在任务中
+type pageProgress struct {
+	Pages   int         `json:"pages"`   // 已获取页数
+	Records int         `json:"records"` // 已获取记录数
+	Total   int         `json:"total"`   // 响应中声明的总记录数，未知时为 0
+	Cursor  interface{} `json:"cursor"`  // 下一页的游标
+	Done    bool        `json:"done"`    // 是否已获取全部分页
+}
+
+// pageWalker 按API配置的分页描述逐页请求并合并记录
+type pageWalker struct {
+	client     *APIClient
+	apiConfig  *model.APIConfig
+	pagination *model.APIPagination // 为 nil 时只请求一次
+	// onPage 每页完成后回调，返回错误时停止请求
+	onPage func(progress pageProgress, records []interface{}) error
+}
+
+// newPageWalker 创建分页遍历器
+func newPageWalker(client *APIClient, apiConfig *model.APIConfig) (*pageWalker, error) {
+	pagination, err := parseAPIPagination(apiConfig.Pagination)
+	if err != nil {
+		return nil, err
+	}
+	return &pageWalker{
+		client:     client,
+		apiConfig:  apiConfig,
+		pagination: pagination,
+	}, nil
+}
+
+// parseAPIPagination 解析分页配置，未配置时返回 nil
+func parseAPIPagination(raw string) (*model.APIPagination, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var pagination model.APIPagination
+	if err := json.Unmarshal([]byte(raw), &pagination); err != nil {
+		return nil, fmt.Errorf("分页配置格式错误: %v", err)
+	}
+	if pagination.Mode == "" {
+		return nil, nil
+	}
+
+	switch pagination.Mode {
+	case model.PaginationModeCursor:
+		if pagination.CursorParam == "" || pagination.CursorPath == "" {
+			return nil, fmt.Errorf("游标分页需要配置 cursor_param 和 cursor_path")
+		}
+	case model.PaginationModeOffset, model.PaginationModePage:
+		if pagination.CursorParam == "" {
+			return nil, fmt.Errorf("%s 分页需要配置 cursor_param", pagination.Mode)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的分页方式: %s", pagination.Mode)
+	}
+
+	if pagination.Mode == model.PaginationModePage && pagination.StartValue == 0 {
+		pagination.StartValue = 1
+	}
+	if pagination.MaxPages <= 0 {
+		pagination.MaxPages = defaultMaxPages
+	}
+
+	return &pagination, nil
+}
+
+// Walk 请求所有分页并返回本次获取的记录
+// from 不为空时从断点的下一页继续；出错时同时返回已获取的记录，便于调用方记录进度
+func (w *pageWalker) Walk(ctx context.Context, params map[string]interface{}, headers map[string]string, from *pageProgress) ([]interface{}, error) {
+	progress := pageProgress{}
+	if from != nil {
+		progress = *from
+	}
+	if progress.Done {
+		return nil, nil
+	}
+
+	requestParams := make(map[string]interface{}, len(params)+2)
+	for k, v := range params {
+		requestParams[k] = v
+	}
+
+	p := w.pagination
+	if p == nil {
+		resp, err := w.fetch(ctx, requestParams, headers)
+		if err != nil {
+			return nil, err
+		}
+		records := extractRecords(resp.Data, w.apiConfig.RecordPath)
+		return records, w.report(pageProgress{Pages: 1, Records: len(records), Done: true}, records)
+	}
+
+	if p.PageSizeParam != "" && p.PageSize > 0 {
+		requestParams[p.PageSizeParam] = p.PageSize
+	}
+
+	// 游标分页允许在任务参数中指定起始游标
+	var cursor interface{}
+	switch {
+	case progress.Pages > 0:
+		cursor = progress.Cursor
+	case p.Mode == model.PaginationModeCursor:
+		cursor = requestParams[p.CursorParam]
+	default:
+		cursor = p.StartValue
+	}
+
+	var records []interface{}
+	for progress.Pages < p.MaxPages {
+		page := progress.Pages + 1
+		if cursor != nil {
+			requestParams[p.CursorParam] = cursor
+		}
+
+		resp, err := w.fetch(ctx, requestParams, headers)
+		if err != nil {
+			return records, fmt.Errorf("第%d页请求失败: %v", page, err)
+		}
+
+		pageRecords := extractRecords(resp.Data, w.apiConfig.RecordPath)
+		records = append(records, pageRecords...)
+
+		next, hasMore := w.nextCursor(resp.Data, cursor, len(pageRecords))
+		progress.Pages = page
+		progress.Records += len(pageRecords)
+		progress.Cursor = next
+		progress.Done = !hasMore || page >= p.MaxPages
+		if p.TotalPath != "" {
+			if total, ok := utils.GetPath(resp.Data, p.TotalPath); ok {
+				progress.Total, _ = utils.ToInt(total)
+			}
+		}
+		if err := w.report(progress, pageRecords); err != nil {
+			return records, err
+		}
+
+		if !hasMore {
+			return records, nil
+		}
+		cursor = next
+	}
+
+	logrus.Warnf("API配置ID: %d 已达到最大分页数 %d，停止请求", w.apiConfig.ID, p.MaxPages)
+	return records, nil
+}
+
+// fetch 请求一页数据并检查错误码
+func (w *pageWalker) fetch(ctx context.Context, params map[string]interface{}, headers map[string]string) (*APIResponse, error) {
+	resp, err := w.client.Do(ctx, w.apiConfig, params, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.CheckError(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// nextCursor 根据本页响应计算下一页的游标以及是否还有下一页
+func (w *pageWalker) nextCursor(data map[string]interface{}, cursor interface{}, count int) (interface{}, bool) {
+	p := w.pagination
+
+	hasMore := true
+	if p.HasMorePath != "" {
+		value, ok := utils.GetPath(data, p.HasMorePath)
+		hasMore = ok && utils.ToBool(value)
+	}
+
+	switch p.Mode {
+	case model.PaginationModeCursor:
+		next, ok := utils.GetPath(data, p.CursorPath)
+		if !ok || utils.ToString(next) == "" {
+			return nil, false
+		}
+		return next, hasMore
+	default:
+		// 没有 has_more 字段时，以本页是否取满判断是否还有下一页
+		if p.HasMorePath == "" {
+			hasMore = p.PageSize > 0 && count >= p.PageSize
+		}
+		if count == 0 {
+			hasMore = false
+		}
+
+		current, _ := utils.ToInt(cursor)
+		if p.Mode == model.PaginationModePage {
+			return current + 1, hasMore
+		}
+		step := p.PageSize
+		if step <= 0 {
+			step = count
+		}
+		return current + step, hasMore
+	}
+}
+
+// report 回调分页进度
+func (w *pageWalker) report(progress pageProgress, records []interface{}) error {
+	if w.onPage != nil {
+		return w.onPage(progress, records)
+	}
+	return nil
+}
+
+// extractRecords 从响应中取出记录列表
+// 未配置路径时整个响应作为一条记录；路径指向对象时视为单条记录
+func extractRecords(data map[string]interface{}, recordPath string) []interface{} {
+	if recordPath == "" {
+		return []interface{}{data}
+	}
+
+	value, ok := utils.GetPath(data, recordPath)
+	if !ok || value == nil {
+		return nil
+	}
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestPageWalker(t *testing.T) {
+	setupTestModels(&model.AccessToken{})
+
+	// 模拟钉钉列表接口：共 5 条记录，每页 2 条
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		start := 0
+		if cursor, ok := body["cursor"].(float64); ok {
+			start = int(cursor)
+		}
+		if offset, ok := body["offset"].(float64); ok {
+			start = int(offset)
+		}
+		size := int(body["size"].(float64))
+
+		var list []interface{}
+		for i := start; i < start+size && i < 5; i++ {
+			list = append(list, map[string]interface{}{"id": strconv.Itoa(i)})
+		}
+		result := map[string]interface{}{"list": list, "has_more": start+size < 5}
+		if start+size < 5 {
+			result["next_cursor"] = start + size
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 0, "result": result})
+	}))
+	defer server.Close()
+
+	walk := func(t *testing.T, pagination string, from *pageProgress) ([]interface{}, []pageProgress) {
+		apiConfig := &model.APIConfig{
+			BaseURL:    server.URL,
+			Path:       "/topapi/list",
+			Method:     "POST",
+			RecordPath: "result.list",
+			Pagination: pagination,
+		}
+		walker, err := newPageWalker(NewAPIClient(), apiConfig)
+		if err != nil {
+			t.Fatalf("newPageWalker failed: %v", err)
+		}
+		var progresses []pageProgress
+		walker.onPage = func(progress pageProgress, records []interface{}) error {
+			progresses = append(progresses, progress)
+			return nil
+		}
+		records, err := walker.Walk(context.Background(), nil, nil, from)
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		return records, progresses
+	}
+
+	t.Run("Cursor", func(t *testing.T) {
+		records, progresses := walk(t, `{"mode":"cursor","cursor_param":"cursor","cursor_path":"result.next_cursor","page_size_param":"size","page_size":2,"has_more_path":"result.has_more"}`, nil)
+		if len(records) != 5 {
+			t.Fatalf("Expected 5 records, got %d", len(records))
+		}
+		if len(progresses) != 3 || progresses[2].Records != 5 {
+			t.Errorf("Unexpected progress: %+v", progresses)
+		}
+	})
+
+	t.Run("Offset", func(t *testing.T) {
+		records, _ := walk(t, `{"mode":"offset","cursor_param":"offset","page_size_param":"size","page_size":2}`, nil)
+		if len(records) != 5 {
+			t.Fatalf("Expected 5 records, got %d", len(records))
+		}
+		if last := records[4].(map[string]interface{})["id"]; last != "4" {
+			t.Errorf("Expected last record id 4, got %v", last)
+		}
+	})
+
+	t.Run("MaxPages", func(t *testing.T) {
+		records, _ := walk(t, `{"mode":"cursor","cursor_param":"cursor","cursor_path":"result.next_cursor","page_size_param":"size","page_size":2,"max_pages":2}`, nil)
+		if len(records) != 4 {
+			t.Errorf("Expected 4 records, got %d", len(records))
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		pagination := `{"mode":"offset","cursor_param":"offset","page_size_param":"size","page_size":2}`
+		_, progresses := walk(t, pagination, nil)
+
+		// 断点经过 JSON 序列化保存，游标会变为 float64
+		var checkpoint pageProgress
+		data, _ := json.Marshal(progresses[0])
+		json.Unmarshal(data, &checkpoint)
+
+		records, resumed := walk(t, pagination, &checkpoint)
+		if len(records) != 3 || records[0].(map[string]interface{})["id"] != "2" {
+			t.Fatalf("Expected to resume from record 2, got %v", records)
+		}
+		if last := resumed[len(resumed)-1]; !last.Done || last.Pages != 3 || last.Records != 5 {
+			t.Errorf("Unexpected final progress: %+v", last)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DownloadQueue 下载任务队列
+// 任务以 pending 状态持久化在数据库中，内存通道只负责唤醒 worker；
+// 通道写满或服务重启时，由轮询协程从数据库补齐待执行的任务。
+type DownloadQueue struct {
+	cfg     config.DownloadConfig
+	service *DownloadTaskService
+	tasks   chan uint
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+var downloadQueue *DownloadQueue
+
+// InitDownloadQueue 初始化全局下载任务队列
+func InitDownloadQueue(cfg config.DownloadConfig) *DownloadQueue {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < 1 {
+		cfg.QueueSize = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 10 * time.Second
+	}
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 30 * time.Second
+	}
+	if cfg.StaleTimeout > 0 && cfg.StaleTimeout < 3*cfg.Heartbeat {
+		logrus.Warnf("下载任务中断判定时间(%s)小于心跳间隔的3倍，调整为 %s", cfg.StaleTimeout, 3*cfg.Heartbeat)
+		cfg.StaleTimeout = 3 * cfg.Heartbeat
+	}
+
+	downloadQueue = &DownloadQueue{
+		cfg:     cfg,
+		service: NewDownloadTaskService(),
+		tasks:   make(chan uint, cfg.QueueSize),
+	}
+	return downloadQueue
+}
+
+// GetDownloadQueue 获取全局下载任务队列，未初始化时返回 nil
+func GetDownloadQueue() *DownloadQueue {
+	return downloadQueue
+}
+
+// Start 恢复中断的任务并启动 worker 和轮询协程
+func (q *DownloadQueue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	q.recoverStaleTasks()
+
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+
+	q.wg.Add(1)
+	go q.poll(ctx)
+
+	logrus.Infof("下载任务队列已启动，worker数量: %d, 队列深度: %d", q.cfg.Workers, q.cfg.QueueSize)
+}
+
+// Stop 停止队列，正在执行的任务会被取消并放回 pending
+func (q *DownloadQueue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+	logrus.Info("下载任务队列已停止")
+}
+
+// Enqueue 投递任务ID，队列已满时返回 false，任务等待下一次轮询
+func (q *DownloadQueue) Enqueue(taskID uint) bool {
+	select {
+	case q.tasks <- taskID:
+		return true
+	default:
+		logrus.Warnf("下载任务队列已满，任务ID: %d 将由轮询调度", taskID)
+		return false
+	}
+}
+
+// worker 从队列中取出任务并执行
+func (q *DownloadQueue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case taskID := <-q.tasks:
+			task, ok := q.claim(taskID)
+			if !ok {
+				continue
+			}
+			q.execute(ctx, task)
+		}
+	}
+}
+
+// execute 执行任务，执行期间保持心跳
+func (q *DownloadQueue) execute(ctx context.Context, task *model.DownloadTask) {
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.heartbeat(heartbeatCtx, task.ID)
+	}()
+
+	q.service.ExecuteTask(ctx, task)
+	stopHeartbeat()
+	<-done
+}
+
+// heartbeat 定期刷新运行中任务的 updated_at，直到 ctx 结束
+// 导出、上传等长时间不更新进度的阶段也不会被其他实例当作中断任务回收
+func (q *DownloadQueue) heartbeat(ctx context.Context, taskID uint) {
+	ticker := time.NewTicker(q.cfg.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := database.GetDB().Model(&model.DownloadTask{}).
+				Where("id = ? AND status = ?", taskID, model.DownloadTaskStatusRunning).
+				Update("updated_at", time.Now()).Error; err != nil {
+				logrus.Errorf("刷新下载任务心跳失败，任务ID: %d, 错误: %v", taskID, err)
+			}
+		}
+	}
+}
+
+// claim 将任务从 pending 原子地更新为 running 并累加执行次数
+// 多个实例或重复投递时只有一个 worker 能抢到任务
+func (q *DownloadQueue) claim(taskID uint) (*model.DownloadTask, bool) {
+	db := database.GetDB()
+
+	now := time.Now()
+	result := db.Model(&model.DownloadTask{}).
+		Where("id = ? AND status = ?", taskID, model.DownloadTaskStatusPending).
+		Updates(map[string]interface{}{
+			"status":     model.DownloadTaskStatusRunning,
+			"progress":   0,
+			"error_msg":  "",
+			"started_at": now,
+			"attempts":   gorm.Expr("attempts + 1"),
+		})
+	if result.Error != nil {
+		logrus.Errorf("领取下载任务失败，任务ID: %d, 错误: %v", taskID, result.Error)
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	var task model.DownloadTask
+	if err := db.First(&task, taskID).Error; err != nil {
+		logrus.Errorf("获取下载任务失败，任务ID: %d, 错误: %v", taskID, err)
+		return nil, false
+	}
+	publishTaskEvent(&task)
+
+	return &task, true
+}
+
+// poll 定期从数据库补齐 pending 任务并回收中断的任务
+func (q *DownloadQueue) poll(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+
+	q.fillFromDB()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.recoverStaleTasks()
+			q.fillFromDB()
+		}
+	}
+}
+
+// fillFromDB 按创建顺序将数据库中的 pending 任务放入空闲的队列位置
+func (q *DownloadQueue) fillFromDB() {
+	free := cap(q.tasks) - len(q.tasks)
+	if free <= 0 {
+		return
+	}
+
+	var taskIDs []uint
+	if err := database.GetDB().Model(&model.DownloadTask{}).
+		Where("status = ?", model.DownloadTaskStatusPending).
+		Order("id ASC").Limit(free).
+		Pluck("id", &taskIDs).Error; err != nil {
+		logrus.Errorf("查询待执行下载任务失败: %v", err)
+		return
+	}
+
+	for _, id := range taskIDs {
+		if !q.Enqueue(id) {
+			return
+		}
+	}
+}
+
+// recoverStaleTasks 将长时间未更新的 running 任务放回 pending
+// 执行中的任务由心跳持续刷新 updated_at，超过 StaleTimeout 未刷新说明执行它的进程已崩溃或重启
+func (q *DownloadQueue) recoverStaleTasks() {
+	if q.cfg.StaleTimeout <= 0 {
+		return
+	}
+
+	result := database.GetDB().Model(&model.DownloadTask{}).
+		Where("status = ? AND updated_at < ?", model.DownloadTaskStatusRunning, time.Now().Add(-q.cfg.StaleTimeout)).
+		Updates(map[string]interface{}{
+			"status":     model.DownloadTaskStatusPending,
+			"progress":   0,
+			"started_at": nil,
+		})
+	if result.Error != nil {
+		logrus.Errorf("回收中断的下载任务失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		logrus.Warnf("已回收 %d 个中断的下载任务", result.RowsAffected)
+	}
+}
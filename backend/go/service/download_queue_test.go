@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+// newTestQueue 创建不注册为全局队列的下载任务队列
+func newTestQueue(cfg config.DownloadConfig) *DownloadQueue {
+	return &DownloadQueue{cfg: cfg, service: NewDownloadTaskService(), tasks: make(chan uint, cfg.QueueSize)}
+}
+
+func TestDownloadQueueClaim(t *testing.T) {
+	setupTestModels(&model.DownloadTask{})
+	db := database.GetDB()
+	queue := newTestQueue(config.DownloadConfig{QueueSize: 10})
+
+	tests := []struct {
+		name   string
+		status string
+		want   bool
+	}{
+		{"Pending", model.DownloadTaskStatusPending, true},
+		{"Running", model.DownloadTaskStatusRunning, false},
+		{"Success", model.DownloadTaskStatusSuccess, false},
+		{"Cancelled", model.DownloadTaskStatusCancelled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &model.DownloadTask{TaskName: tt.name, Status: tt.status, Attempts: 1}
+			db.Create(task)
+
+			claimed, ok := queue.claim(task.ID)
+			if ok != tt.want {
+				t.Fatalf("Expected claim %v, got %v", tt.want, ok)
+			}
+			if ok && (claimed.Status != model.DownloadTaskStatusRunning || claimed.Attempts != 2 || claimed.StartedAt == nil) {
+				t.Errorf("Unexpected claimed task: %+v", claimed)
+			}
+		})
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		task := &model.DownloadTask{TaskName: "concurrent", Status: model.DownloadTaskStatusPending}
+		db.Create(task)
+
+		var claimed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, ok := queue.claim(task.ID); ok {
+					atomic.AddInt32(&claimed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		var saved model.DownloadTask
+		db.First(&saved, task.ID)
+		if claimed != 1 || saved.Attempts != 1 {
+			t.Errorf("Expected exactly one claim, got %d claims and %d attempts", claimed, saved.Attempts)
+		}
+	})
+}
+
+func TestRecoverStaleTasks(t *testing.T) {
+	setupTestModels(&model.DownloadTask{})
+	db := database.GetDB()
+	queue := newTestQueue(config.DownloadConfig{QueueSize: 10, StaleTimeout: time.Minute})
+
+	tests := []struct {
+		name      string
+		status    string
+		updatedAt time.Time
+		want      string
+	}{
+		{"StaleRunning", model.DownloadTaskStatusRunning, time.Now().Add(-2 * time.Minute), model.DownloadTaskStatusPending},
+		{"ActiveRunning", model.DownloadTaskStatusRunning, time.Now().Add(-10 * time.Second), model.DownloadTaskStatusRunning},
+		{"OldPending", model.DownloadTaskStatusPending, time.Now().Add(-time.Hour), model.DownloadTaskStatusPending},
+		{"OldSuccess", model.DownloadTaskStatusSuccess, time.Now().Add(-time.Hour), model.DownloadTaskStatusSuccess},
+	}
+
+	ids := make([]uint, len(tests))
+	for i, tt := range tests {
+		task := &model.DownloadTask{TaskName: tt.name, Status: tt.status}
+		db.Create(task)
+		db.Model(task).UpdateColumn("updated_at", tt.updatedAt)
+		ids[i] = task.ID
+	}
+
+	queue.recoverStaleTasks()
+	for i, tt := range tests {
+		var saved model.DownloadTask
+		db.First(&saved, ids[i])
+		if saved.Status != tt.want {
+			t.Errorf("%s: expected status %s, got %s", tt.name, tt.want, saved.Status)
+		}
+	}
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		// 心跳刷新 updated_at 后，长时间未更新进度的任务不会被回收
+		task := &model.DownloadTask{TaskName: "heartbeat", Status: model.DownloadTaskStatusRunning}
+		db.Create(task)
+		db.Model(task).UpdateColumn("updated_at", time.Now().Add(-2*time.Minute))
+
+		heartbeatQueue := newTestQueue(config.DownloadConfig{QueueSize: 10, StaleTimeout: time.Minute, Heartbeat: 10 * time.Millisecond})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		heartbeatQueue.heartbeat(ctx, task.ID)
+
+		heartbeatQueue.recoverStaleTasks()
+		var saved model.DownloadTask
+		db.First(&saved, task.ID)
+		if saved.Status != model.DownloadTaskStatusRunning {
+			t.Errorf("Expected task kept running, got %s", saved.Status)
+		}
+	})
+}
+
+func TestFillFromDB(t *testing.T) {
+	setupTestModels(&model.DownloadTask{})
+	db := database.GetDB()
+	queue := newTestQueue(config.DownloadConfig{QueueSize: 2})
+
+	for _, status := range []string{model.DownloadTaskStatusRunning, model.DownloadTaskStatusPending, model.DownloadTaskStatusPending, model.DownloadTaskStatusPending} {
+		db.Create(&model.DownloadTask{TaskName: "t", Status: status})
+	}
+
+	queue.fillFromDB()
+	if len(queue.tasks) != 2 || <-queue.tasks != 2 || <-queue.tasks != 3 {
+		t.Error("Expected oldest pending tasks 2 and 3 to be enqueued")
+	}
+}
+
+func TestInitDownloadQueueStaleTimeout(t *testing.T) {
+	previous := downloadQueue
+	defer func() { downloadQueue = previous }()
+
+	queue := InitDownloadQueue(config.DownloadConfig{StaleTimeout: time.Minute, Heartbeat: time.Minute})
+	if queue.cfg.StaleTimeout != 3*time.Minute {
+		t.Errorf("Expected stale timeout raised to 3 heartbeats, got %s", queue.cfg.StaleTimeout)
+	}
+}
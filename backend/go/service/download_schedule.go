@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // 容器镜像中可能没有时区数据
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/util"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// scheduleLockTTL 定时任务触发锁的有效期，需大于各实例间的时钟偏差
+const scheduleLockTTL = 10 * time.Minute
+
+// scheduleEventDebounce 回调事件触发定时任务的最小间隔，避免连续推送时重复创建下载任务
+const scheduleEventDebounce = time.Minute
+
+// defaultScheduleTimezone 未配置时区时使用的默认时区
+const defaultScheduleTimezone = "Asia/Shanghai"
+
+// cronParser 标准 5 段 Cron 表达式解析器，同时支持 @daily 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// DownloadScheduleService 定时下载任务服务
+type DownloadScheduleService struct{}
+
+// NewDownloadScheduleService 创建定时下载任务服务实例
+func NewDownloadScheduleService() *DownloadScheduleService {
+	return &DownloadScheduleService{}
+}
+
+// List 获取定时任务列表，非管理员只能看到自己创建的定时任务
+func (s *DownloadScheduleService) List(page, pageSize int, companyID uint, name string, status int, currentUser model.User, roleCodes []string) ([]model.DownloadSchedule, int64, error) {
+	db := database.GetDB()
+
+	var schedules []model.DownloadSchedule
+	var total int64
+
+	query := db.Model(&model.DownloadSchedule{})
+	if !util.ContainsString(roleCodes, "admin") {
+		query = query.Where("user_id = ?", currentUser.ID)
+	}
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	if status >= 0 {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取定时任务总数失败: %v", err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Company").Preload("User").Preload("APIConfig").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		logrus.Errorf("获取定时任务列表失败: %v", err)
+		return nil, 0, err
+	}
+
+	return schedules, total, nil
+}
+
+// Get 获取定时任务详情
+func (s *DownloadScheduleService) Get(id uint) (*model.DownloadSchedule, error) {
+	db := database.GetDB()
+
+	var schedule model.DownloadSchedule
+	if err := db.Preload("Company").Preload("User").Preload("APIConfig").First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("定时任务不存在")
+		}
+		logrus.Errorf("获取定时任务详情失败: %v", err)
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// Create 创建定时任务
+func (s *DownloadScheduleService) Create(schedule *model.DownloadSchedule) error {
+	db := database.GetDB()
+
+	if err := s.validate(schedule); err != nil {
+		return err
+	}
+	if schedule.Status == nil {
+		schedule.Status = util.IntPtr(1)
+	}
+	schedule.NextRunAt = s.nextRunTime(schedule, time.Now())
+
+	if err := db.Create(schedule).Error; err != nil {
+		logrus.Errorf("创建定时任务失败: %v", err)
+		return err
+	}
+
+	syncDownloadScheduler()
+	return nil
+}
+
+// Update 更新定时任务，执行记录相关字段保持不变
+func (s *DownloadScheduleService) Update(schedule *model.DownloadSchedule) error {
+	db := database.GetDB()
+
+	var existing model.DownloadSchedule
+	if err := db.First(&existing, schedule.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("定时任务不存在")
+		}
+		logrus.Errorf("获取定时任务失败: %v", err)
+		return err
+	}
+
+	existing.CompanyID = schedule.CompanyID
+	existing.APIConfigID = schedule.APIConfigID
+	existing.Name = schedule.Name
+	existing.TaskType = schedule.TaskType
+	existing.CronExpr = schedule.CronExpr
+	existing.Timezone = schedule.Timezone
+	existing.Params = schedule.Params
+	existing.FileFormat = schedule.FileFormat
+	existing.SyncMode = schedule.SyncMode
+	existing.EventTypes = schedule.EventTypes
+	existing.Description = schedule.Description
+	if err := s.validate(&existing); err != nil {
+		return err
+	}
+	existing.NextRunAt = s.nextRunTime(&existing, time.Now())
+
+	if err := db.Save(&existing).Error; err != nil {
+		logrus.Errorf("更新定时任务失败: %v", err)
+		return err
+	}
+
+	*schedule = existing
+	syncDownloadScheduler()
+	return nil
+}
+
+// Delete 删除定时任务，已生成的下载任务和执行记录保留
+func (s *DownloadScheduleService) Delete(id uint) error {
+	db := database.GetDB()
+
+	result := db.Delete(&model.DownloadSchedule{}, id)
+	if result.Error != nil {
+		logrus.Errorf("删除定时任务失败: %v", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("定时任务不存在")
+	}
+
+	syncDownloadScheduler()
+	return nil
+}
+
+// Pause 暂停定时任务
+func (s *DownloadScheduleService) Pause(id uint) (*model.DownloadSchedule, error) {
+	return s.setStatus(id, 0)
+}
+
+// Resume 恢复定时任务
+func (s *DownloadScheduleService) Resume(id uint) (*model.DownloadSchedule, error) {
+	return s.setStatus(id, 1)
+}
+
+// setStatus 更新定时任务状态并同步调度器
+func (s *DownloadScheduleService) setStatus(id uint, status int) (*model.DownloadSchedule, error) {
+	schedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"status": status, "next_run_at": nil}
+	if status == 1 {
+		updates["next_run_at"] = s.nextRunTime(schedule, time.Now())
+	}
+	if err := database.GetDB().Model(schedule).Updates(updates).Error; err != nil {
+		logrus.Errorf("更新定时任务状态失败: %v", err)
+		return nil, err
+	}
+
+	syncDownloadScheduler()
+	return s.Get(id)
+}
+
+// RunNow 立即执行一次定时任务，暂停状态下也可执行
+func (s *DownloadScheduleService) RunNow(id uint) (*model.DownloadScheduleRun, error) {
+	schedule, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(schedule, model.ScheduleTriggerManual, time.Now())
+}
+
+// Runs 分页获取定时任务的执行记录
+func (s *DownloadScheduleService) Runs(scheduleID uint, page, pageSize int) ([]model.DownloadScheduleRun, int64, error) {
+	db := database.GetDB()
+
+	var runs []model.DownloadScheduleRun
+	var total int64
+
+	query := db.Model(&model.DownloadScheduleRun{}).Where("schedule_id = ?", scheduleID)
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取定时任务执行记录总数失败: %v", err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("DownloadTask").Offset(offset).Limit(pageSize).Order("id DESC").Find(&runs).Error; err != nil {
+		logrus.Errorf("获取定时任务执行记录失败: %v", err)
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// fire 由调度器在 Cron 触发时调用
+// 多个实例同时触发时，只有获得 Redis 锁的实例会创建下载任务
+func (s *DownloadScheduleService) fire(scheduleID uint, scheduledAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := fmt.Sprintf("download_schedule:lock:%d:%d", scheduleID, scheduledAt.Unix())
+	locked, err := database.TryLock(ctx, key, scheduleLockTTL)
+	if err != nil {
+		logrus.Errorf("获取定时任务锁失败，定时任务ID: %d, 错误: %v", scheduleID, err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	var schedule model.DownloadSchedule
+	if err := database.GetDB().First(&schedule, scheduleID).Error; err != nil {
+		logrus.Errorf("获取定时任务失败，定时任务ID: %d, 错误: %v", scheduleID, err)
+		return
+	}
+	if util.IntValue(schedule.Status, 1) != 1 {
+		return
+	}
+
+	s.run(&schedule, model.ScheduleTriggerCron, scheduledAt)
+}
+
+// fireEvent 收到回调事件时执行订阅了该事件的定时任务，暂停的定时任务不执行
+func (s *DownloadScheduleService) fireEvent(ctx context.Context, companyID uint, eventType string) error {
+	var schedules []model.DownloadSchedule
+	if err := database.GetDB().
+		Where("company_id = ? AND status = ? AND event_types LIKE ?", companyID, 1, "%"+eventType+"%").
+		Find(&schedules).Error; err != nil {
+		logrus.Errorf("获取订阅事件的定时任务失败: %v", err)
+		return err
+	}
+
+	var firstErr error
+	for i := range schedules {
+		schedule := &schedules[i]
+		if !util.ContainsString(strings.Split(schedule.EventTypes, ","), eventType) {
+			continue
+		}
+
+		key := fmt.Sprintf("download_schedule:event:%d", schedule.ID)
+		locked, err := database.TryLock(ctx, key, scheduleEventDebounce)
+		if err != nil {
+			logrus.Errorf("获取定时任务锁失败，定时任务ID: %d, 错误: %v", schedule.ID, err)
+			locked = true
+		}
+		if !locked {
+			continue
+		}
+
+		if _, err := s.run(schedule, model.ScheduleTriggerEvent, time.Now()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// normalizeEventTypes 去掉事件类型列表中的空格、空项和重复项
+func normalizeEventTypes(eventTypes string) string {
+	var normalized []string
+	for _, eventType := range strings.Split(eventTypes, ",") {
+		eventType = strings.TrimSpace(eventType)
+		if eventType != "" && !util.ContainsString(normalized, eventType) {
+			normalized = append(normalized, eventType)
+		}
+	}
+	return strings.Join(normalized, ",")
+}
+
+// run 计算参数模板并创建下载任务，记录执行结果
+func (s *DownloadScheduleService) run(schedule *model.DownloadSchedule, trigger string, scheduledAt time.Time) (*model.DownloadScheduleRun, error) {
+	db := database.GetDB()
+
+	localTime := scheduledAt.In(scheduleLocation(schedule))
+	run := &model.DownloadScheduleRun{
+		ScheduleID:  schedule.ID,
+		Trigger:     trigger,
+		ScheduledAt: scheduledAt,
+		Status:      model.ScheduleRunStatusSuccess,
+	}
+
+	params, err := renderParamTemplate(schedule.Params, localTime)
+	if err == nil {
+		run.Params = params
+		task := &model.DownloadTask{
+			CompanyID:   schedule.CompanyID,
+			UserID:      schedule.UserID,
+			APIConfigID: schedule.APIConfigID,
+			TaskName:    fmt.Sprintf("%s %s", schedule.Name, localTime.Format("2006-01-02 15:04")),
+			TaskType:    schedule.TaskType,
+			Params:      params,
+			FileFormat:  schedule.FileFormat,
+			SyncMode:    schedule.SyncMode,
+		}
+		if err = NewDownloadTaskService().Create(task); err == nil {
+			run.TaskID = &task.ID
+		}
+	}
+	if err != nil {
+		run.Status = model.ScheduleRunStatusFailed
+		run.ErrorMsg = err.Error()
+		logrus.Errorf("定时任务执行失败，定时任务ID: %d, 错误: %v", schedule.ID, err)
+	}
+
+	if createErr := db.Create(run).Error; createErr != nil {
+		logrus.Errorf("保存定时任务执行记录失败: %v", createErr)
+	}
+
+	// 执行记录字段不更新 updated_at，避免调度器误认为配置已变更
+	updates := map[string]interface{}{
+		"last_run_at": scheduledAt,
+		"next_run_at": s.nextRunTime(schedule, time.Now()),
+	}
+	if run.TaskID != nil {
+		updates["last_task_id"] = *run.TaskID
+	}
+	if updateErr := db.Model(schedule).UpdateColumns(updates).Error; updateErr != nil {
+		logrus.Errorf("更新定时任务执行时间失败: %v", updateErr)
+	}
+
+	if err != nil {
+		return run, err
+	}
+	logrus.Infof("定时任务已创建下载任务，定时任务ID: %d, 任务ID: %d", schedule.ID, *run.TaskID)
+	return run, nil
+}
+
+// validate 校验定时任务配置
+func (s *DownloadScheduleService) validate(schedule *model.DownloadSchedule) error {
+	db := database.GetDB()
+
+	if schedule.Name == "" {
+		return errors.New("定时任务名称不能为空")
+	}
+	if schedule.TaskType == "" {
+		schedule.TaskType = "list"
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = defaultScheduleTimezone
+	}
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("时区无效: %s", schedule.Timezone)
+	}
+	if _, err := cronParser.Parse(schedule.CronExpr); err != nil {
+		return fmt.Errorf("Cron 表达式无效: %v", err)
+	}
+	if _, err := renderParamTemplate(schedule.Params, time.Now()); err != nil {
+		return err
+	}
+	schedule.EventTypes = normalizeEventTypes(schedule.EventTypes)
+
+	if schedule.FileFormat == "" {
+		schedule.FileFormat = model.FileFormatJSON
+	}
+	if _, err := exportFileExt(schedule.FileFormat); err != nil {
+		return err
+	}
+
+	var apiConfig model.APIConfig
+	if err := db.Where("id = ? AND company_id = ?", schedule.APIConfigID, schedule.CompanyID).First(&apiConfig).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("API配置不存在")
+		}
+		logrus.Errorf("检查API配置是否存在失败: %v", err)
+		return err
+	}
+
+	return validateSyncMode(&schedule.SyncMode, &apiConfig)
+}
+
+// nextRunTime 计算下次执行时间，暂停或配置无效时返回 nil
+func (s *DownloadScheduleService) nextRunTime(schedule *model.DownloadSchedule, now time.Time) *time.Time {
+	if util.IntValue(schedule.Status, 1) != 1 {
+		return nil
+	}
+	sched, err := cronParser.Parse(scheduleSpec(schedule))
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(now)
+	return &next
+}
+
+// scheduleSpec 返回带时区的 Cron 表达式
+func scheduleSpec(schedule *model.DownloadSchedule) string {
+	return fmt.Sprintf("CRON_TZ=%s %s", scheduleLocation(schedule).String(), schedule.CronExpr)
+}
+
+// scheduleLocation 返回定时任务的时区，无效时使用默认时区
+func scheduleLocation(schedule *model.DownloadSchedule) *time.Location {
+	timezone := schedule.Timezone
+	if timezone == "" {
+		timezone = defaultScheduleTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/util"
+)
+
+func TestDownloadSchedule(t *testing.T) {
+	setupTestModels(&model.APIConfig{}, &model.Company{}, &model.DownloadSchedule{}, &model.DownloadScheduleRun{}, &model.User{})
+	db := database.GetDB()
+	svc := NewDownloadScheduleService()
+
+	db.Create(&model.Company{ID: 1, Name: "总部", Code: "HQ"})
+	db.Create(&model.User{ID: 1, CompanyID: 1, Username: "admin", Password: "x"})
+	db.Create(&model.APIConfig{ID: 1, CompanyID: 1, Name: "审批列表", Code: "approval", Version: "v1"})
+
+	schedule := &model.DownloadSchedule{
+		CompanyID:   1,
+		UserID:      1,
+		APIConfigID: 1,
+		Name:        "每日审批导出",
+		CronExpr:    "0 8 * * *",
+		Params:      `{"start_time":"${yesterday_start_ms}","end_time":"${today_start_ms}"}`,
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		if err := svc.Create(schedule); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if util.IntValue(schedule.Status, 0) != 1 || schedule.NextRunAt == nil || schedule.NextRunAt.In(scheduleLocation(schedule)).Hour() != 8 {
+			t.Errorf("Unexpected schedule: status=%v next_run_at=%v", schedule.Status, schedule.NextRunAt)
+		}
+
+		paused := *schedule
+		paused.ID = 0
+		paused.Status = util.IntPtr(0)
+		if err := svc.Create(&paused); err != nil {
+			t.Fatalf("Create paused failed: %v", err)
+		}
+		var saved model.DownloadSchedule
+		db.First(&saved, paused.ID)
+		if util.IntValue(saved.Status, 1) != 0 || saved.NextRunAt != nil {
+			t.Errorf("Expected paused schedule, got status=%v next_run_at=%v", saved.Status, saved.NextRunAt)
+		}
+		db.Delete(&saved)
+
+		invalid := *schedule
+		invalid.ID = 0
+		invalid.CronExpr = "every morning"
+		if err := svc.Create(&invalid); err == nil {
+			t.Error("Expected invalid cron expression to fail")
+		}
+	})
+
+	t.Run("RunNow", func(t *testing.T) {
+		if _, err := svc.Pause(schedule.ID); err != nil {
+			t.Fatalf("Pause failed: %v", err)
+		}
+
+		run, err := svc.RunNow(schedule.ID)
+		if err != nil {
+			t.Fatalf("RunNow failed: %v", err)
+		}
+		if run.TaskID == nil || strings.Contains(run.Params, "${") {
+			t.Fatalf("Unexpected run: %+v", run)
+		}
+
+		var task model.DownloadTask
+		db.First(&task, *run.TaskID)
+		if task.Params != run.Params || task.Status != model.DownloadTaskStatusPending {
+			t.Errorf("Unexpected task: params=%s status=%s", task.Params, task.Status)
+		}
+
+		runs, total, _ := svc.Runs(schedule.ID, 1, 10)
+		if total != 1 || runs[0].Trigger != model.ScheduleTriggerManual {
+			t.Errorf("Unexpected runs: %+v", runs)
+		}
+	})
+
+	t.Run("PausedNotFired", func(t *testing.T) {
+		svc.fire(schedule.ID, time.Now().Truncate(time.Minute))
+		if _, total, _ := svc.Runs(schedule.ID, 1, 10); total != 1 {
+			t.Errorf("Expected paused schedule not to fire, got %d runs", total)
+		}
+	})
+
+	t.Run("EventTrigger", func(t *testing.T) {
+		if _, err := svc.Resume(schedule.ID); err != nil {
+			t.Fatalf("Resume failed: %v", err)
+		}
+		schedule.EventTypes = " bpms_instance_change, ,bpms_task_change"
+		if err := svc.Update(schedule); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if schedule.EventTypes != "bpms_instance_change,bpms_task_change" {
+			t.Errorf("Unexpected event types: %q", schedule.EventTypes)
+		}
+
+		if err := svc.fireEvent(context.Background(), 1, "bpms_instance"); err != nil {
+			t.Fatalf("fireEvent failed: %v", err)
+		}
+		if err := svc.fireEvent(context.Background(), 1, "bpms_task_change"); err != nil {
+			t.Fatalf("fireEvent failed: %v", err)
+		}
+		runs, total, _ := svc.Runs(schedule.ID, 1, 10)
+		if total != 2 || runs[0].Trigger != model.ScheduleTriggerEvent {
+			t.Errorf("Expected one event run, got %d runs: %+v", total, runs)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleSyncInterval 从数据库同步定时任务配置的间隔，用于感知其他实例上的修改
+const scheduleSyncInterval = time.Minute
+
+// DownloadScheduler 进程内的定时任务调度器
+// 每个实例都按 Cron 表达式触发，由 Redis 锁保证同一次触发只创建一个下载任务
+type DownloadScheduler struct {
+	cron    *cron.Cron
+	service *DownloadScheduleService
+	mu      sync.Mutex
+	entries map[uint]scheduledEntry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// scheduledEntry 已注册到 Cron 的定时任务
+type scheduledEntry struct {
+	entryID   cron.EntryID
+	updatedAt time.Time
+}
+
+var downloadScheduler *DownloadScheduler
+
+// InitDownloadScheduler 初始化全局定时任务调度器
+func InitDownloadScheduler() *DownloadScheduler {
+	downloadScheduler = &DownloadScheduler{
+		cron:    cron.New(cron.WithParser(cronParser)),
+		service: NewDownloadScheduleService(),
+		entries: make(map[uint]scheduledEntry),
+	}
+	return downloadScheduler
+}
+
+// GetDownloadScheduler 获取全局定时任务调度器，未初始化时返回 nil
+func GetDownloadScheduler() *DownloadScheduler {
+	return downloadScheduler
+}
+
+// syncDownloadScheduler 定时任务配置变更后立即同步调度器
+func syncDownloadScheduler() {
+	if scheduler := GetDownloadScheduler(); scheduler != nil {
+		scheduler.Sync()
+	}
+}
+
+// Start 加载定时任务并启动调度
+func (s *DownloadScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.Sync()
+	s.cron.Start()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(scheduleSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Sync()
+			}
+		}
+	}()
+
+	logrus.Info("定时任务调度器已启动")
+}
+
+// Stop 停止调度，等待正在触发的任务完成
+func (s *DownloadScheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	<-s.cron.Stop().Done()
+	logrus.Info("定时任务调度器已停止")
+}
+
+// Sync 按数据库中启用的定时任务增删 Cron 条目，配置有变更的条目会重新注册
+func (s *DownloadScheduler) Sync() {
+	var schedules []model.DownloadSchedule
+	if err := database.GetDB().Where("status = ?", 1).Find(&schedules).Error; err != nil {
+		logrus.Errorf("加载定时任务失败: %v", err)
+		return
+	}
+
+	active := make(map[uint]model.DownloadSchedule, len(schedules))
+	for _, schedule := range schedules {
+		active[schedule.ID] = schedule
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, entry := range s.entries {
+		schedule, ok := active[id]
+		if ok && schedule.UpdatedAt.Equal(entry.updatedAt) {
+			continue
+		}
+		s.cron.Remove(entry.entryID)
+		delete(s.entries, id)
+	}
+
+	for id, schedule := range active {
+		if _, ok := s.entries[id]; ok {
+			continue
+		}
+
+		scheduleID := id
+		entryID, err := s.cron.AddFunc(scheduleSpec(&schedule), func() {
+			s.service.fire(scheduleID, time.Now().Truncate(time.Minute))
+		})
+		if err != nil {
+			logrus.Errorf("注册定时任务失败，定时任务ID: %d, 错误: %v", id, err)
+			continue
+		}
+		s.entries[id] = scheduledEntry{entryID: entryID, updatedAt: schedule.UpdatedAt}
+	}
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// syncRecordBatchSize 查询和保存记录摘要的批大小
+const syncRecordBatchSize = 500
+
+// SyncStateService 增量同步状态服务
+type SyncStateService struct{}
+
+// NewSyncStateService 创建增量同步状态服务实例
+func NewSyncStateService() *SyncStateService {
+	return &SyncStateService{}
+}
+
+// SyncState 某个公司和API配置的增量同步状态
+type SyncState struct {
+	CompanyID   uint                 `json:"company_id"`
+	APIConfigID uint                 `json:"api_config_id"`
+	Watermark   *model.SyncWatermark `json:"watermark"`
+	RecordCount int64                `json:"record_count"` // 已记录的主键数量
+}
+
+// Get 获取增量同步状态
+func (s *SyncStateService) Get(companyID, apiConfigID uint) (*SyncState, error) {
+	db := database.GetDB()
+
+	state := &SyncState{CompanyID: companyID, APIConfigID: apiConfigID}
+
+	var watermark model.SyncWatermark
+	err := db.Where("company_id = ? AND api_config_id = ?", companyID, apiConfigID).First(&watermark).Error
+	if err == nil {
+		state.Watermark = &watermark
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.Errorf("获取增量同步水位失败: %v", err)
+		return nil, err
+	}
+
+	if err := db.Model(&model.SyncRecord{}).
+		Where("company_id = ? AND api_config_id = ?", companyID, apiConfigID).
+		Count(&state.RecordCount).Error; err != nil {
+		logrus.Errorf("获取增量同步记录数失败: %v", err)
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Reset 清除水位和记录摘要，下次增量同步从初始水位重新开始
+func (s *SyncStateService) Reset(companyID, apiConfigID uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("company_id = ? AND api_config_id = ?", companyID, apiConfigID).
+			Delete(&model.SyncWatermark{}).Error; err != nil {
+			logrus.Errorf("清除增量同步水位失败: %v", err)
+			return err
+		}
+		if err := tx.Where("company_id = ? AND api_config_id = ?", companyID, apiConfigID).
+			Delete(&model.SyncRecord{}).Error; err != nil {
+			logrus.Errorf("清除增量同步记录失败: %v", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// parseAPIIncremental 解析增量同步配置，未配置时返回 nil
+func parseAPIIncremental(raw string) (*model.APIIncremental, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var incremental model.APIIncremental
+	if err := json.Unmarshal([]byte(raw), &incremental); err != nil {
+		return nil, fmt.Errorf("增量同步配置格式错误: %v", err)
+	}
+	if incremental.Param == "" {
+		return nil, errors.New("增量同步需要配置 param")
+	}
+
+	switch incremental.Source {
+	case "":
+		incremental.Source = model.WatermarkSourceRecord
+		fallthrough
+	case model.WatermarkSourceRecord:
+		if incremental.WatermarkPath == "" {
+			return nil, errors.New("按记录计算水位需要配置 watermark_path")
+		}
+	case model.WatermarkSourceCursor:
+	default:
+		return nil, fmt.Errorf("不支持的水位来源: %s", incremental.Source)
+	}
+
+	return &incremental, nil
+}
+
+// validateSyncMode 校验同步方式，增量同步要求API配置了增量同步参数
+func validateSyncMode(syncMode *string, apiConfig *model.APIConfig) error {
+	switch *syncMode {
+	case "":
+		*syncMode = model.SyncModeFull
+		return nil
+	case model.SyncModeFull:
+		return nil
+	case model.SyncModeIncremental:
+		incremental, err := parseAPIIncremental(apiConfig.Incremental)
+		if err != nil {
+			return err
+		}
+		if incremental == nil {
+			return errors.New("API配置未配置增量同步")
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的同步方式: %s", *syncMode)
+	}
+}
+
+// incrementalSync 一次增量同步的上下文
+type incrementalSync struct {
+	config      *model.APIIncremental
+	companyID   uint
+	apiConfigID uint
+	from        interface{} // 本次使用的水位，nil 表示沿用任务参数
+}
+
+// newIncrementalSync 任务为增量模式时读取配置和当前水位，否则返回 nil
+func newIncrementalSync(task *model.DownloadTask, apiConfig *model.APIConfig) (*incrementalSync, error) {
+	if task.SyncMode != model.SyncModeIncremental {
+		return nil, nil
+	}
+
+	config, err := parseAPIIncremental(apiConfig.Incremental)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.New("API配置未配置增量同步")
+	}
+
+	sync := &incrementalSync{
+		config:      config,
+		companyID:   task.CompanyID,
+		apiConfigID: apiConfig.ID,
+		from:        config.InitialValue,
+	}
+
+	var watermark model.SyncWatermark
+	err = database.GetDB().Where("company_id = ? AND api_config_id = ?", task.CompanyID, apiConfig.ID).First(&watermark).Error
+	if err == nil {
+		if sync.from, err = decodeWatermark(watermark.Value); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.Errorf("获取增量同步水位失败: %v", err)
+		return nil, err
+	}
+
+	return sync, nil
+}
+
+// apply 将水位写入请求参数
+func (s *incrementalSync) apply(params map[string]interface{}) map[string]interface{} {
+	if s.from == nil {
+		return params
+	}
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	params[s.config.Param] = s.from
+	return params
+}
+
+// filter 过滤掉主键已导出且内容未变化的记录，返回需要导出的记录和待保存的摘要
+// 同一批中主键重复时只保留第一条；没有配置主键或记录中取不到主键时不去重
+func (s *incrementalSync) filter(records []interface{}) ([]interface{}, []model.SyncRecord, error) {
+	if s.config.KeyPath == "" {
+		return records, nil, nil
+	}
+
+	type keyedRecord struct {
+		record interface{}
+		key    string
+		hash   string
+	}
+
+	keyed := make([]keyedRecord, 0, len(records))
+	var keys []string
+	for _, record := range records {
+		item := keyedRecord{record: record}
+		if value, ok := utils.GetPath(record, s.config.KeyPath); ok && value != nil {
+			item.key = syncRecordKey(value)
+			item.hash = syncRecordHash(record)
+			keys = append(keys, item.key)
+		}
+		keyed = append(keyed, item)
+	}
+
+	// 查询已导出记录的摘要
+	known := make(map[string]string, len(keys))
+	db := database.GetDB()
+	for start := 0; start < len(keys); start += syncRecordBatchSize {
+		end := start + syncRecordBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		var existing []model.SyncRecord
+		if err := db.Select("record_key", "hash").
+			Where("company_id = ? AND api_config_id = ? AND record_key IN ?", s.companyID, s.apiConfigID, keys[start:end]).
+			Find(&existing).Error; err != nil {
+			logrus.Errorf("查询增量同步记录失败: %v", err)
+			return nil, nil, err
+		}
+		for _, record := range existing {
+			known[record.RecordKey] = record.Hash
+		}
+	}
+
+	changed := make([]interface{}, 0, len(records))
+	var states []model.SyncRecord
+	seen := make(map[string]bool, len(keys))
+	for _, item := range keyed {
+		if item.key == "" {
+			changed = append(changed, item.record)
+			continue
+		}
+		// 同一批中主键重复时只保留第一条，后面的记录无论内容是否不同都丢弃
+		if seen[item.key] {
+			continue
+		}
+		seen[item.key] = true
+		if hash, ok := known[item.key]; ok && hash == item.hash {
+			continue
+		}
+		changed = append(changed, item.record)
+		states = append(states, model.SyncRecord{
+			CompanyID:   s.companyID,
+			APIConfigID: s.apiConfigID,
+			RecordKey:   item.key,
+			Hash:        item.hash,
+		})
+	}
+
+	return changed, states, nil
+}
+
+// next 计算本次同步后的水位，水位不会后退
+func (s *incrementalSync) next(records []interface{}, last pageProgress) interface{} {
+	if s.config.Source == model.WatermarkSourceCursor {
+		if last.Cursor != nil && utils.ToString(last.Cursor) != "" {
+			return last.Cursor
+		}
+		return s.from
+	}
+
+	watermark := s.from
+	for _, record := range records {
+		value, ok := utils.GetPath(record, s.config.WatermarkPath)
+		if !ok || value == nil {
+			continue
+		}
+		if watermark == nil || compareWatermark(value, watermark) > 0 {
+			watermark = value
+		}
+	}
+	return watermark
+}
+
+// commit 保存新的水位和记录摘要
+func (s *incrementalSync) commit(taskID uint, watermark interface{}, states []model.SyncRecord) error {
+	value, err := json.Marshal(watermark)
+	if err != nil {
+		return err
+	}
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if watermark != nil {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "company_id"}, {Name: "api_config_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "task_id", "updated_at"}),
+			}).Create(&model.SyncWatermark{
+				CompanyID:   s.companyID,
+				APIConfigID: s.apiConfigID,
+				Value:       string(value),
+				TaskID:      taskID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(states) == 0 {
+			return nil
+		}
+		for i := range states {
+			states[i].TaskID = taskID
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "company_id"}, {Name: "api_config_id"}, {Name: "record_key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"hash", "task_id", "updated_at"}),
+		}).CreateInBatches(states, syncRecordBatchSize).Error
+	})
+}
+
+// decodeWatermark 解析保存的水位，数字保持原样避免毫秒时间戳丢失精度
+func decodeWatermark(raw string) (interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("增量同步水位格式错误: %v", err)
+	}
+	return value, nil
+}
+
+// compareWatermark 比较两个水位，都能转为数字时按数值比较，否则按字符串比较
+func compareWatermark(a, b interface{}) int {
+	as, bs := utils.ToString(a), utils.ToString(b)
+	af, aErr := strconv.ParseFloat(as, 64)
+	bf, bErr := strconv.ParseFloat(bs, 64)
+	switch {
+	case aErr == nil && bErr == nil && af != bf:
+		if af > bf {
+			return 1
+		}
+		return -1
+	case aErr == nil && bErr == nil:
+		return 0
+	case as > bs:
+		return 1
+	case as < bs:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// syncRecordKey 将主键转为字符串，超长时使用摘要
+func syncRecordKey(value interface{}) string {
+	key := utils.ToString(value)
+	if len(key) > 191 {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// syncRecordHash 计算记录内容摘要
+func syncRecordHash(record interface{}) string {
+	data, _ := json.Marshal(record)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
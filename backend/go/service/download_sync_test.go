@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestIncrementalSync(t *testing.T) {
+	setupTestModels(&model.APIConfig{}, &model.SyncWatermark{}, &model.SyncRecord{})
+	db := database.GetDB()
+
+	apiConfig := &model.APIConfig{
+		Name:        "list",
+		Code:        "list",
+		CompanyID:   1,
+		Incremental: `{"param":"start_time","watermark_path":"modified","key_path":"id","initial_value":100}`,
+	}
+	db.Create(apiConfig)
+	task := &model.DownloadTask{CompanyID: 1, APIConfigID: apiConfig.ID, SyncMode: model.SyncModeIncremental}
+
+	first := []interface{}{
+		map[string]interface{}{"id": "a", "modified": 200.0},
+		map[string]interface{}{"id": "b", "modified": 300.0},
+	}
+
+	t.Run("FirstRun", func(t *testing.T) {
+		sync, err := newIncrementalSync(task, apiConfig)
+		if err != nil {
+			t.Fatalf("newIncrementalSync failed: %v", err)
+		}
+		params := sync.apply(nil)
+		if params["start_time"] != 100.0 {
+			t.Errorf("Expected initial watermark 100, got %v", params["start_time"])
+		}
+
+		records, states, err := sync.filter(first)
+		if err != nil {
+			t.Fatalf("filter failed: %v", err)
+		}
+		if len(records) != 2 || len(states) != 2 {
+			t.Fatalf("Expected 2 new records, got %d", len(records))
+		}
+		if err := sync.commit(1, sync.next(first, pageProgress{}), states); err != nil {
+			t.Fatalf("commit failed: %v", err)
+		}
+	})
+
+	t.Run("NextRun", func(t *testing.T) {
+		sync, err := newIncrementalSync(task, apiConfig)
+		if err != nil {
+			t.Fatalf("newIncrementalSync failed: %v", err)
+		}
+		if params := sync.apply(nil); compareWatermark(params["start_time"], 300) != 0 {
+			t.Errorf("Expected watermark 300, got %v", params["start_time"])
+		}
+
+		second := []interface{}{
+			first[0],
+			map[string]interface{}{"id": "b", "modified": 400.0},
+			map[string]interface{}{"id": "c", "modified": 250.0},
+		}
+		records, states, err := sync.filter(second)
+		if err != nil {
+			t.Fatalf("filter failed: %v", err)
+		}
+		if len(records) != 2 || len(states) != 2 {
+			t.Errorf("Expected changed b and new c, got %v", records)
+		}
+
+		// 同一批中主键重复时只保留第一条
+		duplicated := []interface{}{
+			map[string]interface{}{"id": "d", "modified": 500.0},
+			map[string]interface{}{"id": "d", "modified": 600.0},
+		}
+		records, states, err = sync.filter(duplicated)
+		if err != nil {
+			t.Fatalf("filter failed: %v", err)
+		}
+		if len(records) != 1 || len(states) != 1 || records[0].(map[string]interface{})["modified"] != 500.0 {
+			t.Errorf("Expected only the first d, got %v", records)
+		}
+		if next := sync.next(second, pageProgress{}); compareWatermark(next, 400) != 0 {
+			t.Errorf("Expected watermark 400, got %v", next)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		if err := NewSyncStateService().Reset(1, apiConfig.ID); err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		state, err := NewSyncStateService().Get(1, apiConfig.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if state.Watermark != nil || state.RecordCount != 0 {
+			t.Errorf("Expected empty sync state, got %+v", state)
+		}
+	})
+}
+
+func TestValidateSyncMode(t *testing.T) {
+	mode := ""
+	if err := validateSyncMode(&mode, &model.APIConfig{}); err != nil || mode != model.SyncModeFull {
+		t.Errorf("Expected default full mode, got %q %v", mode, err)
+	}
+
+	mode = model.SyncModeIncremental
+	if err := validateSyncMode(&mode, &model.APIConfig{}); err == nil {
+		t.Error("Expected incremental mode without config to fail")
+	}
+	if err := validateSyncMode(&mode, &model.APIConfig{Incremental: `{"param":"cursor","source":"cursor"}`}); err != nil {
+		t.Errorf("Expected cursor incremental config to pass, got %v", err)
+	}
+}
@@ -1,15 +1,15 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/ddoalistdownload/backend/database"
 	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/storage"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
@@ -128,22 +128,44 @@ func (s *DownloadTaskService) Create(downloadTask *model.DownloadTask) error {
 		return err
 	}
 
-	// 设置默认值
-	if downloadTask.Status == "" {
-		downloadTask.Status = "pending"
+	// 审批导出任务校验审批参数，不支持增量同步
+	if downloadTask.TaskType == model.DownloadTaskTypeApproval {
+		if downloadTask.SyncMode == model.SyncModeIncremental {
+			return errors.New("审批导出任务不支持增量同步")
+		}
+		if _, err := parseApprovalParams(downloadTask.Params); err != nil {
+			return err
+		}
+	}
+
+	// 校验同步方式
+	if err := validateSyncMode(&downloadTask.SyncMode, &apiConfig); err != nil {
+		return err
+	}
+
+	// 校验导出格式
+	if downloadTask.FileFormat == "" {
+		downloadTask.FileFormat = model.FileFormatJSON
 	}
-	if downloadTask.Progress == 0 {
-		downloadTask.Progress = 0
+	if _, err := exportFileExt(downloadTask.FileFormat); err != nil {
+		return err
 	}
 
+	// 新建任务一律从 pending 开始，由队列调度执行
+	downloadTask.Status = model.DownloadTaskStatusPending
+	downloadTask.Progress = 0
+
 	// 创建下载任务
 	if err := db.Create(downloadTask).Error; err != nil {
 		logrus.Errorf("创建下载任务失败: %v", err)
 		return err
 	}
 
-	// 异步执行下载任务
-	go s.ExecuteTask(downloadTask)
+	// 投递到下载队列，队列已满时任务仍保留在数据库中等待轮询
+	if queue := GetDownloadQueue(); queue != nil {
+		queue.Enqueue(downloadTask.ID)
+	}
+	publishTaskEvent(downloadTask)
 
 	return nil
 }
@@ -162,13 +184,23 @@ func (s *DownloadTaskService) Delete(id uint) error {
 		return err
 	}
 
-	// 软删除下载任务
+	// 软删除下载任务，正在执行的任务会停止
 	if err := db.Delete(&downloadTask).Error; err != nil {
 		logrus.Errorf("删除下载任务失败: %v", err)
 		return err
 	}
+	cancelRunningTask(id)
+	clearCheckpoint(context.Background(), id, downloadTask.PagesFetched)
 
-	// 软删除相关的下载结果
+	// 删除导出文件及相关的下载结果
+	var results []model.DownloadResult
+	if err := db.Where("task_id = ?", id).Find(&results).Error; err != nil {
+		logrus.Errorf("获取下载结果失败: %v", err)
+		return err
+	}
+	for i := range results {
+		deleteArtifact(context.Background(), &results[i])
+	}
 	if err := db.Where("task_id = ?", id).Delete(&model.DownloadResult{}).Error; err != nil {
 		logrus.Errorf("删除下载结果失败: %v", err)
 		return err
@@ -178,26 +210,39 @@ func (s *DownloadTaskService) Delete(id uint) error {
 }
 
 // ExecuteTask 执行下载任务
-func (s *DownloadTaskService) ExecuteTask(task *model.DownloadTask) {
+// 调用方需先将任务置为 running（见 DownloadQueue.claim），ctx 取消时任务会被放回队列
+func (s *DownloadTaskService) ExecuteTask(ctx context.Context, task *model.DownloadTask) {
 	db := database.GetDB()
 
-	// 更新任务状态为运行中
-	task.Status = "running"
+	// 登记任务，取消时中断进行中的请求
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer trackRunningTask(task.ID, cancel)()
+
+	// 更新任务进度
 	task.Progress = 10
-	if err := db.Save(task).Error; err != nil {
-		logrus.Errorf("更新任务状态失败: %v", err)
+	result := db.Model(task).Where("status = ?", model.DownloadTaskStatusRunning).Update("progress", task.Progress)
+	if result.Error != nil {
+		logrus.Errorf("更新任务状态失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		logrus.Infof("下载任务已被取消，任务ID: %d", task.ID)
 		return
 	}
+	publishTaskEvent(task)
 
 	// 获取API配置
 	var apiConfig model.APIConfig
 	if err := db.First(&apiConfig, task.APIConfigID).Error; err != nil {
 		logrus.Errorf("获取API配置失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("获取API配置失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
+		s.failTask(ctx, task, fmt.Sprintf("获取API配置失败: %v", err))
+		return
+	}
+
+	// 审批导出任务使用固定的审批接口，API配置只提供数据字典模块和映射
+	if task.TaskType == model.DownloadTaskTypeApproval {
+		s.executeApproval(ctx, cancel, task, &apiConfig)
 		return
 	}
 
@@ -206,198 +251,326 @@ func (s *DownloadTaskService) ExecuteTask(task *model.DownloadTask) {
 	if task.Params != "" {
 		if err := json.Unmarshal([]byte(task.Params), &params); err != nil {
 			logrus.Errorf("解析任务参数失败: %v", err)
-			task.Status = "failed"
-			task.ErrorMsg = fmt.Sprintf("参数格式错误: %v", err)
-			if err := db.Save(task).Error; err != nil {
-				logrus.Errorf("更新任务状态失败: %v", err)
-			}
+			s.failTask(ctx, task, fmt.Sprintf("参数格式错误: %v", err))
 			return
 		}
 	}
 
+	// 增量同步时以上次的水位作为请求参数
+	incremental, err := newIncrementalSync(task, &apiConfig)
+	if err != nil {
+		s.failTask(ctx, task, err.Error())
+		return
+	}
+	if incremental != nil {
+		params = incremental.apply(params)
+	}
+
 	// 解析请求头
 	var headers map[string]string
 	if apiConfig.Headers != "" {
 		if err := json.Unmarshal([]byte(apiConfig.Headers), &headers); err != nil {
 			logrus.Errorf("解析请求头失败: %v", err)
-			task.Status = "failed"
-			task.ErrorMsg = fmt.Sprintf("请求头格式错误: %v", err)
-			if err := db.Save(task).Error; err != nil {
-				logrus.Errorf("更新任务状态失败: %v", err)
-			}
+			s.failTask(ctx, task, fmt.Sprintf("请求头格式错误: %v", err))
 			return
 		}
 	}
 
-	// 构建请求URL
-	baseURL := strings.TrimSuffix(apiConfig.BaseURL, "/")
-	path := strings.TrimPrefix(apiConfig.Path, "/")
-	url := baseURL + "/" + path
+	// 按分页配置逐页请求
+	walker, err := newPageWalker(NewAPIClient(), &apiConfig)
+	if err != nil {
+		s.failTask(ctx, task, err.Error())
+		return
+	}
+	var last pageProgress
+	walker.onPage = func(progress pageProgress, pageRecords []interface{}) error {
+		last = progress
 
-	// 如果是GET请求，将参数添加到URL
-	if apiConfig.Method == "GET" && len(params) > 0 {
-		url += "?"
-		for k, v := range params {
-			url += fmt.Sprintf("%s=%v&", k, v)
+		// 保存断点，重试时从下一页继续
+		if err := saveCheckpointPage(ctx, task.ID, progress.Pages, pageRecords); err != nil {
+			return fmt.Errorf("保存断点失败: %v", err)
+		}
+		checkpoint, _ := json.Marshal(progress)
+
+		task.PagesFetched = progress.Pages
+		task.RecordsFetched = progress.Records
+		task.Progress = fetchProgressPercent(progress, walker.pagination)
+		task.Checkpoint = string(checkpoint)
+
+		// 只更新执行中的任务，更新不到说明任务已被取消或删除
+		result := db.Model(&model.DownloadTask{}).
+			Where("id = ? AND status = ?", task.ID, model.DownloadTaskStatusRunning).
+			Updates(map[string]interface{}{
+				"pages_fetched":   task.PagesFetched,
+				"records_fetched": task.RecordsFetched,
+				"progress":        task.Progress,
+				"checkpoint":      task.Checkpoint,
+			})
+		if result.Error != nil {
+			logrus.Errorf("更新任务进度失败: %v", result.Error)
+			return nil
 		}
-		url = strings.TrimSuffix(url, "&")
+		if result.RowsAffected == 0 {
+			cancel(errTaskCancelled)
+			return errTaskCancelled
+		}
+		publishTaskEvent(task)
+		return nil
 	}
 
-	// 创建请求
-	var req *http.Request
-	var err error
-
-	if apiConfig.Method == "GET" {
-		req, err = http.NewRequest(apiConfig.Method, url, nil)
-	} else {
-		// 对于非GET请求，将参数转换为JSON
-		jsonParams, err := json.Marshal(params)
-		if err != nil {
-			logrus.Errorf("参数转换为JSON失败: %v", err)
-			task.Status = "failed"
-			task.ErrorMsg = fmt.Sprintf("参数转换为JSON失败: %v", err)
-			if err := db.Save(task).Error; err != nil {
-				logrus.Errorf("更新任务状态失败: %v", err)
-			}
-			return
-		}
-		req, err = http.NewRequest(apiConfig.Method, url, strings.NewReader(string(jsonParams)))
+	// 从断点恢复此前已获取的记录
+	from, records, err := loadCheckpoint(ctx, task)
+	if err != nil {
+		logrus.Warnf("读取断点失败，任务将从第一页重新开始，任务ID: %d, 错误: %v", task.ID, err)
+		from, records = nil, nil
+	}
+	if from != nil {
+		last = *from
+		logrus.Infof("下载任务从第%d页之后继续，任务ID: %d", from.Pages, task.ID)
 	}
 
+	fetched, err := walker.Walk(ctx, params, headers, from)
 	if err != nil {
-		logrus.Errorf("创建请求失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("创建请求失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
+		logrus.Errorf("下载任务请求失败，任务ID: %d, 错误: %v", task.ID, err)
+		s.failTask(ctx, task, err.Error())
 		return
 	}
+	records = append(records, fetched...)
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	// 增量同步时只导出新增或变化的记录
+	var syncRecords []model.SyncRecord
+	var watermark interface{}
+	fetchedCount := len(records)
+	if incremental != nil {
+		watermark = incremental.next(records, last)
+		if records, syncRecords, err = incremental.filter(records); err != nil {
+			s.failTask(ctx, task, fmt.Sprintf("增量同步去重失败: %v", err))
+			return
+		}
 	}
 
-	// 发送请求
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		logrus.Errorf("发送请求失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("发送请求失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
+	// 生成导出文件并上传到文件存储
+	downloadResult, columns, redactions, errMsg := s.exportRecords(ctx, task, &apiConfig, records)
+	if errMsg != "" {
+		s.failTask(ctx, task, errMsg)
 		return
 	}
-	defer resp.Body.Close()
 
-	// 读取响应
-	respBody, err := s.readResponseBody(resp)
-	if err != nil {
-		logrus.Errorf("读取响应失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("读取响应失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
+	// 任务结果只保留摘要，完整数据在导出文件中
+	resultSummary := exportSummary(task, downloadResult, columns, redactions)
+	if incremental != nil {
+		resultSummary["watermark_from"] = incremental.from
+		resultSummary["watermark_to"] = watermark
+		resultSummary["skipped"] = fetchedCount - len(records)
+	}
+	if !s.completeTask(task, downloadResult, resultSummary) {
 		return
 	}
+	clearCheckpoint(context.Background(), task.ID, task.PagesFetched)
 
-	// 解析响应
-	var respData map[string]interface{}
-	if err := json.Unmarshal(respBody, &respData); err != nil {
-		// 如果响应不是JSON格式，直接返回原始响应
-		respData = map[string]interface{}{
-			"raw_response": string(respBody),
+	// 任务成功后才推进水位，失败的任务下次仍从原水位开始
+	if incremental != nil {
+		if err := incremental.commit(task.ID, watermark, syncRecords); err != nil {
+			logrus.Errorf("保存增量同步水位失败，任务ID: %d, 错误: %v", task.ID, err)
 		}
 	}
+	publishTaskEvent(task)
 
-	// 更新任务进度
-	task.Progress = 80
-	if err := db.Save(task).Error; err != nil {
-		logrus.Errorf("更新任务进度失败: %v", err)
-		return
+	logrus.Infof("下载任务执行成功，任务ID: %d, 文件名: %s", task.ID, task.FileName)
+}
+
+// exportRecords 将记录按映射和字段权限生成导出文件并保存下载结果，失败时返回错误信息
+func (s *DownloadTaskService) exportRecords(ctx context.Context, task *model.DownloadTask, apiConfig *model.APIConfig, records []interface{}) (*model.DownloadResult, []exportColumn, []exportRedaction, string) {
+	mapping, err := parseAPIMapping(apiConfig.Mapping)
+	if err != nil {
+		return nil, nil, nil, err.Error()
 	}
+	columns, rows, warnings, err := buildExport(exportModule(apiConfig), mapping, records)
+	if err != nil {
+		return nil, nil, nil, fmt.Sprintf("映射导出记录失败: %v", err)
+	}
+	if len(warnings) > 0 {
+		logrus.Warnf("下载任务映射记录时部分值转换失败，任务ID: %d, 示例: %s", task.ID, warnings[0])
+	}
+	return s.saveExportRows(ctx, task, apiConfig, columns, rows)
+}
 
-	// 保存下载结果
-	resultJSON, err := json.Marshal(respData)
+// saveExportRows 按任务创建人的字段权限处理导出列，生成导出文件并保存下载结果，失败时返回错误信息
+func (s *DownloadTaskService) saveExportRows(ctx context.Context, task *model.DownloadTask, apiConfig *model.APIConfig, columns []exportColumn, rows []map[string]interface{}) (*model.DownloadResult, []exportColumn, []exportRedaction, string) {
+	db := database.GetDB()
+
+	// 按任务创建人的字段权限移除或脱敏列
+	columns, redactions, err := redactColumns(task.UserID, exportModule(apiConfig), columns)
 	if err != nil {
-		logrus.Errorf("转换结果为JSON失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("转换结果为JSON失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
-		return
+		return nil, nil, nil, fmt.Sprintf("获取字段权限失败: %v", err)
+	}
+
+	downloadResult, err := saveExport(ctx, task, columns, rows)
+	if err != nil {
+		logrus.Errorf("生成导出文件失败，任务ID: %d, 错误: %v", task.ID, err)
+		return nil, nil, nil, fmt.Sprintf("生成导出文件失败: %v", err)
+	}
+
+	if len(redactions) > 0 {
+		data, _ := json.Marshal(redactions)
+		downloadResult.Redactions = string(data)
 	}
 
 	// 创建下载结果
-	downloadResult := model.DownloadResult{
-		TaskID:    task.ID,
-		Data:      string(resultJSON),
-		DataType:  "json",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	if err := db.Create(&downloadResult).Error; err != nil {
+	if err := db.Create(downloadResult).Error; err != nil {
 		logrus.Errorf("创建下载结果失败: %v", err)
-		task.Status = "failed"
-		task.ErrorMsg = fmt.Sprintf("创建下载结果失败: %v", err)
-		if err := db.Save(task).Error; err != nil {
-			logrus.Errorf("更新任务状态失败: %v", err)
-		}
-		return
+		return nil, nil, nil, fmt.Sprintf("创建下载结果失败: %v", err)
+	}
+
+	return downloadResult, columns, redactions, ""
+}
+
+// exportSummary 返回任务结果摘要，完整数据在导出文件中
+func exportSummary(task *model.DownloadTask, downloadResult *model.DownloadResult, columns []exportColumn, redactions []exportRedaction) map[string]interface{} {
+	fields := make([]string, len(columns))
+	for i, column := range columns {
+		fields[i] = column.Field
+	}
+	resultSummary := map[string]interface{}{
+		"record_count": downloadResult.RecordCount,
+		"pages":        task.PagesFetched,
+		"columns":      fields,
 	}
+	if len(redactions) > 0 {
+		resultSummary["redactions"] = redactions
+	}
+	return resultSummary
+}
+
+// completeTask 将任务标记为成功，任务已被取消或删除时丢弃导出结果并返回 false
+func (s *DownloadTaskService) completeTask(task *model.DownloadTask, downloadResult *model.DownloadResult, resultSummary map[string]interface{}) bool {
+	db := database.GetDB()
+	summary, _ := json.Marshal(resultSummary)
 
 	// 更新任务结果
-	task.Status = "success"
+	now := time.Now()
+	task.Status = model.DownloadTaskStatusSuccess
 	task.Progress = 100
-	task.Result = string(resultJSON)
-	task.FileName = fmt.Sprintf("download_%d.json", task.ID)
-	task.FileSize = int64(len(resultJSON))
-	if err := db.Save(task).Error; err != nil {
-		logrus.Errorf("更新任务结果失败: %v", err)
+	task.Result = string(summary)
+	task.FileName = downloadResult.FileName
+	task.FileSize = downloadResult.FileSize
+	task.FileURL = fmt.Sprintf("/api/v1/download-task/%d/file", task.ID)
+	task.FinishedAt = &now
+	task.Checkpoint = ""
+	result := db.Model(&model.DownloadTask{}).
+		Where("id = ? AND status = ?", task.ID, model.DownloadTaskStatusRunning).
+		Updates(map[string]interface{}{
+			"status":      task.Status,
+			"progress":    task.Progress,
+			"result":      task.Result,
+			"file_name":   task.FileName,
+			"file_size":   task.FileSize,
+			"file_url":    task.FileURL,
+			"finished_at": task.FinishedAt,
+			"checkpoint":  task.Checkpoint,
+		})
+	if result.Error != nil {
+		logrus.Errorf("更新任务结果失败: %v", result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		// 导出期间任务被取消，丢弃本次结果
+		logrus.Infof("下载任务已被取消，丢弃导出结果，任务ID: %d", task.ID)
+		deleteArtifact(context.Background(), downloadResult)
+		db.Delete(downloadResult)
+		return false
+	}
+	notifyTaskFinished(task)
+	return true
+}
+
+// failTask 将任务标记为失败
+// 如果是用户取消则保持 cancelled；如果是服务关闭导致 ctx 被取消，则将任务放回 pending 等待重新执行
+// 进度和断点已在每页完成时保存，这里只更新状态
+func (s *DownloadTaskService) failTask(ctx context.Context, task *model.DownloadTask, errMsg string) {
+	db := database.GetDB()
+
+	if errors.Is(context.Cause(ctx), errTaskCancelled) {
+		// 状态已由 Cancel 更新
+		task.Status = model.DownloadTaskStatusCancelled
+		logrus.Infof("下载任务已停止执行，任务ID: %d", task.ID)
 		return
 	}
 
-	logrus.Infof("下载任务执行成功，任务ID: %d, 文件名: %s", task.ID, task.FileName)
-}
-
-// readResponseBody 读取响应体
-func (s *DownloadTaskService) readResponseBody(resp *http.Response) ([]byte, error) {
-	// 限制响应体大小为100MB
-	maxBodySize := int64(100 * 1024 * 1024)
-
-	// 检查响应体大小
-	if resp.ContentLength > maxBodySize {
-		return nil, errors.New("响应体过大")
-	}
-
-	// 读取响应体
-	body := make([]byte, 0, resp.ContentLength)
-	buf := make([]byte, 4096)
-	var totalRead int64
-
-	for {
-		n, err := resp.Body.Read(buf)
-		if n > 0 {
-			totalRead += int64(n)
-			if totalRead > maxBodySize {
-				return nil, errors.New("响应体过大")
-			}
-			body = append(body, buf[:n]...)
+	var updates map[string]interface{}
+	if ctx.Err() != nil {
+		task.Status = model.DownloadTaskStatusPending
+		task.Progress = 0
+		task.StartedAt = nil
+		updates = map[string]interface{}{
+			"status":     task.Status,
+			"progress":   task.Progress,
+			"started_at": nil,
 		}
-		if err != nil {
-			break
+		logrus.Warnf("下载任务被中断，已放回队列，任务ID: %d", task.ID)
+	} else {
+		now := time.Now()
+		task.Status = model.DownloadTaskStatusFailed
+		task.ErrorMsg = errMsg
+		task.FinishedAt = &now
+		updates = map[string]interface{}{
+			"status":      task.Status,
+			"error_msg":   task.ErrorMsg,
+			"finished_at": task.FinishedAt,
 		}
 	}
 
-	return body, nil
+	// 只更新执行中的任务，避免覆盖已取消或已删除的任务
+	result := db.Model(&model.DownloadTask{}).
+		Where("id = ? AND status = ?", task.ID, model.DownloadTaskStatusRunning).
+		Updates(updates)
+	if result.Error != nil {
+		logrus.Errorf("更新任务状态失败: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		publishTaskEvent(task)
+		if task.Status == model.DownloadTaskStatusFailed {
+			notifyTaskFinished(task)
+		}
+	}
+}
+
+// notifyTaskFinished 通知任务创建人下载任务已执行成功或失败
+func notifyTaskFinished(task *model.DownloadTask) {
+	event := model.MessageEventDownloadSuccess
+	if task.Status == model.DownloadTaskStatusFailed {
+		event = model.MessageEventDownloadFailed
+	}
+	notifyAsync(&Notification{
+		CompanyID: task.CompanyID,
+		Event:     event,
+		UserIDs:   []uint{task.UserID},
+		Data: map[string]interface{}{
+			"TaskID":     task.ID,
+			"TaskName":   task.TaskName,
+			"FileName":   task.FileName,
+			"FileSize":   task.FileSize,
+			"FileURL":    task.FileURL,
+			"ErrorMsg":   task.ErrorMsg,
+			"FinishedAt": task.FinishedAt.Format("2006-01-02 15:04:05"),
+		},
+	})
+}
+
+// fetchProgressPercent 根据分页进度计算任务进度，请求阶段占 10%-90%
+// 响应中有总数时按记录数计算，否则按已获取页数与最大页数计算
+func fetchProgressPercent(progress pageProgress, pagination *model.APIPagination) int {
+	ratio := 1.0
+	if progress.Total > 0 {
+		ratio = float64(progress.Records) / float64(progress.Total)
+	} else if pagination != nil && pagination.MaxPages > 0 {
+		ratio = float64(progress.Pages) / float64(pagination.MaxPages)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	return 10 + int(ratio*80)
 }
 
 // GetResult 获取下载结果
@@ -413,9 +586,48 @@ func (s *DownloadTaskService) GetResult(taskID uint) (*model.DownloadResult, err
 		return nil, err
 	}
 
+	if result.StorageKey != "" {
+		result.DownloadURL, _ = signDownloadURL(context.Background(), &result)
+	}
+
 	return &result, nil
 }
 
+// GetDownloadURL 生成任务导出文件的签名下载地址
+func (s *DownloadTaskService) GetDownloadURL(ctx context.Context, task *model.DownloadTask) (string, error) {
+	if task.Status != model.DownloadTaskStatusSuccess {
+		return "", errors.New("下载任务尚未完成")
+	}
+
+	result, err := s.GetResult(task.ID)
+	if err != nil {
+		return "", err
+	}
+	if result.StorageKey == "" {
+		return "", errors.New("导出文件不存在或已过期")
+	}
+	if result.DownloadURL == "" {
+		return signDownloadURL(ctx, result)
+	}
+
+	return result.DownloadURL, nil
+}
+
+// signDownloadURL 为下载结果生成签名下载地址
+func signDownloadURL(ctx context.Context, result *model.DownloadResult) (string, error) {
+	fileStorage := storage.Get()
+	if fileStorage == nil {
+		return "", errors.New("文件存储未初始化")
+	}
+
+	url, err := fileStorage.SignedURL(ctx, result.StorageKey, result.FileName, signedURLExpire())
+	if err != nil {
+		logrus.Errorf("生成下载地址失败: %v", err)
+		return "", err
+	}
+	return url, nil
+}
+
 // GetTaskByUserID 根据用户ID获取下载任务列表
 func (s *DownloadTaskService) GetTaskByUserID(userID uint) ([]model.DownloadTask, error) {
 	db := database.GetDB()
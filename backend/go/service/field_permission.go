@@ -257,3 +257,76 @@ func (s *FieldPermissionService) CheckFieldEditable(userID uint, module, field s
 	// 4. 既没有特殊权限也没有字典限制，默认允许编辑（或根据具体 RBAC 决定）
 	return true, nil
 }
+
+// FieldAccess 用户对字段的查看权限
+type FieldAccess struct {
+	Viewable      bool `json:"viewable"`       // 是否可查看
+	ReportVisible bool `json:"report_visible"` // 是否在报表中显示
+}
+
+// ResolveFieldAccess 汇总用户所有角色对模块字段的查看权限
+// 与 CheckFieldEditable 处理 SpecialEdit 的方式一致：任意一个角色允许即允许，
+// 角色没有配置该字段时按默认值（允许）处理。只返回受限制的字段。
+// 没有角色的用户（包括 userID 为 0）与 CheckFieldEditable 一样拒绝：模块中配置过权限的字段均不可查看。
+func (s *FieldPermissionService) ResolveFieldAccess(userID uint, module string) (map[string]FieldAccess, error) {
+	db := database.GetDB()
+
+	// 1. 获取用户的角色ID列表
+	var roleIDs []uint
+	if err := db.Table("user_roles").Where("user_id = ?", userID).Distinct().Pluck("role_id", &roleIDs).Error; err != nil {
+		logrus.Errorf("获取用户角色失败: %v", err)
+		return nil, err
+	}
+
+	restricted := make(map[string]FieldAccess)
+	if len(roleIDs) == 0 {
+		var fields []string
+		if err := db.Model(&model.FieldPermission{}).Where("module = ?", module).Distinct().Pluck("field", &fields).Error; err != nil {
+			logrus.Errorf("获取模块字段权限失败: %v", err)
+			return nil, err
+		}
+		for _, field := range fields {
+			restricted[field] = FieldAccess{Viewable: false, ReportVisible: true}
+		}
+		return restricted, nil
+	}
+
+	// 2. 按字段汇总各角色的配置
+	fieldPermissions, err := s.GetByRoleIDsAndModule(roleIDs, module)
+	if err != nil {
+		return nil, err
+	}
+
+	type fieldRules struct {
+		roles         map[uint]bool
+		viewable      bool
+		reportVisible bool
+	}
+	rules := make(map[string]*fieldRules)
+	for _, permission := range fieldPermissions {
+		rule, ok := rules[permission.Field]
+		if !ok {
+			rule = &fieldRules{roles: make(map[uint]bool)}
+			rules[permission.Field] = rule
+		}
+		rule.roles[permission.RoleID] = true
+		if util.IntValue(permission.Viewable, 1) == 1 {
+			rule.viewable = true
+		}
+		if util.IntValue(permission.ReportVisible, 1) == 1 {
+			rule.reportVisible = true
+		}
+	}
+
+	// 3. 有角色未配置该字段时按默认值允许
+	for field, rule := range rules {
+		if len(rule.roles) < len(roleIDs) {
+			continue
+		}
+		if !rule.viewable || !rule.reportVisible {
+			restricted[field] = FieldAccess{Viewable: rule.viewable, ReportVisible: rule.reportVisible}
+		}
+	}
+
+	return restricted, nil
+}
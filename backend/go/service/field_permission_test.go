@@ -87,3 +87,60 @@ func TestCheckFieldEditable(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveFieldAccess(t *testing.T) {
+	setupTestDB()
+	db := database.GetDB()
+	svc := NewFieldPermissionService()
+
+	userID := uint(1)
+	module := "employee"
+	db.Create(&model.UserRole{UserID: userID, RoleID: 1})
+	db.Create(&model.FieldPermission{RoleID: 1, Module: module, Field: "salary", ReportVisible: util.IntPtr(0)})
+	db.Create(&model.FieldPermission{RoleID: 1, Module: module, Field: "mobile", Viewable: util.IntPtr(0)})
+
+	columns := []exportColumn{{Field: "mobile", Label: "手机号"}, {Field: "name", Label: "姓名"}, {Field: "salary", Label: "薪资"}}
+
+	t.Run("SingleRole", func(t *testing.T) {
+		kept, redactions, err := redactColumns(userID, module, columns)
+		if err != nil {
+			t.Fatalf("redactColumns failed: %v", err)
+		}
+		if len(kept) != 2 || !kept[0].Masked || kept[1].Field != "name" {
+			t.Errorf("Expected masked mobile and name kept, got %+v", kept)
+		}
+		if len(redactions) != 2 || redactions[1].Reason != redactReasonReportHidden {
+			t.Errorf("Expected salary removed as report hidden, got %+v", redactions)
+		}
+	})
+
+	t.Run("AnyRoleGrants", func(t *testing.T) {
+		// 另一个角色没有配置 salary，按默认值允许导出
+		db.Create(&model.UserRole{UserID: userID, RoleID: 2})
+		db.Create(&model.FieldPermission{RoleID: 2, Module: module, Field: "mobile", Viewable: util.IntPtr(1)})
+
+		access, err := svc.ResolveFieldAccess(userID, module)
+		if err != nil {
+			t.Fatalf("ResolveFieldAccess failed: %v", err)
+		}
+		if len(access) != 0 {
+			t.Errorf("Expected no restricted fields, got %+v", access)
+		}
+	})
+
+	t.Run("NoRoles", func(t *testing.T) {
+		// 没有角色的用户及 user_id 为 0 的任务：配置过权限的字段全部按不可查看处理
+		for _, id := range []uint{0, 99} {
+			kept, redactions, err := redactColumns(id, module, columns)
+			if err != nil {
+				t.Fatalf("redactColumns failed: %v", err)
+			}
+			if len(kept) != 3 || !kept[0].Masked || kept[1].Masked || !kept[2].Masked {
+				t.Errorf("User %d: expected mobile and salary masked, got %+v", id, kept)
+			}
+			if len(redactions) != 2 || redactions[0].Reason != redactReasonNotViewable {
+				t.Errorf("User %d: unexpected redactions %+v", id, redactions)
+			}
+		}
+	})
+}
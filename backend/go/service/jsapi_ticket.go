@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// jsapiTicketGroup 合并本实例内同一公司的并发刷新
+var jsapiTicketGroup singleflight.Group
+
+// localJSAPITickets 未连接 Redis 时在本实例内缓存 jsapi_ticket
+var localJSAPITickets sync.Map
+
+// jsapiTicketCacheKey 返回公司 jsapi_ticket 在Redis中的键
+func jsapiTicketCacheKey(companyID uint) string {
+	return fmt.Sprintf("jsapi_ticket:%d", companyID)
+}
+
+// JSAPITicket 缓存的 jsapi_ticket
+type JSAPITicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresIn int       `json:"expires_in"` // 有效期（秒）
+	RefreshAt time.Time `json:"refresh_at"` // 获取时间
+	ExpiresAt time.Time `json:"expires_at"` // 过期时间
+}
+
+// refreshDue 与 AccessToken 相同，在有效期过去一定比例后提前刷新
+func (t *JSAPITicket) refreshDue(now time.Time) bool {
+	if t.Ticket == "" || !now.Before(t.ExpiresAt) {
+		return true
+	}
+	lifetime := time.Duration(t.ExpiresIn) * time.Second
+	return !now.Before(t.RefreshAt.Add(lifetime * time.Duration(tokenRefreshPercent()) / 100))
+}
+
+// JSAPISignature 前端调用 dd.config 所需的签名参数，字段名与 dd.config 保持一致
+type JSAPISignature struct {
+	URL       string `json:"url"`
+	NonceStr  string `json:"nonceStr"`
+	TimeStamp string `json:"timeStamp"`
+	Signature string `json:"signature"`
+}
+
+// JSAPITicketService 钉钉 H5 微应用 jsapi_ticket 服务
+type JSAPITicketService struct {
+	tokens *TokenProvider
+}
+
+// NewJSAPITicketService 创建 jsapi_ticket 服务实例
+func NewJSAPITicketService() *JSAPITicketService {
+	return &JSAPITicketService{tokens: NewTokenProvider()}
+}
+
+// Sign 为页面地址生成 dd.config 签名
+func (s *JSAPITicketService) Sign(ctx context.Context, companyID uint, pageURL string) (*JSAPISignature, error) {
+	signURL, err := jsapiSignURL(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.Ticket(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	signature := &JSAPISignature{
+		URL:       pageURL,
+		NonceStr:  hex.EncodeToString(nonce),
+		TimeStamp: strconv.FormatInt(time.Now().UnixMilli(), 10),
+	}
+	signature.Signature = jsapiSignature(ticket, signature.NonceStr, signature.TimeStamp, signURL)
+	return signature, nil
+}
+
+// Ticket 获取公司当前有效的 jsapi_ticket，需要提前刷新但刷新失败时继续使用未过期的 ticket
+func (s *JSAPITicketService) Ticket(ctx context.Context, companyID uint) (string, error) {
+	now := time.Now()
+	cached := s.load(ctx, companyID)
+	if cached != nil && !cached.refreshDue(now) {
+		return cached.Ticket, nil
+	}
+
+	ticket, err := s.refresh(ctx, companyID)
+	if err != nil {
+		if cached != nil && now.Before(cached.ExpiresAt) {
+			logrus.Warnf("刷新jsapi_ticket失败，继续使用未过期的ticket，公司ID: %d, 错误: %v", companyID, err)
+			return cached.Ticket, nil
+		}
+		return "", err
+	}
+	return ticket.Ticket, nil
+}
+
+// refresh 刷新公司的 jsapi_ticket
+// 本实例内同一公司的并发刷新只请求一次钉钉，多实例之间由 Redis 锁保证同一时间只有一个实例刷新
+func (s *JSAPITicketService) refresh(ctx context.Context, companyID uint) (*JSAPITicket, error) {
+	value, err, _ := jsapiTicketGroup.Do(strconv.FormatUint(uint64(companyID), 10), func() (interface{}, error) {
+		return s.refreshLocked(ctx, companyID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*JSAPITicket), nil
+}
+
+// refreshLocked 获取分布式锁后刷新，锁被其他实例持有时等待其刷新结果
+func (s *JSAPITicketService) refreshLocked(ctx context.Context, companyID uint) (*JSAPITicket, error) {
+	lockKey := fmt.Sprintf("jsapi_ticket:refresh_lock:%d", companyID)
+	locked, err := database.TryLock(ctx, lockKey, tokenRefreshLockTTL)
+	if err != nil {
+		// Redis 不可用时仍然刷新，最多多请求一次钉钉
+		logrus.Errorf("获取jsapi_ticket刷新锁失败: %v", err)
+		locked = true
+	}
+	if !locked {
+		return s.waitForRefresh(ctx, companyID)
+	}
+	defer database.Unlock(context.Background(), lockKey)
+
+	// 等锁期间其他实例可能已经刷新过
+	if cached := s.load(ctx, companyID); cached != nil && !cached.refreshDue(time.Now()) {
+		return cached, nil
+	}
+
+	ticket, err := s.fetch(ctx, companyID)
+	if err != nil {
+		logrus.Errorf("获取jsapi_ticket失败，公司ID: %d, 错误: %v", companyID, err)
+		return nil, err
+	}
+	s.store(ctx, companyID, ticket)
+
+	logrus.Infof("刷新jsapi_ticket成功，公司ID: %d, 过期时间: %s", companyID, ticket.ExpiresAt.Format(time.RFC3339))
+	return ticket, nil
+}
+
+// waitForRefresh 等待其他实例刷新完成
+func (s *JSAPITicketService) waitForRefresh(ctx context.Context, companyID uint) (*JSAPITicket, error) {
+	deadline := time.Now().Add(tokenRefreshWait)
+	for {
+		if cached := s.load(ctx, companyID); cached != nil && !cached.refreshDue(time.Now()) {
+			return cached, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("jsapi_ticket正在刷新，请稍后重试")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// fetch 使用公司的旧版 AccessToken 调用钉钉 get_jsapi_ticket 接口
+// AccessToken 失效时由 TokenProvider 刷新后重试一次
+func (s *JSAPITicketService) fetch(ctx context.Context, companyID uint) (*JSAPITicket, error) {
+	apiConfig := &model.APIConfig{CompanyID: companyID, Type: model.APITypeLegacy, AuthMode: model.AuthModeQuery}
+	newRequest := func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, dingTalkOAPIURL("/get_jsapi_ticket"), nil)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	_, body, err := s.tokens.Do(ctx, client, apiConfig, newRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Errcode   int    `json:"errcode"`
+		Errmsg    string `json:"errmsg"`
+		Ticket    string `json:"ticket"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析jsapi_ticket响应失败: %v", err)
+	}
+	if result.Errcode != 0 || result.Ticket == "" {
+		return nil, fmt.Errorf("获取jsapi_ticket失败：%s", result.Errmsg)
+	}
+	if result.ExpiresIn <= 0 {
+		result.ExpiresIn = 7200 // 默认2小时
+	}
+
+	now := time.Now()
+	return &JSAPITicket{
+		Ticket:    result.Ticket,
+		ExpiresIn: result.ExpiresIn,
+		RefreshAt: now,
+		ExpiresAt: now.Add(time.Duration(result.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// load 读取缓存的 jsapi_ticket，不存在或已过期时返回 nil
+func (s *JSAPITicketService) load(ctx context.Context, companyID uint) *JSAPITicket {
+	var ticket JSAPITicket
+
+	redisClient := database.GetRedis()
+	if redisClient == nil {
+		value, ok := localJSAPITickets.Load(companyID)
+		if !ok {
+			return nil
+		}
+		ticket = value.(JSAPITicket)
+	} else {
+		data, err := redisClient.Get(ctx, jsapiTicketCacheKey(companyID)).Bytes()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				logrus.Errorf("读取jsapi_ticket缓存失败: %v", err)
+			}
+			return nil
+		}
+		if err := json.Unmarshal(data, &ticket); err != nil {
+			logrus.Errorf("解析jsapi_ticket缓存失败: %v", err)
+			return nil
+		}
+	}
+
+	if !time.Now().Before(ticket.ExpiresAt) {
+		return nil
+	}
+	return &ticket
+}
+
+// store 缓存 jsapi_ticket 直到过期
+func (s *JSAPITicketService) store(ctx context.Context, companyID uint, ticket *JSAPITicket) {
+	redisClient := database.GetRedis()
+	if redisClient == nil {
+		localJSAPITickets.Store(companyID, *ticket)
+		return
+	}
+
+	data, _ := json.Marshal(ticket)
+	if err := redisClient.Set(ctx, jsapiTicketCacheKey(companyID), data, time.Until(ticket.ExpiresAt)).Err(); err != nil {
+		logrus.Errorf("写入jsapi_ticket缓存失败: %v", err)
+	}
+}
+
+// jsapiSignURL 返回参与签名的页面地址
+// 与钉钉官方示例一致：去掉 # 之后的部分，查询参数解码后参与签名
+func jsapiSignURL(pageURL string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("页面地址格式错误")
+	}
+
+	signURL := u.Scheme + "://" + u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		query, err := url.QueryUnescape(u.RawQuery)
+		if err != nil {
+			query = u.RawQuery
+		}
+		signURL += "?" + query
+	}
+	return signURL, nil
+}
+
+// jsapiSignature 计算 dd.config 签名
+func jsapiSignature(ticket, nonceStr, timeStamp, signURL string) string {
+	plain := "jsapi_ticket=" + ticket + "&noncestr=" + nonceStr + "&timestamp=" + timeStamp + "&url=" + signURL
+	sum := sha1.Sum([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestJSAPISignature(t *testing.T) {
+	t.Run("SignURL", func(t *testing.T) {
+		signURL, err := jsapiSignURL("https://h5.example.com/app/index.html?corpId=ding123&name=a%20b#/home")
+		if err != nil {
+			t.Fatalf("jsapiSignURL failed: %v", err)
+		}
+		if signURL != "https://h5.example.com/app/index.html?corpId=ding123&name=a b" {
+			t.Errorf("Unexpected sign url: %s", signURL)
+		}
+		if _, err := jsapiSignURL("/app/index.html"); err == nil {
+			t.Error("Expected error for relative url")
+		}
+	})
+
+	t.Run("Signature", func(t *testing.T) {
+		signature := jsapiSignature("mock-ticket", "abcdef", "1700000000000", "https://h5.example.com/app/index.html?corpId=ding123&name=a b")
+		if signature != "a6710ec62d12307d623b27b7e320bf71477c5f40" {
+			t.Errorf("Unexpected signature: %s", signature)
+		}
+	})
+}
+
+func TestJSAPITicketWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.AccessTokenRefreshLog{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+	localJSAPITickets.Delete(uint(1))
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	if _, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret"}); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+
+	svc := NewJSAPITicketService()
+	pageURL := "https://h5.example.com/app/index.html?corpId=ding123"
+	first, err := svc.Sign(context.Background(), 1, pageURL)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	second, err := svc.Sign(context.Background(), 1, pageURL)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if calls := mock.Calls(dingtalkmock.EndpointGetJSAPITicket); calls != 1 {
+		t.Errorf("Expected ticket cached, got %d get_jsapi_ticket calls", calls)
+	}
+
+	ticket, _ := svc.Ticket(context.Background(), 1)
+	if second.Signature != jsapiSignature(ticket, second.NonceStr, second.TimeStamp, pageURL) {
+		t.Error("Signature does not match cached ticket")
+	}
+	if first.NonceStr == second.NonceStr || second.URL != pageURL {
+		t.Errorf("Unexpected signature fields: %+v %+v", first, second)
+	}
+
+	// AccessToken 失效时刷新后重新获取
+	localJSAPITickets.Delete(uint(1))
+	mock.ExpireTokens()
+	if _, err := svc.Ticket(context.Background(), 1); err != nil {
+		t.Fatalf("Expected ticket after access token refresh, got %v", err)
+	}
+	if calls := mock.Calls(dingtalkmock.EndpointGetToken); calls != 2 {
+		t.Errorf("Expected access token refreshed once, got %d gettoken calls", calls)
+	}
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrLegacyServiceUnavailable 旧版API服务无法连接、调用超时或健康检查失败
+var ErrLegacyServiceUnavailable = errors.New("旧版API服务不可用")
+
+// 旧版API服务的接口路径
+const (
+	legacyExecutePath = "/api/v1/legacy/execute"
+	legacyHealthPath  = "/health"
+)
+
+// legacySecretHeader 携带共享密钥的请求头，Python 侧校验后才执行调用
+const legacySecretHeader = "X-Legacy-Secret"
+
+const (
+	legacyHealthTimeout = 5 * time.Second // 单次健康检查的超时时间
+	legacyTimeoutMargin = 5 * time.Second // Go 侧在 Python 侧超时基础上多等待的时间，保证优先拿到 Python 返回的超时原因
+)
+
+// 旧版API服务的健康状态
+const (
+	LegacyStatusUp   = "up"
+	LegacyStatusDown = "down"
+)
+
+// LegacyRequest 发送给旧版API服务的调用请求
+// 鉴权由 Go 侧完成，URL 中已附加 access_token，Python 侧按原样发出请求
+type LegacyRequest struct {
+	CompanyID   uint              `json:"company_id"`
+	APIConfigID uint              `json:"api_config_id"`
+	APICode     string            `json:"api_code"`
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`       // 原始请求体，GET/DELETE 请求为空
+	TimeoutMs   int64             `json:"timeout_ms"` // Python 侧调用钉钉接口的超时时间
+}
+
+// LegacyResponse 旧版API服务返回的调用结果
+type LegacyResponse struct {
+	StatusCode int               `json:"status_code"`
+	Status     string            `json:"status"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`     // 钉钉接口的原始响应体
+	Duration   int64             `json:"duration"` // Python 侧调用耗时（毫秒）
+	Error      string            `json:"error"`    // 调用钉钉接口失败的原因，为空表示已拿到响应
+}
+
+// LegacyHealth 旧版API服务的健康状态
+type LegacyHealth struct {
+	Status    string    `json:"status"`  // up, down
+	URL       string    `json:"url"`     // 旧版API服务地址
+	Error     string    `json:"error"`   // 不可用的原因
+	Latency   int64     `json:"latency"` // 健康检查耗时（毫秒）
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// legacyState 最近一次确认的旧版API服务状态，由健康检查和代理调用共同更新
+var legacyState struct {
+	sync.RWMutex
+	health *LegacyHealth
+}
+
+// LegacyClient 调用 backend/python 旧版API服务
+type LegacyClient struct {
+	baseURL        string
+	secret         string
+	timeout        time.Duration
+	healthInterval time.Duration
+	httpClient     *http.Client
+}
+
+// NewLegacyClient 创建旧版API服务客户端
+func NewLegacyClient() *LegacyClient {
+	cfg := config.LegacyConfig{}
+	if config.GlobalConfig != nil {
+		cfg = config.GlobalConfig.Legacy
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:8081"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 30 * time.Second
+	}
+	return &LegacyClient{
+		baseURL:        strings.TrimSuffix(cfg.URL, "/"),
+		secret:         cfg.Secret,
+		timeout:        cfg.Timeout,
+		healthInterval: cfg.HealthInterval,
+		httpClient:     &http.Client{Timeout: cfg.Timeout + legacyTimeoutMargin},
+	}
+}
+
+// Execute 通过旧版API服务执行一次接口调用
+// 最近一次确认服务不可用且未超过健康检查间隔时直接返回 ErrLegacyServiceUnavailable，避免每个请求都等待超时
+func (c *LegacyClient) Execute(ctx context.Context, req *LegacyRequest) (*LegacyResponse, error) {
+	if health := c.LastHealth(); health != nil && health.Status == LegacyStatusDown && time.Since(health.CheckedAt) < c.healthInterval {
+		return nil, c.unavailable(errors.New(health.Error))
+	}
+
+	if req.TimeoutMs <= 0 {
+		req.TimeoutMs = c.timeout.Milliseconds()
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("序列化旧版API请求失败: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+legacyExecutePath, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建旧版API请求失败: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(legacySecretHeader, c.secret)
+
+	startTime := time.Now()
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.record(LegacyStatusDown, err.Error(), time.Since(startTime))
+		logrus.Errorf("调用旧版API服务失败: %v", err)
+		return nil, c.unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, c.unavailable(fmt.Errorf("读取响应失败: %v", err))
+	}
+	if len(body) > maxResponseSize {
+		return nil, errors.New("旧版API服务响应体过大")
+	}
+
+	var result LegacyResponse
+	if err := json.Unmarshal(body, &result); err != nil || resp.StatusCode != http.StatusOK {
+		if result.Error != "" {
+			return nil, fmt.Errorf("旧版API服务返回错误: HTTP %d, %s", resp.StatusCode, result.Error)
+		}
+		return nil, fmt.Errorf("旧版API服务返回异常: HTTP %d", resp.StatusCode)
+	}
+	c.record(LegacyStatusUp, "", time.Since(startTime))
+
+	if result.Error != "" {
+		return nil, fmt.Errorf("旧版API服务调用接口失败: %s", result.Error)
+	}
+	return &result, nil
+}
+
+// Check 立即检查旧版API服务是否可用并记录结果
+func (c *LegacyClient) Check(ctx context.Context) *LegacyHealth {
+	checkCtx, cancel := context.WithTimeout(ctx, legacyHealthTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := c.ping(checkCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			// 停止检查时取消的请求不代表服务不可用
+			return c.LastHealth()
+		}
+		return c.record(LegacyStatusDown, err.Error(), time.Since(startTime))
+	}
+	return c.record(LegacyStatusUp, "", time.Since(startTime))
+}
+
+// ping 请求旧版API服务的健康检查接口，要求返回 {"status": "ok"}
+func (c *LegacyClient) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+legacyHealthPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil || resp.StatusCode != http.StatusOK || result.Status != "ok" {
+		return fmt.Errorf("健康检查返回异常: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LastHealth 返回最近一次确认的服务状态，尚未检查过时返回 nil
+func (c *LegacyClient) LastHealth() *LegacyHealth {
+	legacyState.RLock()
+	defer legacyState.RUnlock()
+	return legacyState.health
+}
+
+// record 记录服务状态，状态发生变化时输出日志
+func (c *LegacyClient) record(status, errMsg string, latency time.Duration) *LegacyHealth {
+	health := &LegacyHealth{
+		Status:    status,
+		URL:       c.baseURL,
+		Error:     errMsg,
+		Latency:   latency.Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+
+	legacyState.Lock()
+	previous := legacyState.health
+	legacyState.health = health
+	legacyState.Unlock()
+
+	if previous != nil && previous.Status != status {
+		if status == LegacyStatusDown {
+			logrus.Errorf("旧版API服务不可用，地址: %s, 原因: %s", c.baseURL, errMsg)
+		} else {
+			logrus.Infof("旧版API服务已恢复，地址: %s", c.baseURL)
+		}
+	}
+	return health
+}
+
+// unavailable 包装服务不可用错误，调用方可通过 errors.Is 判断
+func (c *LegacyClient) unavailable(err error) error {
+	return fmt.Errorf("%w（%s）: %v", ErrLegacyServiceUnavailable, c.baseURL, err)
+}
+
+// legacyTransport 将请求转发给旧版API服务执行
+// 作为 http.Client 的 Transport 使用，TokenProvider.Do 的鉴权和 AccessToken 失效重试逻辑保持不变
+type legacyTransport struct {
+	client    *LegacyClient
+	apiConfig *model.APIConfig
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *legacyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	headers := make(map[string]string, len(req.Header))
+	for key := range req.Header {
+		headers[key] = req.Header.Get(key)
+	}
+
+	result, err := t.client.Execute(req.Context(), &LegacyRequest{
+		CompanyID:   t.apiConfig.CompanyID,
+		APIConfigID: t.apiConfig.ID,
+		APICode:     t.apiConfig.Code,
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Headers:     headers,
+		Body:        string(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header, len(result.Headers))
+	for key, value := range result.Headers {
+		header.Set(key, value)
+	}
+	status := result.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", result.StatusCode, http.StatusText(result.StatusCode))
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    result.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(result.Body)),
+		ContentLength: int64(len(result.Body)),
+		Request:       req,
+	}, nil
+}
+
+// newAPIHTTPClient 返回执行API配置使用的HTTP客户端，旧版接口转发给旧版API服务执行
+func newAPIHTTPClient(apiConfig *model.APIConfig, timeout time.Duration) *http.Client {
+	if apiConfig.Type == model.APITypeLegacy {
+		return legacyHTTPClient(NewLegacyClient(), apiConfig)
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// legacyHTTPClient 返回通过旧版API服务发送请求的HTTP客户端，超时由 LegacyClient 控制
+func legacyHTTPClient(client *LegacyClient, apiConfig *model.APIConfig) *http.Client {
+	return &http.Client{
+		Transport: &legacyTransport{client: client, apiConfig: apiConfig},
+		// Python 侧不跟随重定向，按原样返回 3xx 响应
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// LegacyHealthChecker 定期检查旧版API服务是否可用
+type LegacyHealthChecker struct {
+	client *LegacyClient
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewLegacyHealthChecker 创建旧版API服务健康检查任务
+func NewLegacyHealthChecker() *LegacyHealthChecker {
+	return &LegacyHealthChecker{client: NewLegacyClient()}
+}
+
+// Start 启动健康检查
+func (h *LegacyHealthChecker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(h.client.healthInterval)
+		defer ticker.Stop()
+
+		for {
+			h.client.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	logrus.Infof("旧版API服务健康检查已启动，地址: %s, 间隔: %s", h.client.baseURL, h.client.healthInterval)
+}
+
+// Stop 停止健康检查
+func (h *LegacyHealthChecker) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+// testLegacySecret 模拟的旧版API服务要求的共享密钥
+const testLegacySecret = "legacy-secret"
+
+// startLegacyService 启动模拟的 Python 旧版API服务，按约定转发请求，failure 不为空时返回调用失败
+func startLegacyService(calls *int32, failure *string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(legacyHealthPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc(legacyExecutePath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(legacySecretHeader) != testLegacySecret {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "密钥错误"})
+			return
+		}
+		atomic.AddInt32(calls, 1)
+		var req LegacyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if *failure != "" {
+			json.NewEncoder(w).Encode(LegacyResponse{Error: *failure})
+			return
+		}
+
+		httpReq, _ := http.NewRequest(req.Method, req.URL, strings.NewReader(req.Body))
+		for k, v := range req.Headers {
+			httpReq.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			json.NewEncoder(w).Encode(LegacyResponse{Error: err.Error()})
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		json.NewEncoder(w).Encode(LegacyResponse{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)})
+	})
+	return httptest.NewServer(mux)
+}
+
+// resetLegacyState 清除记录的旧版API服务状态
+func resetLegacyState() {
+	legacyState.Lock()
+	legacyState.health = nil
+	legacyState.Unlock()
+}
+
+func TestLegacyClientWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{})
+	db := database.GetDB()
+	_, stop := startDingTalkMock()
+	defer stop()
+
+	var calls int32
+	var failure string
+	legacy := startLegacyService(&calls, &failure)
+	defer legacy.Close()
+	config.GlobalConfig.Legacy = config.LegacyConfig{URL: legacy.URL, Secret: testLegacySecret, Timeout: 5 * time.Second, HealthInterval: time.Minute}
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	if _, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret"}); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+
+	apiConfig := &model.APIConfig{
+		ID:        1,
+		CompanyID: 1,
+		Code:      "department_listsub",
+		Type:      model.APITypeLegacy,
+		BaseURL:   config.GlobalConfig.DingTalk.OAPIBaseURL,
+		Path:      dingtalkmock.EndpointDepartmentListSub,
+		Method:    http.MethodPost,
+		Params:    `{"dept_id": 1}`,
+	}
+
+	t.Run("Download", func(t *testing.T) {
+		resp, err := NewAPIClient().Do(context.Background(), apiConfig, map[string]interface{}{"dept_id": 1}, nil)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		if err := resp.CheckError(); err != nil {
+			t.Fatalf("Unexpected response: %v", err)
+		}
+		if _, ok := resp.Data["result"].([]interface{}); !ok || atomic.LoadInt32(&calls) != 1 {
+			t.Errorf("Expected request proxied once, got %d calls, data %v", calls, resp.Data)
+		}
+	})
+
+	t.Run("ConfigTest", func(t *testing.T) {
+		result, err := NewAPIConfigService().Test(apiConfig)
+		if err != nil {
+			t.Fatalf("Test failed: %v", err)
+		}
+		if result["success"] != true || atomic.LoadInt32(&calls) != 2 {
+			t.Errorf("Unexpected result: %v, calls %d", result, calls)
+		}
+	})
+
+	t.Run("CallFailed", func(t *testing.T) {
+		failure = "调用接口超时（30秒）"
+		defer func() { failure = "" }()
+
+		_, err := NewAPIClient().Do(context.Background(), apiConfig, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), failure) || errors.Is(err, ErrLegacyServiceUnavailable) {
+			t.Errorf("Expected call failure, got %v", err)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		config.GlobalConfig.Legacy.Secret = "wrong"
+		defer func() { config.GlobalConfig.Legacy.Secret = testLegacySecret }()
+
+		_, err := NewAPIClient().Do(context.Background(), apiConfig, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "HTTP 403") {
+			t.Errorf("Expected rejected request, got %v", err)
+		}
+	})
+
+	t.Run("ServiceDown", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		config.GlobalConfig.Legacy.URL = down.URL
+		defer func() { config.GlobalConfig.Legacy.URL = legacy.URL }()
+
+		client := NewLegacyClient()
+		if health := client.Check(context.Background()); health.Status != LegacyStatusDown || health.Error == "" {
+			t.Errorf("Expected service down, got %+v", health)
+		}
+
+		_, err := NewAPIClient().Do(context.Background(), apiConfig, nil, nil)
+		if !errors.Is(err, ErrLegacyServiceUnavailable) {
+			t.Errorf("Expected ErrLegacyServiceUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("Recovered", func(t *testing.T) {
+		// 服务恢复后健康检查更新状态，请求不再直接失败
+		if health := NewLegacyClient().Check(context.Background()); health.Status != LegacyStatusUp {
+			t.Fatalf("Expected service up, got %+v", health)
+		}
+		if _, err := NewAPIClient().Do(context.Background(), apiConfig, map[string]interface{}{"dept_id": 1}, nil); err != nil {
+			t.Errorf("Do failed after recovery: %v", err)
+		}
+	})
+}
@@ -0,0 +1,572 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/util"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// workNoticeMaxReceivers 工作通知每次最多发送的用户数
+const workNoticeMaxReceivers = 100
+
+// messageNotifyTimeout 后台发送通知的超时
+const messageNotifyTimeout = 30 * time.Second
+
+// Notifier 消息通知，其他模块通过它在事件发生时发送通知
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// Notification 一次通知，按公司和事件找到消息模板，渲染后发送到模板配置的渠道
+type Notification struct {
+	CompanyID uint
+	Event     string                 // 触发事件，对应消息模板的 event
+	UserIDs   []uint                 // 与事件相关的系统用户，通过免登绑定关系映射为钉钉 userid 接收工作通知
+	Data      map[string]interface{} // 模板变量
+}
+
+// notifier 当前使用的消息通知实现
+var notifier = struct {
+	sync.RWMutex
+	Notifier
+}{Notifier: NewMessageService()}
+
+// SetNotifier 替换消息通知实现并返回原来的实现，用于测试或接入其他通知方式
+func SetNotifier(n Notifier) Notifier {
+	notifier.Lock()
+	defer notifier.Unlock()
+	previous := notifier.Notifier
+	notifier.Notifier = n
+	return previous
+}
+
+// notifyAsync 在后台发送通知，发送失败只记录日志，不影响调用方
+func notifyAsync(notification *Notification) {
+	notifier.RLock()
+	n := notifier.Notifier
+	notifier.RUnlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), messageNotifyTimeout)
+		defer cancel()
+		if err := n.Notify(ctx, notification); err != nil {
+			logrus.Warnf("发送通知失败，公司ID: %d, 事件: %s, 错误: %v", notification.CompanyID, notification.Event, err)
+		}
+	}()
+}
+
+// MessageService 工作通知和机器人消息服务
+type MessageService struct{}
+
+// NewMessageService 创建消息服务实例
+func NewMessageService() *MessageService {
+	return &MessageService{}
+}
+
+// Notify 按消息模板发送通知，公司未配置或禁用了该事件的模板时不发送
+func (s *MessageService) Notify(ctx context.Context, notification *Notification) error {
+	db := database.GetDB()
+
+	var tmpl model.MessageTemplate
+	err := db.Where("company_id = ? AND event = ? AND status = ?", notification.CompanyID, notification.Event, 1).First(&tmpl).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		logrus.Errorf("获取消息模板失败: %v", err)
+		return err
+	}
+
+	title, err := renderMessage(tmpl.Title, notification.Data)
+	if err != nil {
+		return err
+	}
+	content, err := renderMessage(tmpl.Content, notification.Data)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, channel := range splitList(tmpl.Channels) {
+		message := &OutgoingMessage{
+			CompanyID: notification.CompanyID,
+			Event:     notification.Event,
+			Channel:   channel,
+			RobotID:   tmpl.RobotID,
+			Title:     title,
+			Content:   content,
+		}
+		if channel == model.MessageChannelWorkNotice {
+			receivers, err := s.resolveReceivers(notification.CompanyID, notification.UserIDs)
+			if err != nil {
+				return err
+			}
+			message.Receivers = mergeReceivers(receivers, splitList(tmpl.Receivers))
+		}
+		if _, err := s.Send(ctx, message); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// OutgoingMessage 一条待发送的消息，标题和内容已渲染
+type OutgoingMessage struct {
+	CompanyID uint     `json:"company_id" binding:"required"`
+	Event     string   `json:"event"`
+	Channel   string   `json:"channel" binding:"required"` // work_notice, robot
+	Receivers []string `json:"receivers"`                  // 工作通知的接收人钉钉 userid
+	RobotID   uint     `json:"robot_id"`                   // 机器人ID
+	Title     string   `json:"title" binding:"required"`
+	Content   string   `json:"content"`
+}
+
+// Send 发送一条消息并保存发送记录，工作通知超过 100 人时分批发送，每批一条记录
+func (s *MessageService) Send(ctx context.Context, message *OutgoingMessage) ([]model.MessageLog, error) {
+	switch message.Channel {
+	case model.MessageChannelWorkNotice:
+		if len(message.Receivers) == 0 {
+			log := s.newLog(message, nil)
+			s.recordLog(&log, time.Now(), errors.New("没有可接收工作通知的钉钉用户"))
+			return []model.MessageLog{log}, errors.New(log.ErrorMsg)
+		}
+
+		var logs []model.MessageLog
+		var firstErr error
+		for start := 0; start < len(message.Receivers); start += workNoticeMaxReceivers {
+			end := min(start+workNoticeMaxReceivers, len(message.Receivers))
+			log := s.newLog(message, message.Receivers[start:end])
+			started := time.Now()
+			err := s.sendWorkNotice(ctx, &log)
+			s.recordLog(&log, started, err)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			logs = append(logs, log)
+		}
+		return logs, firstErr
+
+	case model.MessageChannelRobot:
+		log := s.newLog(message, nil)
+		started := time.Now()
+		err := s.sendRobot(ctx, &log)
+		s.recordLog(&log, started, err)
+		return []model.MessageLog{log}, err
+
+	default:
+		return nil, fmt.Errorf("不支持的发送渠道: %s", message.Channel)
+	}
+}
+
+// newLog 创建消息的发送记录
+func (s *MessageService) newLog(message *OutgoingMessage, receivers []string) model.MessageLog {
+	log := model.MessageLog{
+		CompanyID: message.CompanyID,
+		Event:     message.Event,
+		Channel:   message.Channel,
+		Receivers: strings.Join(receivers, ","),
+		Title:     message.Title,
+		Content:   message.Content,
+	}
+	if message.Channel == model.MessageChannelRobot {
+		log.RobotID = message.RobotID
+	}
+	return log
+}
+
+// recordLog 保存发送结果
+func (s *MessageService) recordLog(log *model.MessageLog, started time.Time, sendErr error) {
+	log.Status = model.MessageStatusSuccess
+	log.Duration = time.Since(started).Milliseconds()
+	if sendErr != nil {
+		log.Status = model.MessageStatusFailed
+		log.ErrorMsg = sendErr.Error()
+		var dingTalkErr *DingTalkError
+		if errors.As(sendErr, &dingTalkErr) {
+			log.Errcode = dingTalkErr.Errcode
+		}
+		logrus.Errorf("发送消息失败，公司ID: %d, 渠道: %s, 错误: %v", log.CompanyID, log.Channel, sendErr)
+	}
+
+	if err := database.GetDB().Create(log).Error; err != nil {
+		logrus.Errorf("保存消息发送记录失败: %v", err)
+	}
+}
+
+// sendWorkNotice 使用公司应用的 AgentID 发送 Markdown 工作通知
+func (s *MessageService) sendWorkNotice(ctx context.Context, log *model.MessageLog) error {
+	var accessToken model.AccessToken
+	if err := database.GetDB().
+		Where("company_id = ? AND token_type = ?", log.CompanyID, model.AccessTokenTypeLegacy).
+		First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("公司未配置AccessToken")
+		}
+		return err
+	}
+	if accessToken.AgentID == 0 {
+		return errors.New("公司未配置应用的AgentID")
+	}
+
+	payload := map[string]interface{}{
+		"agent_id":    accessToken.AgentID,
+		"userid_list": log.Receivers,
+		"msg":         markdownMessage(log.Title, log.Content),
+	}
+	var result struct {
+		TaskID int64 `json:"task_id"`
+	}
+	if err := callOAPI(ctx, NewTokenProvider(), log.CompanyID, "发送工作通知", "/topapi/message/corpconversation/asyncsend_v2", payload, &result); err != nil {
+		return err
+	}
+	log.DingTaskID = result.TaskID
+	return nil
+}
+
+// sendRobot 向群自定义机器人发送 Markdown 消息，配置了加签密钥时附带签名
+func (s *MessageService) sendRobot(ctx context.Context, log *model.MessageLog) error {
+	var robot model.MessageRobot
+	if err := database.GetDB().Where("id = ? AND company_id = ?", log.RobotID, log.CompanyID).First(&robot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("机器人不存在")
+		}
+		return err
+	}
+	if robot.Status != 1 {
+		return errors.New("机器人已禁用")
+	}
+
+	webhook, err := url.Parse(string(robot.Webhook))
+	if err != nil {
+		return fmt.Errorf("机器人Webhook地址错误: %v", err)
+	}
+	if robot.Secret != "" {
+		timestamp := time.Now().UnixMilli()
+		query := webhook.Query()
+		query.Set("timestamp", strconv.FormatInt(timestamp, 10))
+		query.Set("sign", robotSign(string(robot.Secret), timestamp))
+		webhook.RawQuery = query.Encode()
+	}
+
+	data, _ := json.Marshal(markdownMessage(log.Title, log.Content))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送机器人消息失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析机器人消息响应失败: %v", err)
+	}
+	if result.Errcode != 0 {
+		return &DingTalkError{Op: "发送机器人消息", Errcode: result.Errcode, Errmsg: result.Errmsg}
+	}
+	return nil
+}
+
+// robotSign 计算机器人加签：timestamp + "\n" + secret 以 secret 做 HmacSHA256 后 base64
+func robotSign(secret string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%s", timestamp, secret)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// markdownMessage 构造 Markdown 消息体，工作通知和机器人使用相同的格式
+func markdownMessage(title, content string) map[string]interface{} {
+	if content == "" {
+		content = title
+	}
+	return map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": title, "text": content},
+	}
+}
+
+// renderMessage 使用模板变量渲染标题或内容
+func renderMessage(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("消息模板格式错误: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染消息模板失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// resolveReceivers 通过免登绑定关系将系统用户映射为钉钉 userid，未绑定的用户忽略
+func (s *MessageService) resolveReceivers(companyID uint, userIDs []uint) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var receivers []string
+	if err := database.GetDB().Model(&model.SSOBinding{}).
+		Where("company_id = ? AND user_id IN ?", companyID, userIDs).
+		Pluck("dingtalk_userid", &receivers).Error; err != nil {
+		logrus.Errorf("获取用户的钉钉绑定失败: %v", err)
+		return nil, err
+	}
+	return receivers, nil
+}
+
+// mergeReceivers 合并接收人并去重
+func mergeReceivers(lists ...[]string) []string {
+	var merged []string
+	for _, list := range lists {
+		for _, receiver := range list {
+			if !util.ContainsString(merged, receiver) {
+				merged = append(merged, receiver)
+			}
+		}
+	}
+	return merged
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ListTemplates 获取公司的消息模板
+func (s *MessageService) ListTemplates(companyID uint) ([]model.MessageTemplate, error) {
+	var templates []model.MessageTemplate
+	if err := database.GetDB().Where("company_id = ?", companyID).Order("id").Find(&templates).Error; err != nil {
+		logrus.Errorf("获取消息模板失败: %v", err)
+		return nil, err
+	}
+	return templates, nil
+}
+
+// SaveTemplate 保存消息模板，同一公司同一事件已有模板时覆盖
+func (s *MessageService) SaveTemplate(tmpl *model.MessageTemplate) error {
+	db := database.GetDB()
+
+	if err := s.validateTemplate(tmpl); err != nil {
+		return err
+	}
+
+	var existing model.MessageTemplate
+	err := db.Where("company_id = ? AND event = ?", tmpl.CompanyID, tmpl.Event).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.Errorf("获取消息模板失败: %v", err)
+		return err
+	}
+	tmpl.ID = existing.ID
+	tmpl.CreatedAt = existing.CreatedAt
+
+	if err := db.Save(tmpl).Error; err != nil {
+		logrus.Errorf("保存消息模板失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// validateTemplate 校验消息模板
+func (s *MessageService) validateTemplate(tmpl *model.MessageTemplate) error {
+	if tmpl.Event == "" || tmpl.Title == "" {
+		return errors.New("事件和标题不能为空")
+	}
+	if _, err := renderMessage(tmpl.Title, nil); err != nil {
+		return err
+	}
+	if _, err := renderMessage(tmpl.Content, nil); err != nil {
+		return err
+	}
+
+	channels := splitList(tmpl.Channels)
+	if len(channels) == 0 {
+		return errors.New("发送渠道不能为空")
+	}
+	for _, channel := range channels {
+		switch channel {
+		case model.MessageChannelWorkNotice:
+		case model.MessageChannelRobot:
+			var count int64
+			database.GetDB().Model(&model.MessageRobot{}).Where("id = ? AND company_id = ?", tmpl.RobotID, tmpl.CompanyID).Count(&count)
+			if count == 0 {
+				return errors.New("机器人不存在")
+			}
+		default:
+			return fmt.Errorf("不支持的发送渠道: %s", channel)
+		}
+	}
+	tmpl.Channels = strings.Join(channels, ",")
+	tmpl.Receivers = strings.Join(splitList(tmpl.Receivers), ",")
+	return nil
+}
+
+// DeleteTemplate 删除消息模板
+func (s *MessageService) DeleteTemplate(id uint) error {
+	if err := database.GetDB().Delete(&model.MessageTemplate{}, id).Error; err != nil {
+		logrus.Errorf("删除消息模板失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListRobots 获取公司的机器人
+func (s *MessageService) ListRobots(companyID uint) ([]model.MessageRobot, error) {
+	var robots []model.MessageRobot
+	if err := database.GetDB().Where("company_id = ?", companyID).Order("id").Find(&robots).Error; err != nil {
+		logrus.Errorf("获取机器人失败: %v", err)
+		return nil, err
+	}
+	return robots, nil
+}
+
+// CreateRobot 创建机器人
+func (s *MessageService) CreateRobot(robot *model.MessageRobot) error {
+	if err := validateRobotWebhook(robot); err != nil {
+		return err
+	}
+	if robot.Secret.IsMasked() {
+		robot.Secret = ""
+	}
+	if err := database.GetDB().Create(robot).Error; err != nil {
+		logrus.Errorf("创建机器人失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateRobot 更新机器人，提交脱敏后的值表示不修改 Webhook 和加签密钥
+func (s *MessageService) UpdateRobot(robot *model.MessageRobot) error {
+	db := database.GetDB()
+
+	var existing model.MessageRobot
+	if err := db.First(&existing, robot.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("机器人不存在")
+		}
+		logrus.Errorf("获取机器人失败: %v", err)
+		return err
+	}
+
+	if robot.Webhook.IsMasked() {
+		robot.Webhook = existing.Webhook
+	}
+	if robot.Secret.IsMasked() {
+		robot.Secret = existing.Secret
+	}
+	if err := validateRobotWebhook(robot); err != nil {
+		return err
+	}
+	robot.CreatedAt = existing.CreatedAt
+
+	if err := db.Save(robot).Error; err != nil {
+		logrus.Errorf("更新机器人失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// validateRobotWebhook 校验机器人的 Webhook 地址
+func validateRobotWebhook(robot *model.MessageRobot) error {
+	if robot.Name == "" || robot.Webhook.IsMasked() {
+		return errors.New("机器人名称和Webhook地址不能为空")
+	}
+	webhook, err := url.Parse(string(robot.Webhook))
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return errors.New("机器人Webhook地址格式错误")
+	}
+	return nil
+}
+
+// DeleteRobot 删除机器人，仍被消息模板使用时不允许删除
+func (s *MessageService) DeleteRobot(id uint) error {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&model.MessageTemplate{}).Where("robot_id = ?", id).Count(&count).Error; err != nil {
+		logrus.Errorf("检查机器人是否被使用失败: %v", err)
+		return err
+	}
+	if count > 0 {
+		return errors.New("机器人正在被消息模板使用，无法删除")
+	}
+
+	if err := db.Delete(&model.MessageRobot{}, id).Error; err != nil {
+		logrus.Errorf("删除机器人失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListLogs 分页获取消息发送记录
+func (s *MessageService) ListLogs(companyID uint, event, channel, status string, page, pageSize int) ([]model.MessageLog, int64, error) {
+	db := database.GetDB()
+
+	var logs []model.MessageLog
+	var total int64
+
+	query := db.Model(&model.MessageLog{})
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取消息发送记录总数失败: %v", err)
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Order("id DESC").Find(&logs).Error; err != nil {
+		logrus.Errorf("获取消息发送记录失败: %v", err)
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
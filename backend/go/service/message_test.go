@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/secret"
+)
+
+// notifierFunc 将函数适配为 Notifier
+type notifierFunc func(ctx context.Context, notification *Notification) error
+
+func (f notifierFunc) Notify(ctx context.Context, notification *Notification) error {
+	return f(ctx, notification)
+}
+
+func TestMessageWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.MessageRobot{}, &model.SSOBinding{}, &model.MessageTemplate{}, &model.MessageLog{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+	svc := NewMessageService()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	token, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret", AgentID: 1001})
+	if err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+	db.Create(&model.SSOBinding{CompanyID: 1, UserID: 1, DingTalkUserID: "dev01"})
+
+	robot := &model.MessageRobot{
+		CompanyID: 1,
+		Name:      "运维群",
+		Webhook:   secret.String(robotWebhook(config.GlobalConfig.DingTalk.OAPIBaseURL, "mock-robot")),
+		Secret:    "mock-robot-secret",
+		Status:    1,
+	}
+	if err := svc.CreateRobot(robot); err != nil {
+		t.Fatalf("CreateRobot failed: %v", err)
+	}
+
+	tmpl := &model.MessageTemplate{
+		CompanyID: 1,
+		Event:     model.MessageEventDownloadSuccess,
+		Title:     "{{.TaskName}} 已完成",
+		Content:   "### {{.TaskName}}\n文件：{{.FileName}}",
+		Channels:  "work_notice, robot",
+		RobotID:   robot.ID,
+		Receivers: "manager01,dev01",
+		Status:    1,
+	}
+	if err := svc.SaveTemplate(tmpl); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+
+	t.Run("Notify", func(t *testing.T) {
+		err := svc.Notify(context.Background(), &Notification{
+			CompanyID: 1,
+			Event:     model.MessageEventDownloadSuccess,
+			UserIDs:   []uint{1, 2},
+			Data:      map[string]interface{}{"TaskName": "审批导出", "FileName": "a.csv"},
+		})
+		if err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+
+		messages := mock.Messages()
+		if len(messages) != 2 || messages[0].Target != "dev01,manager01" || messages[1].Target != "mock-robot" {
+			t.Fatalf("Unexpected messages: %+v", messages)
+		}
+		markdown := messages[1].Body["markdown"].(map[string]interface{})
+		if markdown["title"] != "审批导出 已完成" || markdown["text"] != "### 审批导出\n文件：a.csv" {
+			t.Errorf("Unexpected markdown: %v", markdown)
+		}
+
+		logs, total, _ := svc.ListLogs(1, model.MessageEventDownloadSuccess, "", model.MessageStatusSuccess, 1, 10)
+		if total != 2 || logs[1].DingTaskID == 0 {
+			t.Errorf("Unexpected logs: %+v", logs)
+		}
+	})
+
+	t.Run("NoTemplate", func(t *testing.T) {
+		if err := svc.Notify(context.Background(), &Notification{CompanyID: 1, Event: model.MessageEventDownloadFailed}); err != nil {
+			t.Errorf("Expected no error without template, got %v", err)
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		robot.Secret = "wrong-secret"
+		if err := svc.UpdateRobot(robot); err != nil {
+			t.Fatalf("UpdateRobot failed: %v", err)
+		}
+		logs, err := svc.Send(context.Background(), &OutgoingMessage{CompanyID: 1, Channel: model.MessageChannelRobot, RobotID: robot.ID, Title: "测试"})
+		if err == nil || logs[0].Errcode != dingtalkmock.ErrcodeRobotSignMismatch {
+			t.Errorf("Expected sign mismatch, got %+v %v", logs, err)
+		}
+
+		db.Model(token).Update("agent_id", 0)
+		logs, err = svc.Send(context.Background(), &OutgoingMessage{CompanyID: 1, Channel: model.MessageChannelWorkNotice, Receivers: []string{"dev01"}, Title: "测试"})
+		if err == nil || logs[0].Status != model.MessageStatusFailed {
+			t.Errorf("Expected missing agent id to fail, got %+v %v", logs, err)
+		}
+
+		if err := svc.DeleteRobot(robot.ID); err == nil {
+			t.Error("Expected robot used by template not to be deleted")
+		}
+	})
+
+	t.Run("TaskFinished", func(t *testing.T) {
+		received := make(chan *Notification, 1)
+		previous := SetNotifier(notifierFunc(func(ctx context.Context, notification *Notification) error {
+			received <- notification
+			return nil
+		}))
+		defer SetNotifier(previous)
+
+		now := time.Now()
+		notifyTaskFinished(&model.DownloadTask{ID: 9, CompanyID: 1, UserID: 1, TaskName: "导出", Status: model.DownloadTaskStatusFailed, ErrorMsg: "超时", FinishedAt: &now})
+		select {
+		case notification := <-received:
+			if notification.Event != model.MessageEventDownloadFailed || notification.UserIDs[0] != 1 || notification.Data["ErrorMsg"] != "超时" {
+				t.Errorf("Unexpected notification: %+v", notification)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Notification was not sent")
+		}
+	})
+}
+
+func TestRobotSign(t *testing.T) {
+	if robotSign("secret", 1700000000000) != dingtalkmock.RobotSign("secret", 1700000000000) {
+		t.Error("Unexpected robot sign")
+	}
+}
+
+// robotWebhook 返回模拟服务的机器人 Webhook 地址
+func robotWebhook(baseURL, accessToken string) string {
+	return baseURL + dingtalkmock.EndpointRobotSend + "?access_token=" + accessToken
+}
@@ -0,0 +1,509 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// orgRootDeptID 钉钉根部门ID
+const orgRootDeptID = 1
+
+// orgUserPageSize 分页获取部门成员时每页的数量，钉钉最大为 100
+const orgUserPageSize = 100
+
+// orgSyncLockTTL 同步组织架构的分布式锁有效期
+const orgSyncLockTTL = 10 * time.Minute
+
+// errOrgSyncRunning 同一公司已有同步在进行
+var errOrgSyncRunning = errors.New("该公司的组织架构正在同步，请稍后重试")
+
+// OrgDiffSet 一类数据的变更明细，每项为 ID 加名称，如 3 后端组
+type OrgDiffSet struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
+}
+
+// OrgSyncDiff 一次同步的变更明细
+type OrgSyncDiff struct {
+	Departments OrgDiffSet `json:"departments"`
+	Users       OrgDiffSet `json:"users"`
+}
+
+// orgDepartment 钉钉返回的部门
+type orgDepartment struct {
+	DeptID   int64  `json:"dept_id"`
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+}
+
+// orgUser 钉钉返回的成员
+type orgUser struct {
+	UserID     string  `json:"userid"`
+	UnionID    string  `json:"unionid"`
+	Name       string  `json:"name"`
+	Mobile     string  `json:"mobile"`
+	Email      string  `json:"email"`
+	JobNumber  string  `json:"job_number"`
+	Title      string  `json:"title"`
+	DeptIDList []int64 `json:"dept_id_list"`
+	Active     bool    `json:"active"`
+}
+
+// OrgSyncService 钉钉组织架构同步服务
+type OrgSyncService struct {
+	tokens *TokenProvider
+}
+
+// NewOrgSyncService 创建组织架构同步服务实例
+func NewOrgSyncService() *OrgSyncService {
+	return &OrgSyncService{tokens: NewTokenProvider()}
+}
+
+// Sync 同步公司的部门和成员，返回本次同步记录
+// 同一公司同一时间只允许一个同步，拉取全部数据成功后才会写入，避免拉取失败时误删本地数据
+func (s *OrgSyncService) Sync(ctx context.Context, companyID uint, trigger string) (*model.OrgSyncRun, error) {
+	db := database.GetDB()
+
+	lockKey := fmt.Sprintf("org_sync:lock:%d", companyID)
+	locked, err := database.TryLock(ctx, lockKey, orgSyncLockTTL)
+	if err != nil {
+		logrus.Errorf("获取组织架构同步锁失败: %v", err)
+		locked = true
+	}
+	if !locked {
+		return nil, errOrgSyncRunning
+	}
+	defer database.Unlock(context.Background(), lockKey)
+
+	started := time.Now()
+	run := &model.OrgSyncRun{CompanyID: companyID, Trigger: trigger, Status: model.OrgSyncStatusRunning}
+	if err := db.Create(run).Error; err != nil {
+		logrus.Errorf("创建组织架构同步记录失败: %v", err)
+		return nil, err
+	}
+
+	var diff *OrgSyncDiff
+	departments, users, err := s.fetch(ctx, companyID)
+	if err == nil {
+		diff, err = s.apply(companyID, run.ID, departments, users)
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Duration = finishedAt.Sub(started).Milliseconds()
+	if err != nil {
+		run.Status = model.OrgSyncStatusFailed
+		run.ErrorMsg = err.Error()
+		logrus.Errorf("同步组织架构失败，公司ID: %d, 错误: %v", companyID, err)
+	} else {
+		run.Status = model.OrgSyncStatusSuccess
+		run.DeptAdded = len(diff.Departments.Added)
+		run.DeptUpdated = len(diff.Departments.Updated)
+		run.DeptDeleted = len(diff.Departments.Deleted)
+		run.UserAdded = len(diff.Users.Added)
+		run.UserUpdated = len(diff.Users.Updated)
+		run.UserDeleted = len(diff.Users.Deleted)
+		data, _ := json.Marshal(diff)
+		run.Diff = string(data)
+		logrus.Infof("同步组织架构成功，公司ID: %d, 部门 +%d ~%d -%d, 成员 +%d ~%d -%d", companyID,
+			run.DeptAdded, run.DeptUpdated, run.DeptDeleted, run.UserAdded, run.UserUpdated, run.UserDeleted)
+	}
+
+	if saveErr := db.Save(run).Error; saveErr != nil {
+		logrus.Errorf("保存组织架构同步记录失败: %v", saveErr)
+		// 变更明细保存失败时仍需写入最终状态，避免记录一直处于 running
+		if saveErr := db.Model(&model.OrgSyncRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+			"status":       run.Status,
+			"dept_added":   run.DeptAdded,
+			"dept_updated": run.DeptUpdated,
+			"dept_deleted": run.DeptDeleted,
+			"user_added":   run.UserAdded,
+			"user_updated": run.UserUpdated,
+			"user_deleted": run.UserDeleted,
+			"error_msg":    run.ErrorMsg,
+			"duration":     run.Duration,
+			"finished_at":  run.FinishedAt,
+		}).Error; saveErr != nil {
+			logrus.Errorf("保存组织架构同步状态失败: %v", saveErr)
+		}
+	}
+	return run, err
+}
+
+// fetch 从根部门开始逐层获取全部部门，再分页获取每个部门的成员
+func (s *OrgSyncService) fetch(ctx context.Context, companyID uint) ([]orgDepartment, []orgUser, error) {
+	var root struct {
+		Result orgDepartment `json:"result"`
+	}
+	if err := callOAPI(ctx, s.tokens, companyID, "获取部门详情", "/topapi/v2/department/get",
+		map[string]interface{}{"dept_id": orgRootDeptID}, &root); err != nil {
+		return nil, nil, err
+	}
+	root.Result.DeptID = orgRootDeptID
+	root.Result.ParentID = 0
+
+	departments := []orgDepartment{root.Result}
+	for i := 0; i < len(departments); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		var children struct {
+			Result []orgDepartment `json:"result"`
+		}
+		if err := callOAPI(ctx, s.tokens, companyID, "获取子部门列表", "/topapi/v2/department/listsub",
+			map[string]interface{}{"dept_id": departments[i].DeptID}, &children); err != nil {
+			return nil, nil, err
+		}
+		departments = append(departments, children.Result...)
+	}
+
+	// 成员可能属于多个部门，按 userid 去重
+	users := make(map[string]orgUser)
+	for _, department := range departments {
+		cursor := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, err
+			}
+
+			var page struct {
+				Result struct {
+					HasMore    bool      `json:"has_more"`
+					NextCursor int       `json:"next_cursor"`
+					List       []orgUser `json:"list"`
+				} `json:"result"`
+			}
+			payload := map[string]interface{}{"dept_id": department.DeptID, "cursor": cursor, "size": orgUserPageSize}
+			if err := callOAPI(ctx, s.tokens, companyID, "获取部门成员", "/topapi/v2/user/list", payload, &page); err != nil {
+				return nil, nil, err
+			}
+			for _, user := range page.Result.List {
+				users[user.UserID] = user
+			}
+			if !page.Result.HasMore {
+				break
+			}
+			cursor = page.Result.NextCursor
+		}
+	}
+
+	userList := make([]orgUser, 0, len(users))
+	for _, user := range users {
+		userList = append(userList, user)
+	}
+	sort.Slice(userList, func(i, j int) bool { return userList[i].UserID < userList[j].UserID })
+	return departments, userList, nil
+}
+
+// apply 对比本地数据写入新增、变更和删除，返回变更明细
+func (s *OrgSyncService) apply(companyID, runID uint, departments []orgDepartment, users []orgUser) (*OrgSyncDiff, error) {
+	diff := &OrgSyncDiff{
+		Departments: OrgDiffSet{Added: []string{}, Updated: []string{}, Deleted: []string{}},
+		Users:       OrgDiffSet{Added: []string{}, Updated: []string{}, Deleted: []string{}},
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// 部门
+		var existingDepts []model.Department
+		if err := tx.Where("company_id = ?", companyID).Find(&existingDepts).Error; err != nil {
+			return err
+		}
+		deptByID := make(map[int64]model.Department, len(existingDepts))
+		for _, dept := range existingDepts {
+			deptByID[dept.DeptID] = dept
+		}
+
+		for _, fetched := range departments {
+			label := fmt.Sprintf("%d %s", fetched.DeptID, fetched.Name)
+			existing, ok := deptByID[fetched.DeptID]
+			delete(deptByID, fetched.DeptID)
+			if !ok {
+				if err := tx.Create(&model.Department{CompanyID: companyID, DeptID: fetched.DeptID, ParentID: fetched.ParentID, Name: fetched.Name, SyncRunID: runID}).Error; err != nil {
+					return err
+				}
+				diff.Departments.Added = append(diff.Departments.Added, label)
+				continue
+			}
+			if existing.Name == fetched.Name && existing.ParentID == fetched.ParentID {
+				continue
+			}
+			if err := tx.Model(&existing).Updates(map[string]interface{}{"name": fetched.Name, "parent_id": fetched.ParentID, "sync_run_id": runID}).Error; err != nil {
+				return err
+			}
+			diff.Departments.Updated = append(diff.Departments.Updated, label)
+		}
+		for _, stale := range deptByID {
+			if err := tx.Delete(&stale).Error; err != nil {
+				return err
+			}
+			diff.Departments.Deleted = append(diff.Departments.Deleted, fmt.Sprintf("%d %s", stale.DeptID, stale.Name))
+		}
+
+		// 成员
+		var existingUsers []model.DingTalkUser
+		if err := tx.Where("company_id = ?", companyID).Find(&existingUsers).Error; err != nil {
+			return err
+		}
+		userByID := make(map[string]model.DingTalkUser, len(existingUsers))
+		for _, user := range existingUsers {
+			userByID[user.UserID] = user
+		}
+
+		for _, fetched := range users {
+			label := fetched.UserID + " " + fetched.Name
+			record := model.DingTalkUser{
+				CompanyID: companyID,
+				UserID:    fetched.UserID,
+				UnionID:   fetched.UnionID,
+				Name:      fetched.Name,
+				Mobile:    fetched.Mobile,
+				Email:     fetched.Email,
+				JobNumber: fetched.JobNumber,
+				Title:     fetched.Title,
+				DeptIDs:   formatOrgDeptIDs(fetched.DeptIDList),
+				Active:    fetched.Active,
+				SyncRunID: runID,
+			}
+
+			existing, ok := userByID[fetched.UserID]
+			delete(userByID, fetched.UserID)
+			if !ok {
+				if err := tx.Create(&record).Error; err != nil {
+					return err
+				}
+				diff.Users.Added = append(diff.Users.Added, label)
+				continue
+			}
+			if sameOrgUser(&existing, &record) {
+				continue
+			}
+			record.ID = existing.ID
+			record.CreatedAt = existing.CreatedAt
+			if err := tx.Save(&record).Error; err != nil {
+				return err
+			}
+			diff.Users.Updated = append(diff.Users.Updated, label)
+		}
+		for _, stale := range userByID {
+			if err := tx.Delete(&stale).Error; err != nil {
+				return err
+			}
+			diff.Users.Deleted = append(diff.Users.Deleted, stale.UserID+" "+stale.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存组织架构失败: %v", err)
+	}
+
+	sort.Strings(diff.Departments.Deleted)
+	sort.Strings(diff.Users.Deleted)
+	return diff, nil
+}
+
+// sameOrgUser 成员信息是否未变化
+func sameOrgUser(a, b *model.DingTalkUser) bool {
+	return a.UnionID == b.UnionID && a.Name == b.Name && a.Mobile == b.Mobile && a.Email == b.Email &&
+		a.JobNumber == b.JobNumber && a.Title == b.Title && a.DeptIDs == b.DeptIDs && a.Active == b.Active
+}
+
+// formatOrgDeptIDs 将部门ID列表格式化为 ,1,3, 的形式
+func formatOrgDeptIDs(deptIDs []int64) string {
+	sorted := append([]int64(nil), deptIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var b strings.Builder
+	b.WriteString(",")
+	for _, deptID := range sorted {
+		b.WriteString(strconv.FormatInt(deptID, 10))
+		b.WriteString(",")
+	}
+	return b.String()
+}
+
+// ListRuns 分页获取组织架构同步记录，companyID 为 0 时返回所有公司
+func (s *OrgSyncService) ListRuns(companyID uint, page, pageSize int) ([]model.OrgSyncRun, int64, error) {
+	db := database.GetDB()
+
+	var runs []model.OrgSyncRun
+	var total int64
+
+	query := db.Model(&model.OrgSyncRun{})
+	if companyID > 0 {
+		query = query.Where("company_id = ?", companyID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取组织架构同步记录总数失败: %v", err)
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&runs).Error; err != nil {
+		logrus.Errorf("获取组织架构同步记录失败: %v", err)
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// ListDepartments 获取公司的全部部门
+func (s *OrgSyncService) ListDepartments(companyID uint) ([]model.Department, error) {
+	var departments []model.Department
+	if err := database.GetDB().Where("company_id = ?", companyID).Order("dept_id").Find(&departments).Error; err != nil {
+		logrus.Errorf("获取部门列表失败: %v", err)
+		return nil, err
+	}
+	return departments, nil
+}
+
+// DepartmentDescendants 返回部门及其所有下级部门的ID
+func (s *OrgSyncService) DepartmentDescendants(companyID uint, deptID int64) ([]int64, error) {
+	departments, err := s.ListDepartments(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]int64)
+	for _, dept := range departments {
+		children[dept.ParentID] = append(children[dept.ParentID], dept.DeptID)
+	}
+
+	result := []int64{deptID}
+	for i := 0; i < len(result); i++ {
+		result = append(result, children[result[i]]...)
+	}
+	return result, nil
+}
+
+// ListUsers 分页获取公司的钉钉成员，deptID 不为 0 时只返回该部门及下级部门的成员
+func (s *OrgSyncService) ListUsers(companyID uint, deptID int64, keyword string, page, pageSize int) ([]model.DingTalkUser, int64, error) {
+	db := database.GetDB()
+
+	var users []model.DingTalkUser
+	var total int64
+
+	query := db.Model(&model.DingTalkUser{}).Where("company_id = ?", companyID)
+	if deptID != 0 {
+		deptIDs, err := s.DepartmentDescendants(companyID, deptID)
+		if err != nil {
+			return nil, 0, err
+		}
+		conditions := db.Where("dept_ids LIKE ?", "%,"+strconv.FormatInt(deptIDs[0], 10)+",%")
+		for _, id := range deptIDs[1:] {
+			conditions = conditions.Or("dept_ids LIKE ?", "%,"+strconv.FormatInt(id, 10)+",%")
+		}
+		query = query.Where(conditions)
+	}
+	if keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("name LIKE ? OR dingtalk_userid LIKE ? OR mobile LIKE ?", like, like, like)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取钉钉成员总数失败: %v", err)
+		return nil, 0, err
+	}
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&users).Error; err != nil {
+		logrus.Errorf("获取钉钉成员列表失败: %v", err)
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// OrgSyncer 定时同步所有公司的组织架构
+type OrgSyncer struct {
+	interval time.Duration
+	service  *OrgSyncService
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewOrgSyncer 创建组织架构定时同步任务
+func NewOrgSyncer() *OrgSyncer {
+	var interval time.Duration
+	if config.GlobalConfig != nil {
+		interval = config.GlobalConfig.DingTalk.OrgSyncInterval
+	}
+	return &OrgSyncer{interval: interval, service: NewOrgSyncService()}
+}
+
+// Start 启动定时同步，未配置同步间隔时不启动
+func (s *OrgSyncer) Start() {
+	if s.interval <= 0 {
+		logrus.Info("未配置组织架构同步间隔，不启动定时同步")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.SyncAll(ctx)
+			}
+		}
+	}()
+
+	logrus.Infof("组织架构定时同步已启动，间隔: %s", s.interval)
+}
+
+// Stop 停止定时同步，等待正在进行的同步结束
+func (s *OrgSyncer) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// SyncAll 同步所有配置了旧版 AccessToken 的公司
+// 多个实例都会触发，最近一个间隔内已有其他实例同步过的公司会跳过
+func (s *OrgSyncer) SyncAll(ctx context.Context) {
+	db := database.GetDB()
+
+	var companyIDs []uint
+	if err := db.Model(&model.AccessToken{}).
+		Where("token_type = ? AND status = 1", model.AccessTokenTypeLegacy).
+		Distinct().Pluck("company_id", &companyIDs).Error; err != nil {
+		logrus.Errorf("查询需要同步组织架构的公司失败: %v", err)
+		return
+	}
+
+	for _, companyID := range companyIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var recent int64
+		db.Model(&model.OrgSyncRun{}).
+			Where("company_id = ? AND status <> ? AND created_at > ?", companyID, model.OrgSyncStatusFailed, time.Now().Add(-s.interval/2)).
+			Count(&recent)
+		if recent > 0 {
+			continue
+		}
+
+		s.service.Sync(ctx, companyID, model.OrgSyncTriggerScheduled)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestOrgSyncWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.OrgSyncRun{}, &model.Department{}, &model.DingTalkUser{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	if _, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret"}); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+	svc := NewOrgSyncService()
+
+	t.Run("Initial", func(t *testing.T) {
+		run, err := svc.Sync(context.Background(), 1, model.OrgSyncTriggerManual)
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if run.Status != model.OrgSyncStatusSuccess || run.DeptAdded != 4 || run.UserAdded != 3 {
+			t.Errorf("Unexpected run: %+v", run)
+		}
+
+		var dev model.DingTalkUser
+		db.Where("company_id = ? AND dingtalk_userid = ?", 1, "dev01").First(&dev)
+		if dev.DeptIDs != ",3," || dev.UnionID != "union-dev01" {
+			t.Errorf("Unexpected synced user: %+v", dev)
+		}
+
+		// 研发部包含下级的后端组
+		users, total, err := svc.ListUsers(1, 2, "", 1, 10)
+		if err != nil || total != 1 || users[0].UserID != "dev01" {
+			t.Errorf("Expected dev01 under dept 2, got %v, %d, %v", users, total, err)
+		}
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		run, err := svc.Sync(context.Background(), 1, model.OrgSyncTriggerManual)
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if run.DeptAdded+run.DeptUpdated+run.DeptDeleted+run.UserAdded+run.UserUpdated+run.UserDeleted != 0 {
+			t.Errorf("Expected no changes, got %+v", run)
+		}
+	})
+
+	t.Run("FailedFetchKeepsData", func(t *testing.T) {
+		mock.InjectFault(dingtalkmock.EndpointDepartmentListSub, dingtalkmock.Fault{Errcode: dingtalkmock.ErrcodeServiceUnavailable, Errmsg: "系统繁忙", Times: 1})
+		run, err := svc.Sync(context.Background(), 1, model.OrgSyncTriggerManual)
+		if err == nil || run.Status != model.OrgSyncStatusFailed {
+			t.Fatalf("Expected failed run, got %+v, %v", run, err)
+		}
+		var count int64
+		db.Model(&model.Department{}).Where("company_id = ?", 1).Count(&count)
+		if count != 4 {
+			t.Errorf("Expected departments kept after failed sync, got %d", count)
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		fixtures := dingtalkmock.DefaultFixtures()
+		fixtures.Departments = fixtures.Departments[:3]
+		fixtures.Departments[2].Name = "服务端组"
+		fixtures.Users = fixtures.Users[:2]
+		fixtures.Users[1].Title = "高级工程师"
+		mock.SetFixtures(fixtures)
+
+		run, err := svc.Sync(context.Background(), 1, model.OrgSyncTriggerManual)
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if run.DeptUpdated != 1 || run.DeptDeleted != 1 || run.UserUpdated != 1 || run.UserDeleted != 1 {
+			t.Errorf("Unexpected diff counts: %+v", run)
+		}
+		if run.Diff == "" {
+			t.Error("Expected diff detail")
+		}
+
+		var finance int64
+		db.Model(&model.DingTalkUser{}).Where("dingtalk_userid = ?", "finance01").Count(&finance)
+		if finance != 0 {
+			t.Error("Expected finance01 to be deleted")
+		}
+	})
+
+	_, total, _ := svc.ListRuns(1, 1, 10)
+	if total != 4 {
+		t.Errorf("Expected 4 sync runs, got %d", total)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paramPlaceholder 参数模板中的占位符，如 ${yesterday_start_ms}、${day_start_ms:-7}
+var paramPlaceholder = regexp.MustCompile(`\$\{([a-z_]+)(?::(-?\d+))?\}`)
+
+// placeholderAliases 占位符别名及对应的天数偏移
+var placeholderAliases = map[string]struct {
+	name   string
+	offset int
+}{
+	"today":              {"date", 0},
+	"yesterday":          {"date", -1},
+	"today_start_ms":     {"day_start_ms", 0},
+	"yesterday_start_ms": {"day_start_ms", -1},
+}
+
+// renderParamTemplate 计算参数模板中的相对日期占位符，返回 JSON 格式的请求参数
+// 支持的占位符（N 为相对今天/本月的偏移量，可省略）：
+//
+//	${now_ms}               当前时间毫秒时间戳
+//	${now}                  当前时间，格式 2006-01-02 15:04:05
+//	${day_start_ms:N}       N 天后 00:00 的毫秒时间戳，today_start_ms / yesterday_start_ms 为 0 / -1 的别名
+//	${day_start:N}          N 天后 00:00，格式 2006-01-02 15:04:05
+//	${date:N}               N 天后的日期，格式 2006-01-02，today / yesterday 为 0 / -1 的别名
+//	${month_start_ms:N}     N 个月后 1 日 00:00 的毫秒时间戳
+//	${month_start:N}        N 个月后 1 日 00:00，格式 2006-01-02 15:04:05
+//
+// 字符串只包含一个毫秒时间戳占位符时替换为数字
+func renderParamTemplate(template string, now time.Time) (string, error) {
+	if strings.TrimSpace(template) == "" {
+		return template, nil
+	}
+
+	var params interface{}
+	if err := json.Unmarshal([]byte(template), &params); err != nil {
+		return "", fmt.Errorf("参数模板格式错误: %v", err)
+	}
+
+	rendered, err := renderTemplateValue(params, now)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// renderTemplateValue 递归替换对象、数组中的字符串占位符
+func renderTemplateValue(value interface{}, now time.Time) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			rendered, err := renderTemplateValue(item, now)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			rendered, err := renderTemplateValue(item, now)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+	case string:
+		return renderTemplateString(v, now)
+	default:
+		return v, nil
+	}
+}
+
+// renderTemplateString 替换字符串中的占位符
+func renderTemplateString(s string, now time.Time) (interface{}, error) {
+	matches := paramPlaceholder.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// 整个字符串就是一个占位符时保留原始类型
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return evalPlaceholder(s, now)
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range matches {
+		value, err := evalPlaceholder(s[match[0]:match[1]], now)
+		if err != nil {
+			return nil, err
+		}
+		builder.WriteString(s[last:match[0]])
+		builder.WriteString(fmt.Sprint(value))
+		last = match[1]
+	}
+	builder.WriteString(s[last:])
+	return builder.String(), nil
+}
+
+// evalPlaceholder 计算单个占位符的值
+func evalPlaceholder(placeholder string, now time.Time) (interface{}, error) {
+	parts := paramPlaceholder.FindStringSubmatch(placeholder)
+	name := parts[1]
+	offset := 0
+	if parts[2] != "" {
+		offset, _ = strconv.Atoi(parts[2])
+	}
+
+	if alias, ok := placeholderAliases[name]; ok {
+		name = alias.name
+		offset += alias.offset
+	}
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month()+time.Month(offset), 1, 0, 0, 0, 0, now.Location())
+
+	switch name {
+	case "now_ms":
+		return now.UnixMilli(), nil
+	case "now":
+		return now.Format("2006-01-02 15:04:05"), nil
+	case "date":
+		return dayStart.Format("2006-01-02"), nil
+	case "day_start_ms":
+		return dayStart.UnixMilli(), nil
+	case "day_start":
+		return dayStart.Format("2006-01-02 15:04:05"), nil
+	case "month_start_ms":
+		return monthStart.UnixMilli(), nil
+	case "month_start":
+		return monthStart.Format("2006-01-02 15:04:05"), nil
+	default:
+		return nil, fmt.Errorf("不支持的参数占位符: %s", placeholder)
+	}
+}
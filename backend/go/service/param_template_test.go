@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderParamTemplate(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2024, 3, 1, 8, 30, 0, 0, loc)
+
+	t.Run("RelativeDates", func(t *testing.T) {
+		got, err := renderParamTemplate(`{"start_time":"${yesterday_start_ms}","end_time":"${today_start_ms}","week":"${day_start_ms:-7}","month":"${month_start_ms:-1}"}`, now)
+		if err != nil {
+			t.Fatalf("renderParamTemplate failed: %v", err)
+		}
+		want := `{"end_time":1709222400000,"month":1706716800000,"start_time":1709136000000,"week":1708617600000}`
+		if got != want {
+			t.Errorf("Unexpected params:\n got %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("Interpolate", func(t *testing.T) {
+		got, err := renderParamTemplate(`{"range":"${yesterday}~${today}","size":20,"list":["${date:1}"]}`, now)
+		if err != nil {
+			t.Fatalf("renderParamTemplate failed: %v", err)
+		}
+		want := `{"list":["2024-03-02"],"range":"2024-02-29~2024-03-01","size":20}`
+		if got != want {
+			t.Errorf("Unexpected params:\n got %s\nwant %s", got, want)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if _, err := renderParamTemplate(`{"a":"${last_week}"}`, now); err == nil {
+			t.Error("Expected unknown placeholder to fail")
+		}
+	})
+}
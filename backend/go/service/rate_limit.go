@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidRateLimit 限流配置不合法
+var ErrInvalidRateLimit = errors.New("限流配置错误：QPS 和突发请求数不能小于0")
+
+// throttleBackoffMax 限流重试单次等待时间的上限
+const throttleBackoffMax = 30 * time.Second
+
+// throttleErrcodes 钉钉旧版接口表示调用频率超过限制的错误码
+var throttleErrcodes = map[float64]bool{
+	90018: true, // 调用频率超过限制，请求被暂时禁用
+}
+
+// throttleCodePrefix 钉钉新版接口表示调用频率超过限制的错误码前缀，如 Forbidden.AccessDenied.QpsLimitForApi
+const throttleCodePrefix = "Forbidden.AccessDenied.QpsLimit"
+
+// tokenBucketScript 在 Redis 中原子地从令牌桶预留一个令牌，返回需要等待的毫秒数
+// 令牌不足时同样扣减（允许为负），调用方等待返回的时长后即可发送，多个副本共享同一个桶
+const tokenBucketScript = `
+if redis.replicate_commands then redis.replicate_commands() end
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000) - 1
+local wait = 0
+if tokens < 0 then
+	wait = math.ceil(-tokens * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return wait
+`
+
+var tokenBucket = redis.NewScript(tokenBucketScript)
+
+// RateLimit 调用钉钉接口的限流配置
+type RateLimit struct {
+	QPS   float64 // 每秒请求数上限，0 表示不限流
+	Burst int     // 允许的突发请求数
+}
+
+// validateRateLimit 校验限流配置
+func validateRateLimit(qps float64, burst int) error {
+	if qps < 0 || burst < 0 || math.IsNaN(qps) || math.IsInf(qps, 0) {
+		return ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// resolveRateLimit 返回API配置实际使用的限流配置，未单独配置时使用公司的默认限流
+func resolveRateLimit(apiConfig *model.APIConfig) RateLimit {
+	limit := RateLimit{QPS: apiConfig.QPS, Burst: apiConfig.Burst}
+	if limit.QPS <= 0 && apiConfig.CompanyID > 0 {
+		var company model.Company
+		err := database.GetDB().Select("id", "api_qps", "api_burst").First(&company, apiConfig.CompanyID).Error
+		if err == nil {
+			limit = RateLimit{QPS: company.APIQPS, Burst: company.APIBurst}
+		}
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.QPS))
+	}
+	return limit
+}
+
+// rateLimitKey 返回令牌桶在 Redis 中的键，按公司和接口地址区分，与钉钉按应用和接口计算调用频率一致
+func rateLimitKey(companyID uint, req *http.Request) string {
+	return fmt.Sprintf("rate_limit:%d:%s%s", companyID, req.URL.Host, req.URL.Path)
+}
+
+// waitRateLimit 按限流配置等待可以发送请求，ctx 结束时返回错误
+func waitRateLimit(ctx context.Context, companyID uint, limit RateLimit, req *http.Request) error {
+	if limit.QPS <= 0 {
+		return nil
+	}
+
+	key := rateLimitKey(companyID, req)
+	wait := reserveToken(ctx, key, limit)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("等待调用频率限制时中断: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserveToken 从令牌桶预留一个令牌，返回发送前需要等待的时间
+// 未连接 Redis 或 Redis 出错时使用进程内的令牌桶
+func reserveToken(ctx context.Context, key string, limit RateLimit) time.Duration {
+	if redisClient := database.GetRedis(); redisClient != nil {
+		wait, err := tokenBucket.Run(ctx, redisClient, []string{key}, limit.QPS, limit.Burst).Int64()
+		if err == nil {
+			return time.Duration(wait) * time.Millisecond
+		}
+		logrus.Errorf("读取限流令牌桶失败，使用本地限流: %v", err)
+	}
+	return localBuckets.reserve(key, limit, time.Now())
+}
+
+// localBucket 进程内的令牌桶
+type localBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// localBucketSet 按键保存进程内的令牌桶
+type localBucketSet struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+}
+
+var localBuckets = &localBucketSet{buckets: make(map[string]*localBucket)}
+
+// reserve 与 tokenBucketScript 相同的预留逻辑
+func (s *localBucketSet) reserve(key string, limit RateLimit, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	burst := float64(limit.Burst)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &localBucket{tokens: burst, last: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+elapsed*limit.QPS) - 1
+	bucket.last = now
+	if bucket.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-bucket.tokens / limit.QPS * float64(time.Second)))
+}
+
+// requestThrottled 响应是否表示调用频率超过钉钉限制
+// 旧版接口返回 errcode 90018，新版接口返回 HTTP 429 或 Forbidden.AccessDenied.QpsLimit* 错误码
+func requestThrottled(resp *http.Response, body []byte) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var data struct {
+		Errcode *float64 `json:"errcode"`
+		Code    string   `json:"code"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return false
+	}
+	if data.Errcode != nil && throttleErrcodes[*data.Errcode] {
+		return true
+	}
+	return strings.HasPrefix(data.Code, throttleCodePrefix)
+}
+
+// throttleRetries 返回限流错误的最大重试次数
+func throttleRetries() int {
+	if config.GlobalConfig == nil || config.GlobalConfig.DingTalk.ThrottleRetries <= 0 {
+		return 3
+	}
+	return config.GlobalConfig.DingTalk.ThrottleRetries
+}
+
+// throttleBackoff 返回第 attempt 次限流重试前的等待时间，按指数增长并加入随机抖动，避免多个副本同时重试
+func throttleBackoff(attempt int) time.Duration {
+	base := time.Second
+	if config.GlobalConfig != nil && config.GlobalConfig.DingTalk.ThrottleBackoff > 0 {
+		base = config.GlobalConfig.DingTalk.ThrottleBackoff
+	}
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > throttleBackoffMax {
+		delay = throttleBackoffMax
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sendLimited 按限流配置等待后发送请求，钉钉返回限流错误时退避后重试
+// limit 由调用方通过 resolveRateLimit 解析一次，重试时不再查询数据库
+// newRequest 用于重试时重新创建请求，需要已附加 AccessToken
+func sendLimited(ctx context.Context, client *http.Client, apiConfig *model.APIConfig, limit RateLimit, req *http.Request, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	retries := throttleRetries()
+	for attempt := 0; ; attempt++ {
+		if err := waitRateLimit(ctx, apiConfig.CompanyID, limit, req); err != nil {
+			return nil, nil, err
+		}
+		resp, body, err := sendRequest(client, req)
+		if err != nil || !requestThrottled(resp, body) || attempt >= retries {
+			return resp, body, err
+		}
+
+		delay := throttleBackoff(attempt)
+		logrus.Warnf("钉钉接口返回调用频率超限，%s 后第 %d 次重试，公司ID: %d, 接口: %s", delay, attempt+1, apiConfig.CompanyID, req.URL.Path)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, fmt.Errorf("等待限流重试时中断: %w", ctx.Err())
+		case <-timer.C:
+		}
+
+		if req, err = newRequest(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestLocalTokenBucket(t *testing.T) {
+	buckets := &localBucketSet{buckets: make(map[string]*localBucket)}
+	limit := RateLimit{QPS: 10, Burst: 2}
+	now := time.Now()
+
+	expected := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, want := range expected {
+		if wait := buckets.reserve("k", limit, now); wait != want {
+			t.Errorf("Reserve %d: expected wait %s, got %s", i, want, wait)
+		}
+	}
+
+	// 预留的令牌补充后恢复突发容量
+	if wait := buckets.reserve("k", limit, now.Add(time.Second)); wait != 0 {
+		t.Errorf("Expected bucket refilled, got wait %s", wait)
+	}
+	if wait := buckets.reserve("other", limit, now); wait != 0 {
+		t.Errorf("Expected separate bucket per key, got wait %s", wait)
+	}
+}
+
+func TestRequestThrottled(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{http.StatusOK, `{"errcode":90018,"errmsg":"请求频率过快"}`, true},
+		{http.StatusOK, `{"errcode":0}`, false},
+		{http.StatusForbidden, `{"code":"Forbidden.AccessDenied.QpsLimitForApi","message":"qps limit"}`, true},
+		{http.StatusTooManyRequests, ``, true},
+		{http.StatusBadRequest, `{"code":"InvalidParameter"}`, false},
+	}
+	for _, tt := range tests {
+		if got := requestThrottled(&http.Response{StatusCode: tt.status}, []byte(tt.body)); got != tt.want {
+			t.Errorf("requestThrottled(%d, %s) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.APIConfig{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+	backoff := config.GlobalConfig.DingTalk.ThrottleBackoff
+	config.GlobalConfig.DingTalk.ThrottleBackoff = time.Millisecond
+	defer func() { config.GlobalConfig.DingTalk.ThrottleBackoff = backoff }()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1", APIQPS: 20, APIBurst: 1})
+	if _, err := NewAccessTokenService().CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, AppKey: "mock-app-key", AppSecret: "mock-app-secret"}); err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+	tokens := NewTokenProvider()
+	payload := map[string]interface{}{"dept_id": 1}
+
+	t.Run("Resolve", func(t *testing.T) {
+		if limit := resolveRateLimit(&model.APIConfig{CompanyID: 1}); limit.QPS != 20 || limit.Burst != 1 {
+			t.Errorf("Expected company default, got %+v", limit)
+		}
+		if limit := resolveRateLimit(&model.APIConfig{CompanyID: 1, QPS: 2.5}); limit.QPS != 2.5 || limit.Burst != 3 {
+			t.Errorf("Expected api config limit, got %+v", limit)
+		}
+		if limit := resolveRateLimit(&model.APIConfig{CompanyID: 2}); limit.QPS != 0 {
+			t.Errorf("Expected no limit, got %+v", limit)
+		}
+	})
+
+	t.Run("CompanyDefault", func(t *testing.T) {
+		// 20 QPS、突发 1：连续 4 次调用至少等待 3 个 50ms
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			if err := callOAPI(context.Background(), tokens, 1, "获取子部门列表", dingtalkmock.EndpointDepartmentListSub, payload, nil); err != nil {
+				t.Fatalf("callOAPI failed: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+			t.Errorf("Expected calls to be paced, took %s", elapsed)
+		}
+	})
+
+	t.Run("ThrottleRetry", func(t *testing.T) {
+		before := mock.Calls(dingtalkmock.EndpointDepartmentGet)
+		mock.InjectFault(dingtalkmock.EndpointDepartmentGet, dingtalkmock.Fault{Errcode: dingtalkmock.ErrcodeRequestLimitReached, Errmsg: "请求频率过快", Times: 2})
+		if err := callOAPI(context.Background(), tokens, 1, "获取部门详情", dingtalkmock.EndpointDepartmentGet, payload, nil); err != nil {
+			t.Fatalf("Expected retry to succeed, got %v", err)
+		}
+		if calls := mock.Calls(dingtalkmock.EndpointDepartmentGet) - before; calls != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("ThrottleExhausted", func(t *testing.T) {
+		before := mock.Calls(dingtalkmock.EndpointDepartmentGet)
+		mock.InjectFault(dingtalkmock.EndpointDepartmentGet, dingtalkmock.Fault{Errcode: dingtalkmock.ErrcodeRequestLimitReached, Errmsg: "请求频率过快"})
+		defer mock.ClearFaults()
+
+		err := callOAPI(context.Background(), tokens, 1, "获取部门详情", dingtalkmock.EndpointDepartmentGet, payload, nil)
+		var dingErr *DingTalkError
+		if !errors.As(err, &dingErr) || dingErr.Errcode != dingtalkmock.ErrcodeRequestLimitReached {
+			t.Errorf("Expected throttle error, got %v", err)
+		}
+		if calls := mock.Calls(dingtalkmock.EndpointDepartmentGet) - before; calls != throttleRetries()+1 {
+			t.Errorf("Expected %d calls, got %d", throttleRetries()+1, calls)
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		if err := NewCompanyService().Create(&model.Company{Name: "c2", Code: "c2", APIQPS: -1}); !errors.Is(err, ErrInvalidRateLimit) {
+			t.Errorf("Expected ErrInvalidRateLimit, got %v", err)
+		}
+		if err := NewAPIConfigService().Create(&model.APIConfig{CompanyID: 1, Name: "a", Code: "a", Version: "v1", Burst: -1}); !errors.Is(err, ErrInvalidRateLimit) {
+			t.Errorf("Expected ErrInvalidRateLimit, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/secret"
+	"github.com/sirupsen/logrus"
+)
+
+// secretReencryptBatchSize 每批重新加密的行数
+const secretReencryptBatchSize = 100
+
+// secretColumns 加密保存的字段
+var secretColumns = []struct {
+	Model  interface{ TableName() string }
+	Column string
+}{
+	{model.AccessToken{}, "app_secret"},
+	{model.SSOConfig{}, "app_secret"},
+	{model.SSOConfig{}, "callback_token"},
+	{model.SSOConfig{}, "callback_aes_key"},
+	{model.MessageRobot{}, "webhook"},
+	{model.MessageRobot{}, "secret"},
+}
+
+// SecretReencryptResult 一个字段的重新加密结果
+type SecretReencryptResult struct {
+	Table       string `json:"table"`
+	Column      string `json:"column"`
+	Total       int    `json:"total"`       // 有值的行数
+	Reencrypted int    `json:"reencrypted"` // 已重新加密（试运行时为需要重新加密）的行数
+	Failed      int    `json:"failed"`      // 解密失败的行数
+}
+
+// SecretService 敏感字段加密服务
+type SecretService struct{}
+
+// NewSecretService 创建敏感字段加密服务实例
+func NewSecretService() *SecretService {
+	return &SecretService{}
+}
+
+// Reencrypt 使用当前版本的主密钥重新加密所有敏感字段
+// 明文和旧版本主密钥加密的值都会迁移，dryRun 为 true 时只统计不修改
+func (s *SecretService) Reencrypt(dryRun bool) ([]SecretReencryptResult, error) {
+	keyring := secret.Default()
+	if !keyring.Enabled() {
+		return nil, errors.New("未配置加密密钥")
+	}
+
+	results := make([]SecretReencryptResult, 0, len(secretColumns))
+	for _, item := range secretColumns {
+		result, err := s.reencryptColumn(keyring, item.Model.TableName(), item.Column, dryRun)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// reencryptColumn 按主键分批重新加密一个字段，更新时校验原值避免覆盖并发修改
+func (s *SecretService) reencryptColumn(keyring *secret.Keyring, table, column string, dryRun bool) (*SecretReencryptResult, error) {
+	db := database.GetDB()
+	result := &SecretReencryptResult{Table: table, Column: column}
+
+	var lastID uint
+	for {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		if err := db.Table(table).
+			Select("id, "+column+" AS value").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(secretReencryptBatchSize).
+			Find(&rows).Error; err != nil {
+			logrus.Errorf("查询%s.%s失败: %v", table, column, err)
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			if row.Value == "" {
+				continue
+			}
+			result.Total++
+			if !keyring.NeedsReencrypt(row.Value) {
+				continue
+			}
+
+			plaintext, err := keyring.Decrypt(row.Value)
+			if err != nil {
+				result.Failed++
+				logrus.Errorf("解密%s.%s失败，ID: %d, 错误: %v", table, column, row.ID, err)
+				continue
+			}
+			if dryRun {
+				result.Reencrypted++
+				continue
+			}
+
+			ciphertext, err := keyring.Encrypt(plaintext)
+			if err != nil {
+				return nil, err
+			}
+			if err := db.Table(table).
+				Where("id = ? AND "+column+" = ?", row.ID, row.Value).
+				UpdateColumn(column, ciphertext).Error; err != nil {
+				logrus.Errorf("更新%s.%s失败，ID: %d, 错误: %v", table, column, row.ID, err)
+				return nil, err
+			}
+			result.Reencrypted++
+		}
+	}
+}
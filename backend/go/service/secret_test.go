@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/secret"
+)
+
+func TestSecretEncryption(t *testing.T) {
+	setupTestModels(&model.AccessToken{}, &model.SSOConfig{}, &model.MessageRobot{})
+	db := database.GetDB()
+
+	key1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	if _, err := secret.Init("v1:"+key1, ""); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer secret.SetDefault(nil)
+
+	rawSecret := func(table string, id uint) string {
+		var value string
+		db.Table(table).Select("app_secret").Where("id = ?", id).Scan(&value)
+		return value
+	}
+
+	token := &model.AccessToken{CompanyID: 1, AppKey: "key", AppSecret: "plain-secret", Status: 1}
+	db.Create(token)
+	// 启用加密前保存的明文
+	db.Exec("INSERT INTO sso_config (company_id, app_id, app_key, app_secret, status) VALUES (1, 'app', 'key', 'legacy-secret', 1)")
+
+	t.Run("EncryptAtRest", func(t *testing.T) {
+		if raw := rawSecret("access_token", token.ID); !strings.HasPrefix(raw, "enc:v1:") || strings.Contains(raw, "plain-secret") {
+			t.Errorf("Expected encrypted column, got %s", raw)
+		}
+
+		var loaded model.AccessToken
+		db.First(&loaded, token.ID)
+		if loaded.AppSecret != "plain-secret" {
+			t.Errorf("Expected decrypted secret, got %s", loaded.AppSecret)
+		}
+
+		// 明文保存的旧数据仍可读取
+		config, err := NewSSOService().GetConfig(1)
+		if err != nil || config.AppSecret != "legacy-secret" {
+			t.Errorf("Expected legacy plaintext secret, got %v %v", config, err)
+		}
+	})
+
+	t.Run("MaskedUpdateKeepsSecret", func(t *testing.T) {
+		if err := NewSSOService().UpdateConfig(&model.SSOConfig{CompanyID: 1, AppID: "app", AppKey: "key2", AppSecret: secret.Masked, Status: 1}); err != nil {
+			t.Fatalf("UpdateConfig failed: %v", err)
+		}
+		config, _ := NewSSOService().GetConfig(1)
+		if config.AppKey != "key2" || config.AppSecret != "legacy-secret" {
+			t.Errorf("Expected secret unchanged, got %s %s", config.AppKey, config.AppSecret)
+		}
+	})
+
+	t.Run("Reencrypt", func(t *testing.T) {
+		keyring, _ := secret.Init("v1:"+key1+",v2:"+key2, "v2")
+
+		results, err := NewSecretService().Reencrypt(true)
+		if err != nil || len(results) != len(secretColumns) || results[0].Reencrypted != 1 || results[1].Reencrypted != 1 {
+			t.Fatalf("Unexpected dry run result: %+v %v", results, err)
+		}
+		if secret.Version(rawSecret("access_token", token.ID)) != "v1" {
+			t.Error("Expected dry run not to modify rows")
+		}
+
+		if _, err := NewSecretService().Reencrypt(false); err != nil {
+			t.Fatalf("Reencrypt failed: %v", err)
+		}
+		for _, table := range []string{"access_token", "sso_config"} {
+			raw := rawSecret(table, 1)
+			if secret.Version(raw) != "v2" || keyring.NeedsReencrypt(raw) {
+				t.Errorf("Expected %s reencrypted with v2, got %s", table, raw)
+			}
+		}
+
+		// 重新加密后移除旧密钥仍能读取
+		secret.Init("v2:"+key2, "")
+		var loaded model.AccessToken
+		if err := db.First(&loaded, token.ID).Error; err != nil || loaded.AppSecret != "plain-secret" {
+			t.Errorf("Expected secret readable with v2 only, got %s %v", loaded.AppSecret, err)
+		}
+	})
+}
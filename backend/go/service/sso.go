@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/ddoalistdownload/backend/database"
 	"github.com/ddoalistdownload/backend/model"
+	"github.com/ddoalistdownload/backend/secret"
 	"github.com/sirupsen/logrus"
 )
 
@@ -36,11 +39,31 @@ func (s *SSOService) GetConfig(companyID uint) (*model.SSOConfig, error) {
 func (s *SSOService) UpdateConfig(config *model.SSOConfig) error {
 	db := database.GetDB()
 	
+	// 校验免登登录策略
+	if err := validateSSOPolicy(config); err != nil {
+		return err
+	}
+	// 校验事件订阅的 aes_key
+	if !config.CallbackAESKey.IsMasked() {
+		if _, err := decodeCallbackAESKey(string(config.CallbackAESKey)); err != nil {
+			return err
+		}
+	}
+	
 	// 检查是否存在
 	var existing model.SSOConfig
 	result := db.Where("company_id = ?", config.CompanyID).First(&existing)
 	if result.Error != nil {
 		// 不存在则创建
+		if config.AppSecret.IsMasked() {
+			return errors.New("AppSecret不能为空")
+		}
+		if config.CallbackToken == secret.Masked {
+			config.CallbackToken = ""
+		}
+		if config.CallbackAESKey == secret.Masked {
+			config.CallbackAESKey = ""
+		}
 		if err := db.Create(config).Error; err != nil {
 			logrus.Errorf("创建身份验证（免登）配置失败: %v", err)
 			return err
@@ -48,8 +71,17 @@ func (s *SSOService) UpdateConfig(config *model.SSOConfig) error {
 		return nil
 	}
 	
-	// 存在则更新
+	// 存在则更新，提交脱敏后的值表示不修改 AppSecret
 	config.ID = existing.ID
+	if config.AppSecret.IsMasked() {
+		config.AppSecret = existing.AppSecret
+	}
+	if config.CallbackToken.IsMasked() {
+		config.CallbackToken = existing.CallbackToken
+	}
+	if config.CallbackAESKey.IsMasked() {
+		config.CallbackAESKey = existing.CallbackAESKey
+	}
 	if err := db.Save(config).Error; err != nil {
 		logrus.Errorf("更新身份验证（免登）配置失败: %v", err)
 		return err
@@ -58,8 +90,17 @@ func (s *SSOService) UpdateConfig(config *model.SSOConfig) error {
 	return nil
 }
 
-// TestSSO 测试身份验证（免登）
+// TestSSO 测试身份验证（免登），每次测试都会保存免登记录
 func (s *SSOService) TestSSO(companyID uint, code string) (map[string]interface{}, error) {
+	started := time.Now()
+	attempt := newSSOLoginLog(companyID, model.SSOActionTest, code, "")
+	result, err := s.testSSO(companyID, code, attempt)
+	s.recordAttempt(attempt, started, err)
+	return result, err
+}
+
+// testSSO 执行免登测试，解析出的钉钉账号和已绑定的系统用户写入 attempt
+func (s *SSOService) testSSO(companyID uint, code string, attempt *model.SSOLoginLog) (map[string]interface{}, error) {
 	db := database.GetDB()
 	
 	// 获取配置
@@ -69,15 +110,73 @@ func (s *SSOService) TestSSO(companyID uint, code string) (map[string]interface{
 		return nil, err
 	}
 	
+	userInfoResp, _, err := s.exchangeCode(&config, code)
+	if err != nil {
+		return nil, err
+	}
+	attempt.DingTalkUserID = userInfoResp.Userid
+	
+	// 查找已绑定的系统用户，便于确认绑定关系是否正确
+	if validateSSOPolicy(&config) == nil {
+		if binding, err := s.findBinding(&config, userInfoResp); err == nil && binding != nil {
+			attempt.UserID = binding.UserID
+		}
+	}
+	
+	// 返回结果
+	result := map[string]interface{}{
+		"success": true,
+		"message": "免登测试成功",
+		"data": map[string]interface{}{
+			"company_id": companyID,
+			"app_key":    config.AppKey,
+			"code":       code,
+			"user_id":    attempt.UserID,
+			"user_info": map[string]interface{}{
+				"userid":     userInfoResp.Userid,
+				"name":       userInfoResp.Name,
+				"department": userInfoResp.Department,
+				"unionid":    userInfoResp.Unionid,
+				"openid":     userInfoResp.Openid,
+				"avatar":     userInfoResp.Avatar,
+				"mobile":     userInfoResp.Mobile,
+				"email":      userInfoResp.Email,
+				"jobnumber":  userInfoResp.Jobnumber,
+				"position":   userInfoResp.Position,
+			},
+		},
+	}
+	
+	logrus.Infof("免登测试成功，公司ID: %d, AppKey: %s, Code: %s, UserID: %s", companyID, config.AppKey, code, userInfoResp.Userid)
+	
+	return result, nil
+}
+
+// dingTalkSSOUser 钉钉免登返回的用户信息
+type dingTalkSSOUser struct {
+	Userid     string `json:"userid"`
+	Name       string `json:"name"`
+	Department []int  `json:"department"`
+	Unionid    string `json:"unionid"`
+	Openid     string `json:"openid"`
+	Avatar     string `json:"avatar"`
+	Mobile     string `json:"mobile"`
+	Email      string `json:"email"`
+	Jobnumber  string `json:"jobnumber"`
+	Position   string `json:"position"`
+}
+
+// exchangeCode 使用免登授权码换取钉钉用户信息，同时返回本次获取的access_token
+func (s *SSOService) exchangeCode(config *model.SSOConfig, code string) (*dingTalkSSOUser, string, error) {
 	// 1. 获取access_token
-	accessTokenURL := fmt.Sprintf("https://oapi.dingtalk.com/gettoken?appkey=%s&appsecret=%s", config.AppKey, config.AppSecret)
+	accessTokenURL := dingTalkOAPIURL(fmt.Sprintf("/gettoken?appkey=%s&appsecret=%s", config.AppKey, config.AppSecret))
 	resp, err := http.Get(accessTokenURL)
 	if err != nil {
 		logrus.Errorf("获取access_token失败: %v", err)
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
-	
+
 	// 解析access_token响应
 	var accessTokenResp struct {
 		Errcode     int    `json:"errcode"`
@@ -87,72 +186,38 @@ func (s *SSOService) TestSSO(companyID uint, code string) (map[string]interface{
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&accessTokenResp); err != nil {
 		logrus.Errorf("解析access_token响应失败: %v", err)
-		return nil, err
+		return nil, "", err
 	}
-	
+
 	if accessTokenResp.Errcode != 0 {
 		logrus.Errorf("获取access_token失败: %s", accessTokenResp.Errmsg)
-		return nil, errors.New(fmt.Sprintf("获取access_token失败: %s", accessTokenResp.Errmsg))
+		return nil, "", &DingTalkError{Op: "获取access_token", Errcode: accessTokenResp.Errcode, Errmsg: accessTokenResp.Errmsg}
 	}
-	
+
 	// 2. 使用code获取用户信息
-	userInfoURL := fmt.Sprintf("https://oapi.dingtalk.com/user/getuserinfo?access_token=%s&code=%s", accessTokenResp.AccessToken, code)
+	userInfoURL := dingTalkOAPIURL(fmt.Sprintf("/user/getuserinfo?access_token=%s&code=%s", accessTokenResp.AccessToken, url.QueryEscape(code)))
 	resp, err = http.Get(userInfoURL)
 	if err != nil {
 		logrus.Errorf("获取用户信息失败: %v", err)
-		return nil, err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
-	
+
 	// 解析用户信息响应
 	var userInfoResp struct {
-		Errcode     int    `json:"errcode"`
-		Errmsg      string `json:"errmsg"`
-		Userid      string `json:"userid"`
-		Name        string `json:"name"`
-		Department  []int  `json:"department"`
-		Unionid     string `json:"unionid"`
-		Openid      string `json:"openid"`
-		Avatar      string `json:"avatar"`
-		Mobile      string `json:"mobile"`
-		Email       string `json:"email"`
-		Jobnumber   string `json:"jobnumber"`
-		Position    string `json:"position"`
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+		dingTalkSSOUser
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&userInfoResp); err != nil {
 		logrus.Errorf("解析用户信息响应失败: %v", err)
-		return nil, err
+		return nil, "", err
 	}
-	
+
 	if userInfoResp.Errcode != 0 {
 		logrus.Errorf("获取用户信息失败: %s", userInfoResp.Errmsg)
-		return nil, errors.New(fmt.Sprintf("获取用户信息失败: %s", userInfoResp.Errmsg))
+		return nil, "", &DingTalkError{Op: "获取用户信息", Errcode: userInfoResp.Errcode, Errmsg: userInfoResp.Errmsg}
 	}
-	
-	// 返回结果
-	result := map[string]interface{}{
-		"success": true,
-		"message": "免登测试成功",
-		"data": map[string]interface{}{
-			"company_id": companyID,
-			"app_key":    config.AppKey,
-			"code":       code,
-			"user_info": map[string]interface{}{
-				"userid":     userInfoResp.Userid,
-				"name":       userInfoResp.Name,
-				"department": userInfoResp.Department,
-				"unionid":    userInfoResp.Unionid,
-				"openid":     userInfoResp.Openid,
-				"avatar":     userInfoResp.Avatar,
-				"mobile":     userInfoResp.Mobile,
-				"email":      userInfoResp.Email,
-				"jobnumber":  userInfoResp.Jobnumber,
-				"position":   userInfoResp.Position,
-			},
-		},
-	}
-	
-	logrus.Infof("免登测试成功，公司ID: %d, AppKey: %s, Code: %s, UserID: %s", companyID, config.AppKey, code, userInfoResp.Userid)
-	
-	return result, nil
+
+	return &userInfoResp.dingTalkSSOUser, accessTokenResp.AccessToken, nil
 }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// newSSOLoginLog 创建一条免登记录，授权码只保存哈希
+func newSSOLoginLog(companyID uint, action, code, clientIP string) *model.SSOLoginLog {
+	sum := sha256.Sum256([]byte(code))
+	return &model.SSOLoginLog{
+		CompanyID: companyID,
+		Action:    action,
+		CodeHash:  hex.EncodeToString(sum[:]),
+		IP:        clientIP,
+	}
+}
+
+// recordAttempt 保存免登记录，保存失败只记录日志，不影响免登结果
+func (s *SSOService) recordAttempt(attempt *model.SSOLoginLog, started time.Time, err error) {
+	attempt.Duration = time.Since(started).Milliseconds()
+	attempt.Status = model.SSOLogStatusSuccess
+	if err != nil {
+		attempt.Status = model.SSOLogStatusFailed
+		attempt.Errmsg = err.Error()
+
+		var dingTalkErr *DingTalkError
+		if errors.As(err, &dingTalkErr) {
+			attempt.Errcode = dingTalkErr.Errcode
+		}
+	}
+
+	if err := database.GetDB().Create(attempt).Error; err != nil {
+		logrus.Errorf("保存免登记录失败: %v", err)
+	}
+}
+
+// SSOLogFilter 免登记录查询条件，零值表示不过滤
+type SSOLogFilter struct {
+	CompanyID      uint
+	Action         string
+	Status         string
+	DingTalkUserID string
+	UserID         uint
+	StartTime      *time.Time
+	EndTime        *time.Time
+}
+
+// apply 将查询条件应用到查询上
+func (f SSOLogFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.CompanyID > 0 {
+		query = query.Where("company_id = ?", f.CompanyID)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.DingTalkUserID != "" {
+		query = query.Where("dingtalk_userid = ?", f.DingTalkUserID)
+	}
+	if f.UserID > 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.StartTime != nil {
+		query = query.Where("created_at >= ?", *f.StartTime)
+	}
+	if f.EndTime != nil {
+		query = query.Where("created_at < ?", *f.EndTime)
+	}
+	return query
+}
+
+// ListLogs 分页获取免登记录
+func (s *SSOService) ListLogs(filter SSOLogFilter, page, pageSize int) ([]model.SSOLoginLog, int64, error) {
+	db := database.GetDB()
+
+	var logs []model.SSOLoginLog
+	var total int64
+
+	query := filter.apply(db.Model(&model.SSOLoginLog{}))
+	if err := query.Count(&total).Error; err != nil {
+		logrus.Errorf("获取免登记录总数失败: %v", err)
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		logrus.Errorf("获取免登记录失败: %v", err)
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// SSOErrorStat 免登失败原因统计
+type SSOErrorStat struct {
+	Errcode int    `json:"errcode"`
+	Errmsg  string `json:"errmsg"`
+	Count   int64  `json:"count"`
+}
+
+// SSOLoginStat 公司的免登成功率统计
+type SSOLoginStat struct {
+	CompanyID    uint           `json:"company_id"`
+	Total        int64          `json:"total"`
+	Success      int64          `json:"success"`
+	Failed       int64          `gorm:"-" json:"failed"`
+	SuccessRate  float64        `gorm:"-" json:"success_rate"` // 成功率，0-1
+	AvgDuration  float64        `json:"avg_duration"`          // 平均耗时（毫秒）
+	LastFailedAt *time.Time     `gorm:"-" json:"last_failed_at"`
+	LastErrmsg   string         `gorm:"-" json:"last_errmsg"`
+	TopErrors    []SSOErrorStat `gorm:"-" json:"top_errors"` // 出现最多的失败原因
+}
+
+// Stats 按公司统计免登成功率，filter 中的用户和结果条件不参与统计
+func (s *SSOService) Stats(filter SSOLogFilter) ([]SSOLoginStat, error) {
+	db := database.GetDB()
+	filter.Status = ""
+	filter.DingTalkUserID = ""
+	filter.UserID = 0
+
+	var stats []SSOLoginStat
+	err := filter.apply(db.Model(&model.SSOLoginLog{})).
+		Select("company_id, COUNT(*) AS total, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS success, AVG(duration) AS avg_duration", model.SSOLogStatusSuccess).
+		Group("company_id").
+		Order("company_id").
+		Scan(&stats).Error
+	if err != nil {
+		logrus.Errorf("统计免登记录失败: %v", err)
+		return nil, err
+	}
+
+	for i := range stats {
+		stat := &stats[i]
+		stat.Failed = stat.Total - stat.Success
+		if stat.Total > 0 {
+			stat.SuccessRate = float64(stat.Success) / float64(stat.Total)
+		}
+		if stat.Failed == 0 {
+			stat.TopErrors = []SSOErrorStat{}
+			continue
+		}
+
+		companyFilter := filter
+		companyFilter.CompanyID = stat.CompanyID
+		companyFilter.Status = model.SSOLogStatusFailed
+
+		var lastFailed model.SSOLoginLog
+		if err := companyFilter.apply(db.Model(&model.SSOLoginLog{})).Order("id DESC").First(&lastFailed).Error; err == nil {
+			stat.LastFailedAt = &lastFailed.CreatedAt
+			stat.LastErrmsg = lastFailed.Errmsg
+		}
+
+		err := companyFilter.apply(db.Model(&model.SSOLoginLog{})).
+			Select("errcode, errmsg, COUNT(*) AS count").
+			Group("errcode, errmsg").
+			Order("count DESC").
+			Limit(5).
+			Scan(&stat.TopErrors).Error
+		if err != nil {
+			logrus.Errorf("统计免登失败原因失败: %v", err)
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
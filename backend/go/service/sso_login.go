@@ -0,0 +1,381 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrSSOUnbound 钉钉账号未绑定系统用户且配置为拒绝登录
+var ErrSSOUnbound = errors.New("该钉钉账号未绑定系统用户，请联系管理员")
+
+// validateSSOPolicy 校验并补全免登登录策略
+func validateSSOPolicy(config *model.SSOConfig) error {
+	switch config.BindBy {
+	case "":
+		config.BindBy = model.SSOBindByUserID
+	case model.SSOBindByUserID, model.SSOBindByUnionID:
+	default:
+		return fmt.Errorf("不支持的绑定依据: %s", config.BindBy)
+	}
+
+	switch config.UnboundPolicy {
+	case "":
+		config.UnboundPolicy = model.SSOUnboundReject
+	case model.SSOUnboundReject, model.SSOUnboundMatch, model.SSOUnboundProvision:
+	default:
+		return fmt.Errorf("不支持的未绑定账号处理方式: %s", config.UnboundPolicy)
+	}
+	return nil
+}
+
+// Login 使用钉钉免登授权码登录，返回绑定的系统用户及其角色ID
+// 未绑定的账号按公司配置拒绝登录、匹配已有用户或自动创建用户，每次登录都会保存免登记录
+func (s *SSOService) Login(companyID uint, code, clientIP string) (*model.User, []uint, error) {
+	started := time.Now()
+	attempt := newSSOLoginLog(companyID, model.SSOActionLogin, code, clientIP)
+	user, roleIDs, err := s.login(companyID, code, attempt)
+	s.recordAttempt(attempt, started, err)
+	return user, roleIDs, err
+}
+
+// login 执行免登登录，解析出的钉钉账号和匹配的系统用户写入 attempt
+func (s *SSOService) login(companyID uint, code string, attempt *model.SSOLoginLog) (*model.User, []uint, error) {
+	db := database.GetDB()
+
+	// 获取启用的配置
+	var config model.SSOConfig
+	if err := db.Where("company_id = ? AND status = 1", companyID).First(&config).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("该公司未启用钉钉免登")
+		}
+		logrus.Errorf("获取身份验证（免登）配置失败: %v", err)
+		return nil, nil, err
+	}
+	if err := validateSSOPolicy(&config); err != nil {
+		return nil, nil, err
+	}
+
+	dingTalkUser, accessToken, err := s.exchangeCode(&config, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dingTalkUser.Userid == "" {
+		return nil, nil, errors.New("免登授权码无效")
+	}
+	attempt.DingTalkUserID = dingTalkUser.Userid
+
+	// getuserinfo 不一定返回 unionid、手机号等字段，按需查询用户详情补全
+	if (config.BindBy == model.SSOBindByUnionID && dingTalkUser.Unionid == "") ||
+		(config.UnboundPolicy != model.SSOUnboundReject && dingTalkUser.Mobile == "" && dingTalkUser.Email == "") {
+		if err := s.fillUserDetail(accessToken, dingTalkUser); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	binding, err := s.findBinding(&config, dingTalkUser)
+	if err != nil {
+		return nil, nil, err
+	}
+	if binding == nil {
+		binding, err = s.bindUnbound(&config, dingTalkUser)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	attempt.UserID = binding.UserID
+
+	// 查找绑定的用户
+	var user model.User
+	if err := db.Preload("Roles").Where("id = ? AND company_id = ?", binding.UserID, companyID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("绑定的用户不存在")
+		}
+		logrus.Errorf("查找用户失败: %v", err)
+		return nil, nil, err
+	}
+	if user.Status != 1 {
+		return nil, nil, errors.New("用户已禁用")
+	}
+
+	// 记录最近一次免登信息
+	now := time.Now()
+	updates := map[string]interface{}{"last_login_at": &now, "dingtalk_name": dingTalkUser.Name}
+	if dingTalkUser.Unionid != "" {
+		updates["dingtalk_unionid"] = dingTalkUser.Unionid
+	}
+	if err := db.Model(binding).Updates(updates).Error; err != nil {
+		logrus.Errorf("更新钉钉账号绑定失败: %v", err)
+	}
+
+	// 提取角色ID列表
+	var roleIDs []uint
+	for _, role := range user.Roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	// 清空密码
+	user.Password = ""
+
+	logrus.Infof("钉钉免登成功，公司ID: %d, 钉钉UserID: %s, 用户名: %s", companyID, dingTalkUser.Userid, user.Username)
+
+	return &user, roleIDs, nil
+}
+
+// fillUserDetail 查询钉钉用户详情，补全免登返回中缺少的字段
+func (s *SSOService) fillUserDetail(accessToken string, dingTalkUser *dingTalkSSOUser) error {
+	body, _ := json.Marshal(map[string]string{"userid": dingTalkUser.Userid})
+	resp, err := http.Post(dingTalkOAPIURL("/topapi/v2/user/get?access_token="+accessToken), "application/json", bytes.NewReader(body))
+	if err != nil {
+		logrus.Errorf("获取钉钉用户详情失败: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	var detailResp struct {
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+		Result  struct {
+			Unionid string `json:"unionid"`
+			Name    string `json:"name"`
+			Mobile  string `json:"mobile"`
+			Email   string `json:"email"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&detailResp); err != nil {
+		logrus.Errorf("解析钉钉用户详情失败: %v", err)
+		return err
+	}
+	if detailResp.Errcode != 0 {
+		logrus.Errorf("获取钉钉用户详情失败: %s", detailResp.Errmsg)
+		return &DingTalkError{Op: "获取钉钉用户详情", Errcode: detailResp.Errcode, Errmsg: detailResp.Errmsg}
+	}
+
+	if dingTalkUser.Unionid == "" {
+		dingTalkUser.Unionid = detailResp.Result.Unionid
+	}
+	if dingTalkUser.Name == "" {
+		dingTalkUser.Name = detailResp.Result.Name
+	}
+	if dingTalkUser.Mobile == "" {
+		dingTalkUser.Mobile = detailResp.Result.Mobile
+	}
+	if dingTalkUser.Email == "" {
+		dingTalkUser.Email = detailResp.Result.Email
+	}
+	return nil
+}
+
+// findBinding 按配置的绑定依据查找钉钉账号的绑定关系，不存在时返回 nil
+func (s *SSOService) findBinding(config *model.SSOConfig, dingTalkUser *dingTalkSSOUser) (*model.SSOBinding, error) {
+	db := database.GetDB()
+
+	query := db.Where("company_id = ?", config.CompanyID)
+	if config.BindBy == model.SSOBindByUnionID {
+		if dingTalkUser.Unionid == "" {
+			return nil, errors.New("未获取到钉钉账号的unionid")
+		}
+		query = query.Where("dingtalk_unionid = ?", dingTalkUser.Unionid)
+	} else {
+		query = query.Where("dingtalk_userid = ?", dingTalkUser.Userid)
+	}
+
+	var binding model.SSOBinding
+	if err := query.First(&binding).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logrus.Errorf("查找钉钉账号绑定失败: %v", err)
+		return nil, err
+	}
+	return &binding, nil
+}
+
+// bindUnbound 按配置处理未绑定的钉钉账号
+func (s *SSOService) bindUnbound(config *model.SSOConfig, dingTalkUser *dingTalkSSOUser) (*model.SSOBinding, error) {
+	if config.UnboundPolicy == model.SSOUnboundReject {
+		return nil, ErrSSOUnbound
+	}
+
+	db := database.GetDB()
+
+	// 按手机号或邮箱匹配公司内已有用户，匹配到多个时无法确定，拒绝登录
+	var matched []model.User
+	if dingTalkUser.Mobile != "" || dingTalkUser.Email != "" {
+		query := db.Where("company_id = ?", config.CompanyID)
+		switch {
+		case dingTalkUser.Mobile != "" && dingTalkUser.Email != "":
+			query = query.Where("phone = ? OR email = ?", dingTalkUser.Mobile, dingTalkUser.Email)
+		case dingTalkUser.Mobile != "":
+			query = query.Where("phone = ?", dingTalkUser.Mobile)
+		default:
+			query = query.Where("email = ?", dingTalkUser.Email)
+		}
+		if err := query.Limit(2).Find(&matched).Error; err != nil {
+			logrus.Errorf("匹配系统用户失败: %v", err)
+			return nil, err
+		}
+	}
+	if len(matched) > 1 {
+		return nil, errors.New("钉钉账号匹配到多个系统用户，请联系管理员绑定")
+	}
+
+	binding := &model.SSOBinding{
+		CompanyID:       config.CompanyID,
+		DingTalkUserID:  dingTalkUser.Userid,
+		DingTalkUnionID: dingTalkUser.Unionid,
+		DingTalkName:    dingTalkUser.Name,
+	}
+
+	if len(matched) == 1 {
+		binding.UserID = matched[0].ID
+		if err := db.Create(binding).Error; err != nil {
+			logrus.Errorf("创建钉钉账号绑定失败: %v", err)
+			return nil, err
+		}
+		logrus.Infof("钉钉账号自动绑定已有用户，公司ID: %d, 钉钉UserID: %s, 用户ID: %d", config.CompanyID, dingTalkUser.Userid, binding.UserID)
+		return binding, nil
+	}
+
+	if config.UnboundPolicy != model.SSOUnboundProvision {
+		return nil, ErrSSOUnbound
+	}
+
+	// 自动创建用户，分配默认角色并绑定
+	err := db.Transaction(func(tx *gorm.DB) error {
+		user, err := provisionSSOUser(tx, config, dingTalkUser)
+		if err != nil {
+			return err
+		}
+		binding.UserID = user.ID
+		return tx.Create(binding).Error
+	})
+	if err != nil {
+		logrus.Errorf("自动创建钉钉免登用户失败: %v", err)
+		return nil, err
+	}
+
+	logrus.Infof("钉钉免登自动创建用户，公司ID: %d, 钉钉UserID: %s, 用户ID: %d", config.CompanyID, dingTalkUser.Userid, binding.UserID)
+	return binding, nil
+}
+
+// provisionSSOUser 为钉钉账号创建系统用户，用户名使用 dingtalk_ 前缀加钉钉 userid，密码随机生成
+func provisionSSOUser(tx *gorm.DB, config *model.SSOConfig, dingTalkUser *dingTalkSSOUser) (*model.User, error) {
+	if config.DefaultRoleID != 0 {
+		var role model.Role
+		if err := tx.Where("id = ? AND status = 1", config.DefaultRoleID).First(&role).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("免登配置的默认角色不存在或已禁用")
+			}
+			return nil, err
+		}
+	}
+
+	username := "dingtalk_" + dingTalkUser.Userid
+	var count int64
+	if err := tx.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("用户名 %s 已存在，请联系管理员绑定", username)
+	}
+
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+
+	nickname := dingTalkUser.Name
+	if nickname == "" {
+		nickname = dingTalkUser.Userid
+	}
+	user := &model.User{
+		CompanyID: config.CompanyID,
+		Username:  username,
+		Password:  hex.EncodeToString(password),
+		Nickname:  nickname,
+		Email:     dingTalkUser.Email,
+		Phone:     dingTalkUser.Mobile,
+		Status:    1,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, err
+	}
+
+	if config.DefaultRoleID != 0 {
+		if err := tx.Create(&model.UserRole{UserID: user.ID, RoleID: config.DefaultRoleID}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ListBindings 获取公司的钉钉账号绑定列表
+func (s *SSOService) ListBindings(companyID uint) ([]model.SSOBinding, error) {
+	db := database.GetDB()
+
+	var bindings []model.SSOBinding
+	if err := db.Preload("User").Where("company_id = ?", companyID).Order("id DESC").Find(&bindings).Error; err != nil {
+		logrus.Errorf("获取钉钉账号绑定列表失败: %v", err)
+		return nil, err
+	}
+	for i := range bindings {
+		bindings[i].User.Password = ""
+	}
+	return bindings, nil
+}
+
+// CreateBinding 手动绑定钉钉账号与系统用户
+func (s *SSOService) CreateBinding(binding *model.SSOBinding) error {
+	db := database.GetDB()
+
+	if binding.DingTalkUserID == "" {
+		return errors.New("钉钉UserID不能为空")
+	}
+
+	// 用户必须属于同一公司
+	var user model.User
+	if err := db.Where("id = ? AND company_id = ?", binding.UserID, binding.CompanyID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("用户不存在")
+		}
+		return err
+	}
+
+	var count int64
+	db.Model(&model.SSOBinding{}).Where("company_id = ? AND dingtalk_userid = ?", binding.CompanyID, binding.DingTalkUserID).Count(&count)
+	if count > 0 {
+		return errors.New("该钉钉账号已绑定")
+	}
+
+	if err := db.Create(binding).Error; err != nil {
+		logrus.Errorf("创建钉钉账号绑定失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteBinding 解除钉钉账号绑定
+func (s *SSOService) DeleteBinding(id uint) error {
+	db := database.GetDB()
+
+	result := db.Delete(&model.SSOBinding{}, id)
+	if result.Error != nil {
+		logrus.Errorf("解除钉钉账号绑定失败: %v", result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("绑定关系不存在")
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/dingtalkmock"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+// startDingTalkMock 启动模拟钉钉服务并将钉钉接口地址指向它，返回的函数用于关闭并恢复配置
+func startDingTalkMock() (*dingtalkmock.Server, func()) {
+	mock := dingtalkmock.New(dingtalkmock.DefaultFixtures())
+	server := httptest.NewServer(mock)
+	// 旧版接口经模拟的 Python 旧版API服务转发
+	legacy := startLegacyService(new(int32), new(string))
+
+	original := config.GlobalConfig
+	config.GlobalConfig = &config.Config{
+		DingTalk: config.DingTalkConfig{OAPIBaseURL: server.URL, APIBaseURL: server.URL},
+		Legacy:   config.LegacyConfig{URL: legacy.URL, Secret: testLegacySecret},
+	}
+	return mock, func() {
+		config.GlobalConfig = original
+		server.Close()
+		legacy.Close()
+		resetLegacyState()
+	}
+}
+
+func TestSSOWithMock(t *testing.T) {
+	setupTestModels(&model.SSOConfig{}, &model.SSOLoginLog{}, &model.SSOBinding{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+
+	db.Create(&model.SSOConfig{CompanyID: 1, AppID: "app", AppKey: "mock-app-key", AppSecret: "mock-app-secret", Status: 1})
+
+	t.Run("Success", func(t *testing.T) {
+		result, err := NewSSOService().TestSSO(1, "mock-code-dev")
+		if err != nil {
+			t.Fatalf("TestSSO failed: %v", err)
+		}
+		userInfo := result["data"].(map[string]interface{})["user_info"].(map[string]interface{})
+		if userInfo["userid"] != "dev01" || userInfo["name"] != "李四" {
+			t.Errorf("Unexpected user info: %v", userInfo)
+		}
+	})
+
+	t.Run("InvalidCode", func(t *testing.T) {
+		if _, err := NewSSOService().TestSSO(1, "unknown"); err == nil {
+			t.Error("Expected error for unknown code")
+		}
+	})
+
+	t.Run("InjectedFault", func(t *testing.T) {
+		mock.InjectFault(dingtalkmock.EndpointGetToken, dingtalkmock.Fault{Errcode: dingtalkmock.ErrcodeServiceUnavailable, Errmsg: "系统繁忙", Times: 1})
+		if _, err := NewSSOService().TestSSO(1, "mock-code-dev"); err == nil {
+			t.Error("Expected injected gettoken error")
+		}
+		if _, err := NewSSOService().TestSSO(1, "mock-code-dev"); err != nil {
+			t.Errorf("Expected fault to apply once, got %v", err)
+		}
+		if calls := mock.Calls(dingtalkmock.EndpointGetToken); calls != 4 {
+			t.Errorf("Expected 4 gettoken calls, got %d", calls)
+		}
+	})
+}
+
+func TestAccessTokenWithMock(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.AccessToken{}, &model.AccessTokenRefreshLog{})
+	db := database.GetDB()
+	mock, stop := startDingTalkMock()
+	defer stop()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	svc := NewAccessTokenService()
+
+	for _, tokenType := range []string{model.AccessTokenTypeLegacy, model.AccessTokenTypeOAuth2} {
+		token, err := svc.CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, TokenType: tokenType, AppKey: "mock-app-key", AppSecret: "mock-app-secret"})
+		if err != nil {
+			t.Fatalf("CreateAccessToken %s failed: %v", tokenType, err)
+		}
+		if token.AccessToken == "" || token.ExpiresIn != 7200 {
+			t.Errorf("Unexpected %s token: %+v", tokenType, token)
+		}
+	}
+	if mock.Calls(dingtalkmock.EndpointGetToken) != 1 || mock.Calls(dingtalkmock.EndpointOAuth2AccessToken) != 1 {
+		t.Errorf("Expected one call to each token endpoint")
+	}
+
+	if _, err := svc.CreateAccessToken(nil, &model.AccessToken{CompanyID: 1, TokenType: model.AccessTokenTypeLegacy, AppKey: "k", AppSecret: "s"}); err == nil {
+		t.Error("Expected duplicate token type to be rejected")
+	}
+	if err := svc.TestAccessToken(nil, 1, model.AccessTokenTypeLegacy); err != nil {
+		t.Errorf("TestAccessToken failed: %v", err)
+	}
+
+	// 新版令牌使用应用凭证调用 oauth2/accessToken 校验
+	if err := svc.TestAccessToken(nil, 1, model.AccessTokenTypeOAuth2); err != nil {
+		t.Errorf("TestAccessToken oauth2 failed: %v", err)
+	}
+	if calls := mock.Calls(dingtalkmock.EndpointOAuth2AccessToken); calls != 2 {
+		t.Errorf("Expected oauth2 credentials checked, got %d accessToken calls", calls)
+	}
+	mock.InjectFault(dingtalkmock.EndpointOAuth2AccessToken, dingtalkmock.Fault{Code: "invalidClientIdOrSecret", Message: "无效的clientId或clientSecret", Times: 1})
+	if err := svc.TestAccessToken(nil, 1, model.AccessTokenTypeOAuth2); err == nil || !strings.Contains(err.Error(), "无效的clientId或clientSecret") {
+		t.Errorf("Expected rejected oauth2 credentials, got %v", err)
+	}
+
+	// 令牌失效后自动刷新并重试
+	mock.ExpireTokens()
+	apiConfig := &model.APIConfig{CompanyID: 1, Type: 2, Method: "POST", BaseURL: dingTalkOAPIURL(""), Path: dingtalkmock.EndpointUserGet, Params: `{"userid":"dev01"}`}
+	result, err := NewAPIConfigService().Test(apiConfig)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	if data, ok := result["response"].(map[string]interface{}); !ok || data["errcode"] != float64(0) {
+		t.Errorf("Expected request to succeed after refresh, got %v", result)
+	}
+	if calls := mock.Calls(dingtalkmock.EndpointGetToken); calls != 2 {
+		t.Errorf("Expected token refreshed once, got %d gettoken calls", calls)
+	}
+
+	if _, err := NewTokenProvider().Token(context.Background(), 1, model.AccessTokenTypeOAuth2); err != nil {
+		t.Errorf("Expected oauth2 token, got %v", err)
+	}
+}
+
+func TestSSOLogin(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.Role{}, &model.SSOConfig{}, &model.SSOLoginLog{}, &model.User{}, &model.SSOBinding{})
+	db := database.GetDB()
+	_, stop := startDingTalkMock()
+	defer stop()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	db.Create(&model.Role{ID: 1, Name: "普通用户", Code: "member", Status: 1})
+	db.Create(&model.User{ID: 1, CompanyID: 1, Username: "manager", Password: "x", Phone: "13800000001", Status: 1})
+	db.Create(&model.SSOConfig{CompanyID: 1, AppID: "app", AppKey: "mock-app-key", AppSecret: "mock-app-secret", Status: 1})
+	svc := NewSSOService()
+
+	setPolicy := func(policy string, defaultRoleID uint) {
+		db.Model(&model.SSOConfig{}).Where("company_id = ?", 1).Updates(map[string]interface{}{"unbound_policy": policy, "default_role_id": defaultRoleID})
+	}
+
+	t.Run("RejectUnbound", func(t *testing.T) {
+		setPolicy(model.SSOUnboundReject, 0)
+		if _, _, err := svc.Login(1, "mock-code-manager", ""); !errors.Is(err, ErrSSOUnbound) {
+			t.Errorf("Expected ErrSSOUnbound, got %v", err)
+		}
+	})
+
+	t.Run("Bound", func(t *testing.T) {
+		if err := svc.CreateBinding(&model.SSOBinding{CompanyID: 1, UserID: 1, DingTalkUserID: "manager01"}); err != nil {
+			t.Fatalf("CreateBinding failed: %v", err)
+		}
+		user, _, err := svc.Login(1, "mock-code-manager", "")
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if user.ID != 1 || user.Password != "" {
+			t.Errorf("Unexpected user: %+v", user)
+		}
+		var binding model.SSOBinding
+		db.Where("dingtalk_userid = ?", "manager01").First(&binding)
+		if binding.LastLoginAt == nil || binding.DingTalkUnionID != "union-manager01" {
+			t.Errorf("Expected binding to be refreshed, got %+v", binding)
+		}
+	})
+
+	t.Run("Provision", func(t *testing.T) {
+		setPolicy(model.SSOUnboundProvision, 1)
+		user, roleIDs, err := svc.Login(1, "mock-code-dev", "")
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if user.Username != "dingtalk_dev01" || user.Nickname != "李四" || user.CompanyID != 1 {
+			t.Errorf("Unexpected provisioned user: %+v", user)
+		}
+		if len(roleIDs) != 1 || roleIDs[0] != 1 {
+			t.Errorf("Expected default role, got %v", roleIDs)
+		}
+
+		// 再次登录使用已创建的绑定
+		again, _, err := svc.Login(1, "mock-code-dev", "")
+		if err != nil || again.ID != user.ID {
+			t.Errorf("Expected same user on second login, got %+v, %v", again, err)
+		}
+	})
+
+	t.Run("DisabledUser", func(t *testing.T) {
+		db.Model(&model.User{}).Where("id = ?", 1).Update("status", 0)
+		if _, _, err := svc.Login(1, "mock-code-manager", ""); err == nil {
+			t.Error("Expected error for disabled user")
+		}
+	})
+}
+
+func TestSSOLoginLogs(t *testing.T) {
+	setupTestModels(&model.Company{}, &model.SSOBinding{}, &model.SSOConfig{}, &model.SSOLoginLog{}, &model.User{})
+	db := database.GetDB()
+	_, stop := startDingTalkMock()
+	defer stop()
+
+	db.Create(&model.Company{ID: 1, Name: "c1", Code: "c1"})
+	db.Create(&model.User{ID: 1, CompanyID: 1, Username: "dev", Password: "x", Status: 1})
+	db.Create(&model.SSOConfig{CompanyID: 1, AppID: "app", AppKey: "mock-app-key", AppSecret: "mock-app-secret", Status: 1})
+	db.Create(&model.SSOBinding{CompanyID: 1, UserID: 1, DingTalkUserID: "dev01"})
+	svc := NewSSOService()
+
+	if _, err := svc.TestSSO(1, "mock-code-dev"); err != nil {
+		t.Fatalf("TestSSO failed: %v", err)
+	}
+	if _, _, err := svc.Login(1, "mock-code-dev", "127.0.0.1"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	svc.Login(1, "bad-code", "127.0.0.1")
+	svc.Login(1, "mock-code-manager", "127.0.0.1")
+
+	logs, total, err := svc.ListLogs(SSOLogFilter{CompanyID: 1}, 1, 10)
+	if err != nil || total != 4 {
+		t.Fatalf("Expected 4 logs, got %d, %v", total, err)
+	}
+	test := logs[3]
+	if test.Action != model.SSOActionTest || test.DingTalkUserID != "dev01" || test.UserID != 1 || test.Status != model.SSOLogStatusSuccess {
+		t.Errorf("Unexpected test log: %+v", test)
+	}
+	if test.CodeHash == "" || test.CodeHash == "mock-code-dev" {
+		t.Errorf("Expected hashed code, got %q", test.CodeHash)
+	}
+	badCode := logs[1]
+	if badCode.Status != model.SSOLogStatusFailed || badCode.Errcode != dingtalkmock.ErrcodeInvalidAuthCode {
+		t.Errorf("Expected DingTalk errcode on failed log, got %+v", badCode)
+	}
+	if logs[0].DingTalkUserID != "manager01" || logs[0].UserID != 0 || logs[0].Errmsg != ErrSSOUnbound.Error() {
+		t.Errorf("Unexpected unbound log: %+v", logs[0])
+	}
+
+	_, failed, _ := svc.ListLogs(SSOLogFilter{CompanyID: 1, Action: model.SSOActionLogin, Status: model.SSOLogStatusFailed}, 1, 10)
+	if failed != 2 {
+		t.Errorf("Expected 2 failed logins, got %d", failed)
+	}
+
+	stats, err := svc.Stats(SSOLogFilter{})
+	if err != nil || len(stats) != 1 {
+		t.Fatalf("Expected stats for one company, got %v, %v", stats, err)
+	}
+	if stats[0].Total != 4 || stats[0].Success != 2 || stats[0].SuccessRate != 0.5 || len(stats[0].TopErrors) != 2 {
+		t.Errorf("Unexpected stats: %+v", stats[0])
+	}
+	if stats[0].LastFailedAt == nil || stats[0].LastErrmsg != ErrSSOUnbound.Error() {
+		t.Errorf("Expected last failure in stats, got %+v", stats[0])
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestModels 使用内存数据库替换全局数据库，只迁移测试用到的模型
+func setupTestModels(models ...interface{}) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		fmt.Printf("failed to connect database: %v\n", err)
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(models...); err != nil {
+		fmt.Printf("failed to migrate database: %v\n", err)
+		panic(err)
+	}
+	// 内存数据库每个连接相互独立，只使用一个连接
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	database.DB = db
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrAccessTokenNotConfigured 公司没有启用的AccessToken配置
+var ErrAccessTokenNotConfigured = errors.New("未找到有效的AccessToken配置")
+
+// tokenInvalidateDelay 缓存二次删除的延迟，覆盖并发读取旧数据后回填缓存的情况
+const tokenInvalidateDelay = time.Second
+
+// accessTokenCacheKey 返回公司指定类型AccessToken在Redis中的键
+func accessTokenCacheKey(companyID uint, tokenType string) string {
+	return fmt.Sprintf("access_token:%d:%s", companyID, tokenType)
+}
+
+// TokenProvider 为调用钉钉接口提供AccessToken
+// 优先读取 Redis 缓存，未命中时读取数据库并回填缓存，已过期时刷新
+type TokenProvider struct {
+	service *AccessTokenService
+}
+
+// NewTokenProvider 创建AccessToken提供者
+func NewTokenProvider() *TokenProvider {
+	return &TokenProvider{service: NewAccessTokenService()}
+}
+
+// Token 获取公司指定类型当前有效的AccessToken
+func (p *TokenProvider) Token(ctx context.Context, companyID uint, tokenType string) (string, error) {
+	redisClient := database.GetRedis()
+	key := accessTokenCacheKey(companyID, tokenType)
+
+	if redisClient != nil {
+		token, err := redisClient.Get(ctx, key).Result()
+		if err == nil && token != "" {
+			return token, nil
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			logrus.Errorf("读取AccessToken缓存失败: %v", err)
+		}
+	}
+
+	var accessToken model.AccessToken
+	if err := database.GetDB().Where("company_id = ? AND token_type = ? AND status = 1", companyID, tokenType).First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrAccessTokenNotConfigured
+		}
+		logrus.Errorf("获取AccessToken失败: %v", err)
+		return "", err
+	}
+
+	if accessToken.AccessToken == "" || !time.Now().Before(accessToken.ExpiresAt) {
+		// 刷新时会同时写入缓存
+		refreshed, err := p.service.refresh(ctx, companyID, tokenType, model.TokenRefreshTriggerExpired)
+		if err != nil {
+			return "", err
+		}
+		return refreshed.AccessToken, nil
+	}
+
+	if redisClient != nil {
+		if err := redisClient.Set(ctx, key, accessToken.AccessToken, time.Until(accessToken.ExpiresAt)).Err(); err != nil {
+			logrus.Errorf("写入AccessToken缓存失败: %v", err)
+		}
+	}
+	return accessToken.AccessToken, nil
+}
+
+// tokenRejectedCodes 钉钉旧版接口表示 AccessToken 无效或过期的错误码
+var tokenRejectedCodes = map[float64]bool{
+	40014: true, // 不合法的 access_token
+	42001: true, // access_token 超时
+}
+
+// validateAuthMode 校验API配置的鉴权方式
+func validateAuthMode(authMode string) error {
+	switch authMode {
+	case "", model.AuthModeNone, model.AuthModeQuery, model.AuthModeHeader:
+		return nil
+	default:
+		return fmt.Errorf("不支持的鉴权方式: %s", authMode)
+	}
+}
+
+// apiAuthMode 返回API配置实际使用的鉴权方式，未配置时旧版接口使用查询参数，新版接口使用请求头
+func apiAuthMode(apiConfig *model.APIConfig) string {
+	if apiConfig.AuthMode != "" {
+		return apiConfig.AuthMode
+	}
+	if apiConfig.Type == model.APITypeLegacy {
+		return model.AuthModeQuery
+	}
+	return model.AuthModeHeader
+}
+
+// apiTokenType 返回API配置使用的AccessToken类型，旧版接口使用 gettoken 获取的令牌，新版接口使用 oauth2 令牌
+func apiTokenType(apiConfig *model.APIConfig) string {
+	if apiConfig.Type == model.APITypeLegacy {
+		return model.AccessTokenTypeLegacy
+	}
+	return model.AccessTokenTypeOAuth2
+}
+
+// Authorize 按API配置的鉴权方式为请求附加公司对应类型的AccessToken，返回附加的AccessToken
+// 未配置鉴权方式且公司没有AccessToken配置时按原样发送
+func (p *TokenProvider) Authorize(ctx context.Context, apiConfig *model.APIConfig, req *http.Request) (string, error) {
+	mode := apiAuthMode(apiConfig)
+	if mode == model.AuthModeNone {
+		return "", nil
+	}
+
+	token, err := p.Token(ctx, apiConfig.CompanyID, apiTokenType(apiConfig))
+	if errors.Is(err, ErrAccessTokenNotConfigured) && apiConfig.AuthMode == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("获取AccessToken失败: %v", err)
+	}
+
+	setRequestToken(req, mode, token)
+	return token, nil
+}
+
+// setRequestToken 按鉴权方式将AccessToken写入请求，覆盖请求中已有的值
+func setRequestToken(req *http.Request, mode, token string) {
+	if mode == model.AuthModeQuery {
+		query := req.URL.Query()
+		query.Set("access_token", token)
+		req.URL.RawQuery = query.Encode()
+		return
+	}
+	req.Header.Set("x-acs-dingtalk-access-token", token)
+}
+
+// Do 附加AccessToken发送请求并读取响应体
+// 发送前按限流配置等待，钉钉返回限流错误时退避重试，返回 AccessToken 无效或过期时刷新后重试一次
+// newRequest 每次调用都需要返回新的请求
+func (p *TokenProvider) Do(ctx context.Context, client *http.Client, apiConfig *model.APIConfig, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := p.Authorize(ctx, apiConfig, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limit := resolveRateLimit(apiConfig)
+	resp, body, err := sendLimited(ctx, client, apiConfig, limit, req, authorizedRequest(apiConfig, token, newRequest))
+	if err != nil || token == "" || !tokenRejected(resp, body) {
+		return resp, body, err
+	}
+
+	logrus.Warnf("钉钉接口返回AccessToken失效，刷新后重试，公司ID: %d", apiConfig.CompanyID)
+	refreshed, err := p.service.refreshRejected(ctx, apiConfig.CompanyID, apiTokenType(apiConfig), token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("刷新AccessToken失败: %v", err)
+	}
+
+	retry := authorizedRequest(apiConfig, refreshed.AccessToken, newRequest)
+	req, err = retry()
+	if err != nil {
+		return nil, nil, err
+	}
+	return sendLimited(ctx, client, apiConfig, limit, req, retry)
+}
+
+// authorizedRequest 返回创建请求并附加指定 AccessToken 的函数，token 为空时不附加
+func authorizedRequest(apiConfig *model.APIConfig, token string, newRequest func() (*http.Request, error)) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		req, err := newRequest()
+		if err != nil || token == "" {
+			return req, err
+		}
+		setRequestToken(req, apiAuthMode(apiConfig), token)
+		return req, nil
+	}
+}
+
+// sendRequest 发送请求并读取响应体
+func sendRequest(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, nil, errors.New("响应体过大")
+	}
+	return resp, body, nil
+}
+
+// tokenRejected 响应是否表示 AccessToken 无效或过期
+// 旧版接口返回 errcode，新版接口返回 HTTP 401 及 code=InvalidAuthentication
+func tokenRejected(resp *http.Response, body []byte) bool {
+	var data struct {
+		Errcode *float64 `json:"errcode"`
+		Code    string   `json:"code"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return false
+	}
+	if data.Errcode != nil && tokenRejectedCodes[*data.Errcode] {
+		return true
+	}
+	return resp.StatusCode == http.StatusUnauthorized && data.Code == "InvalidAuthentication"
+}
+
+// invalidateAccessToken 删除公司指定类型AccessToken的缓存，并在稍后再删除一次
+func invalidateAccessToken(companyID uint, tokenType string) {
+	redisClient := database.GetRedis()
+	if redisClient == nil {
+		return
+	}
+
+	key := accessTokenCacheKey(companyID, tokenType)
+	if err := redisClient.Del(context.Background(), key).Err(); err != nil {
+		logrus.Errorf("删除AccessToken缓存失败: %v", err)
+	}
+	time.AfterFunc(tokenInvalidateDelay, func() {
+		if err := redisClient.Del(context.Background(), key).Err(); err != nil {
+			logrus.Errorf("删除AccessToken缓存失败: %v", err)
+		}
+	})
+}
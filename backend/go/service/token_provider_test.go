@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ddoalistdownload/backend/database"
+	"github.com/ddoalistdownload/backend/model"
+)
+
+func TestTokenProvider(t *testing.T) {
+	setupTestModels(&model.AccessToken{}, &model.AccessTokenRefreshLog{})
+	db := database.GetDB()
+	provider := NewTokenProvider()
+
+	db.Create(&model.AccessToken{
+		CompanyID:   1,
+		AppKey:      "key",
+		AppSecret:   "secret",
+		AccessToken: "db-token",
+		ExpiresIn:   7200,
+		RefreshAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(2 * time.Hour),
+		Status:      1,
+	})
+	db.Create(&model.AccessToken{
+		CompanyID:   1,
+		TokenType:   model.AccessTokenTypeOAuth2,
+		AppKey:      "key",
+		AppSecret:   "secret",
+		AccessToken: "oauth2-token",
+		ExpiresIn:   7200,
+		RefreshAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(2 * time.Hour),
+		Status:      1,
+	})
+
+	t.Run("DBFallback", func(t *testing.T) {
+		token, err := provider.Token(context.Background(), 1, model.AccessTokenTypeLegacy)
+		if err != nil || token != "db-token" {
+			t.Errorf("Expected db-token, got %q %v", token, err)
+		}
+		token, err = provider.Token(context.Background(), 1, model.AccessTokenTypeOAuth2)
+		if err != nil || token != "oauth2-token" {
+			t.Errorf("Expected oauth2-token, got %q %v", token, err)
+		}
+		if _, err := provider.Token(context.Background(), 2, model.AccessTokenTypeLegacy); err != ErrAccessTokenNotConfigured {
+			t.Errorf("Expected not configured error, got %v", err)
+		}
+	})
+
+	t.Run("Authorize", func(t *testing.T) {
+		legacy, _ := http.NewRequest(http.MethodPost, "https://oapi.dingtalk.com/topapi/list?size=10", nil)
+		if _, err := provider.Authorize(context.Background(), &model.APIConfig{CompanyID: 1, Type: 2}, legacy); err != nil {
+			t.Fatalf("Authorize failed: %v", err)
+		}
+		if legacy.URL.Query().Get("access_token") != "db-token" || legacy.URL.Query().Get("size") != "10" {
+			t.Errorf("Expected access_token query, got %s", legacy.URL.RawQuery)
+		}
+
+		current, _ := http.NewRequest(http.MethodGet, "https://api.dingtalk.com/v1.0/list", nil)
+		if _, err := provider.Authorize(context.Background(), &model.APIConfig{CompanyID: 1, Type: 1}, current); err != nil {
+			t.Fatalf("Authorize failed: %v", err)
+		}
+		if current.Header.Get("x-acs-dingtalk-access-token") != "oauth2-token" {
+			t.Errorf("Expected access token header, got %v", current.Header)
+		}
+
+		other, _ := http.NewRequest(http.MethodGet, "https://example.com/list", nil)
+		if _, err := provider.Authorize(context.Background(), &model.APIConfig{CompanyID: 2, Type: 1}, other); err != nil || len(other.Header) != 0 {
+			t.Errorf("Expected request without token config unchanged, got %v %v", other.Header, err)
+		}
+
+		explicit, _ := http.NewRequest(http.MethodGet, "https://example.com/list", nil)
+		if _, err := provider.Authorize(context.Background(), &model.APIConfig{CompanyID: 2, AuthMode: model.AuthModeHeader}, explicit); err == nil {
+			t.Error("Expected error when auth mode is set but token is not configured")
+		}
+
+		none, _ := http.NewRequest(http.MethodGet, "https://api.dingtalk.com/v1.0/list", nil)
+		if _, err := provider.Authorize(context.Background(), &model.APIConfig{CompanyID: 1, AuthMode: model.AuthModeNone}, none); err != nil || len(none.Header) != 0 {
+			t.Errorf("Expected no token for auth mode none, got %v %v", none.Header, err)
+		}
+	})
+
+	t.Run("RetryOnRejected", func(t *testing.T) {
+		var apiCalls, tokenCalls int32
+		transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body := `{"errcode":0,"result":{"list":[]}}`
+			switch {
+			case req.URL.Path == "/gettoken":
+				atomic.AddInt32(&tokenCalls, 1)
+				body = `{"errcode":0,"access_token":"fresh-token","expires_in":7200}`
+			case req.URL.Query().Get("access_token") != "fresh-token":
+				atomic.AddInt32(&apiCalls, 1)
+				body = `{"errcode":40014,"errmsg":"invalid access_token"}`
+			default:
+				atomic.AddInt32(&apiCalls, 1)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})
+		original := http.DefaultTransport
+		http.DefaultTransport = transport
+		defer func() { http.DefaultTransport = original }()
+
+		apiConfig := &model.APIConfig{CompanyID: 1, Type: 2, AuthMode: model.AuthModeQuery}
+		newRequest := func() (*http.Request, error) {
+			return http.NewRequest(http.MethodPost, "https://oapi.dingtalk.com/topapi/list", strings.NewReader(`{"size":10}`))
+		}
+		_, body, err := provider.Do(context.Background(), &http.Client{Transport: transport}, apiConfig, newRequest)
+		if err != nil {
+			t.Fatalf("Do failed: %v", err)
+		}
+		if string(body) != `{"errcode":0,"result":{"list":[]}}` {
+			t.Errorf("Expected response after retry, got %s", body)
+		}
+		if apiCalls != 2 || tokenCalls != 1 {
+			t.Errorf("Expected 2 api calls and 1 token refresh, got %d %d", apiCalls, tokenCalls)
+		}
+
+		var accessToken model.AccessToken
+		db.Where("company_id = ? AND token_type = ?", 1, model.AccessTokenTypeLegacy).First(&accessToken)
+		if accessToken.AccessToken != "fresh-token" {
+			t.Errorf("Expected refreshed token saved, got %s", accessToken.AccessToken)
+		}
+		var count int64
+		db.Model(&model.AccessTokenRefreshLog{}).Where("company_id = ? AND `trigger` = ?", 1, model.TokenRefreshTriggerRejected).Count(&count)
+		if count != 1 {
+			t.Errorf("Expected 1 rejected refresh log, got %d", count)
+		}
+	})
+
+	t.Run("OAuth2Refresh", func(t *testing.T) {
+		var requested *http.Request
+		var requestBody string
+		transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requested = req
+			data, _ := io.ReadAll(req.Body)
+			requestBody = string(data)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"accessToken":"new-oauth2-token","expireIn":3600}`)),
+				Request:    req,
+			}, nil
+		})
+		original := http.DefaultTransport
+		http.DefaultTransport = transport
+		defer func() { http.DefaultTransport = original }()
+
+		token, err := provider.service.refresh(context.Background(), 1, model.AccessTokenTypeOAuth2, model.TokenRefreshTriggerManual)
+		if err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+		if requested.URL.String() != "https://api.dingtalk.com/v1.0/oauth2/accessToken" || requestBody != `{"appKey":"key","appSecret":"secret"}` {
+			t.Errorf("Unexpected oauth2 request %s %s", requested.URL, requestBody)
+		}
+		if token.AccessToken != "new-oauth2-token" || token.ExpiresIn != 3600 {
+			t.Errorf("Expected new oauth2 token, got %s %d", token.AccessToken, token.ExpiresIn)
+		}
+
+		var legacy model.AccessToken
+		db.Where("company_id = ? AND token_type = ?", 1, model.AccessTokenTypeLegacy).First(&legacy)
+		if legacy.AccessToken == "new-oauth2-token" {
+			t.Error("Expected legacy token unchanged by oauth2 refresh")
+		}
+	})
+}
+
+// roundTripperFunc 使用函数模拟 HTTP 传输
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
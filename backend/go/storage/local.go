@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage 本地文件系统存储
+// 签名URL由本服务的公开下载接口校验后返回文件
+type LocalStorage struct {
+	dir       string
+	publicURL string
+	secret    []byte
+}
+
+// NewLocalStorage 创建本地存储
+func NewLocalStorage(dir, publicURL, secret string) (*LocalStorage, error) {
+	if dir == "" {
+		return nil, errors.New("本地存储目录不能为空")
+	}
+	if secret == "" {
+		return nil, errors.New("本地存储签名密钥不能为空")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %v", err)
+	}
+	return &LocalStorage{
+		dir:       dir,
+		publicURL: publicURL,
+		secret:    []byte(secret),
+	}, nil
+}
+
+// Type 返回存储类型
+func (s *LocalStorage) Type() string {
+	return TypeLocal
+}
+
+// Put 写入对象，先写临时文件再重命名，避免读到半个文件
+func (s *LocalStorage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	path, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open 读取对象
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Delete 删除对象
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL 生成形如 {publicURL}?key=...&expires=...&filename=...&signature=... 的下载地址
+func (s *LocalStorage) SignedURL(ctx context.Context, key, fileName string, expires time.Duration) (string, error) {
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("expires", expiresAt)
+	query.Set("filename", fileName)
+	query.Set("signature", s.sign(key, fileName, expiresAt))
+	return s.publicURL + "?" + query.Encode(), nil
+}
+
+// Verify 校验签名URL的参数，返回对象的本地路径
+func (s *LocalStorage) Verify(key, fileName, expires, signature string) (string, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", errors.New("下载链接无效")
+	}
+	if time.Now().Unix() > expiresAt {
+		return "", errors.New("下载链接已过期")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, fileName, expires))) {
+		return "", errors.New("下载链接签名错误")
+	}
+	return s.Path(key)
+}
+
+// Path 返回对象的本地路径，拒绝跳出存储目录的 key
+func (s *LocalStorage) Path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("非法的文件路径: %s", key)
+	}
+	return filepath.Join(s.dir, cleaned), nil
+}
+
+// sign 计算签名
+func (s *LocalStorage) sign(key, fileName, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + fileName + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ddoalistdownload/backend/config"
+	"github.com/sirupsen/logrus"
+)
+
+// 存储类型
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 导出文件存储
+type Storage interface {
+	// Type 返回存储类型
+	Type() string
+	// Put 写入对象，size 未知时传 -1
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	// Open 读取对象
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// SignedURL 生成带有效期的下载地址，fileName 为浏览器下载时保存的文件名
+	SignedURL(ctx context.Context, key, fileName string, expires time.Duration) (string, error)
+}
+
+var defaultStorage Storage
+
+// Init 根据配置初始化全局存储
+func Init(cfg *config.StorageConfig) error {
+	var (
+		s   Storage
+		err error
+	)
+
+	switch cfg.Type {
+	case "", TypeLocal:
+		s, err = NewLocalStorage(cfg.LocalDir, cfg.PublicURL, cfg.SignSecret)
+	case TypeS3:
+		s, err = NewS3Storage(cfg)
+	default:
+		err = fmt.Errorf("不支持的存储类型: %s", cfg.Type)
+	}
+	if err != nil {
+		logrus.Errorf("初始化文件存储失败: %v", err)
+		return err
+	}
+
+	defaultStorage = s
+	logrus.Infof("文件存储初始化成功，类型: %s", s.Type())
+	return nil
+}
+
+// Get 获取全局存储
+func Get() Storage {
+	return defaultStorage
+}
+
+// Set 替换全局存储，用于测试
+func Set(s Storage) {
+	defaultStorage = s
+}
@@ -0,0 +1,11 @@
+package util
+
+// ContainsString reports whether values contains target
+func ContainsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
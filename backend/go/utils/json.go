@@ -9,3 +9,12 @@ import (
 func ParseJSON(r io.Reader, v interface{}) error {
 	return json.NewDecoder(r).Decode(v)
 }
+
+// mustMarshal 序列化为JSON字符串，失败时返回空字符串
+func mustMarshal(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
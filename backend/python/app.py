@@ -1,9 +1,10 @@
-from flask import Flask, jsonify
+from flask import Flask, jsonify, request
 from flask_cors import CORS
 import logging
 from config.config import SERVER_PORT
 from database.db import engine, Base
 from database.redis import test_redis_connection
+from legacy.executor import execute, check_secret, LegacyRequestError
 
 # 配置日志
 logging.basicConfig(
@@ -44,6 +45,17 @@ def legacy_api():
         }
     })
 
+@app.route('/api/v1/legacy/execute', methods=['POST'])
+def legacy_execute():
+    """代理 Go 服务调用旧版API（APIConfig.Type == 2），需携带 X-Legacy-Secret 共享密钥"""
+    if not check_secret(request.headers.get('X-Legacy-Secret')):
+        return jsonify({"error": "共享密钥错误"}), 403
+    try:
+        result = execute(request.get_json(silent=True))
+    except LegacyRequestError as e:
+        return jsonify({"error": str(e)}), 400
+    return jsonify(result)
+
 if __name__ == '__main__':
     logger.info("启动 DdOaListDownload Python 服务")
     
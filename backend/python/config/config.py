@@ -24,3 +24,11 @@ REDIS_DB = int(os.getenv('REDIS_DB', '0'))
 # 钉钉配置
 DINGTALK_APPKEY = os.getenv('DINGTALK_APPKEY', '')
 DINGTALK_APPSECRET = os.getenv('DINGTALK_APPSECRET', '')
+
+# 旧版API代理配置
+LEGACY_DEFAULT_TIMEOUT = float(os.getenv('LEGACY_DEFAULT_TIMEOUT', '30'))
+# 与 Go 服务约定的共享密钥，未配置时拒绝所有代理调用
+LEGACY_SERVICE_SECRET = os.getenv('LEGACY_SERVICE_SECRET', '')
+# 允许代理调用的钉钉接口地址，与 Go 服务的 DINGTALK_OAPI_BASE_URL、DINGTALK_API_BASE_URL 一致
+DINGTALK_OAPI_BASE_URL = os.getenv('DINGTALK_OAPI_BASE_URL', 'https://oapi.dingtalk.com')
+DINGTALK_API_BASE_URL = os.getenv('DINGTALK_API_BASE_URL', 'https://api.dingtalk.com')
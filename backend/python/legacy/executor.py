@@ -0,0 +1,116 @@
+import hmac
+import time
+import logging
+from urllib.parse import urlsplit
+import requests
+from config.config import (
+    LEGACY_DEFAULT_TIMEOUT,
+    LEGACY_SERVICE_SECRET,
+    DINGTALK_OAPI_BASE_URL,
+    DINGTALK_API_BASE_URL,
+)
+
+logger = logging.getLogger(__name__)
+
+# 支持的请求方法
+ALLOWED_METHODS = {'GET', 'POST', 'PUT', 'DELETE', 'PATCH'}
+
+# 不转发的请求头和响应头，由 requests 重新生成
+HOP_BY_HOP_HEADERS = {'host', 'content-length', 'connection', 'transfer-encoding', 'content-encoding', 'keep-alive'}
+
+
+class LegacyRequestError(Exception):
+    """调用请求不合法"""
+
+
+def _origin(url):
+    """返回地址的 scheme://host[:port]，统一小写"""
+    parts = urlsplit(url)
+    return f'{parts.scheme.lower()}://{parts.netloc.lower()}'
+
+
+# 只允许调用配置的钉钉接口地址，避免被用作访问内网的代理
+ALLOWED_ORIGINS = {_origin(DINGTALK_OAPI_BASE_URL), _origin(DINGTALK_API_BASE_URL)}
+
+
+def check_secret(secret):
+    """校验 Go 服务携带的共享密钥，未配置密钥时一律拒绝"""
+    if not LEGACY_SERVICE_SECRET:
+        logger.error('未配置 LEGACY_SERVICE_SECRET，拒绝旧版API代理调用')
+        return False
+    return hmac.compare_digest((secret or '').encode('utf-8'), LEGACY_SERVICE_SECRET.encode('utf-8'))
+
+
+def execute(payload):
+    """按 Go 服务传入的请求调用旧版钉钉接口
+
+    请求：{company_id, api_config_id, api_code, method, url, headers, body, timeout_ms}
+    返回：{status_code, status, headers, body, duration, error}，error 不为空表示未拿到接口响应
+    """
+    if not isinstance(payload, dict):
+        raise LegacyRequestError('请求体必须是JSON对象')
+
+    method = str(payload.get('method') or 'GET').upper()
+    if method not in ALLOWED_METHODS:
+        raise LegacyRequestError(f'不支持的请求方法: {method}')
+
+    url = payload.get('url') or ''
+    if not url.startswith(('http://', 'https://')):
+        raise LegacyRequestError('url 必须是 http 或 https 地址')
+    if _origin(url) not in ALLOWED_ORIGINS:
+        raise LegacyRequestError('url 不是允许调用的钉钉接口地址')
+
+    headers = {
+        k: v for k, v in (payload.get('headers') or {}).items()
+        if k.lower() not in HOP_BY_HOP_HEADERS
+    }
+    body = payload.get('body') or None
+    timeout_ms = payload.get('timeout_ms') or 0
+    timeout = timeout_ms / 1000 if timeout_ms > 0 else LEGACY_DEFAULT_TIMEOUT
+
+    start = time.time()
+    try:
+        resp = requests.request(
+            method,
+            url,
+            headers=headers,
+            data=body.encode('utf-8') if body else None,
+            timeout=timeout,
+            # 不跟随重定向，避免被重定向到允许范围以外的地址
+            allow_redirects=False,
+        )
+    except requests.Timeout:
+        logger.warning(f"调用旧版接口超时，配置ID: {payload.get('api_config_id')}, 方法: {method}")
+        return _failed(f'调用接口超时（{timeout}秒）', start)
+    except requests.RequestException as e:
+        logger.error(f"调用旧版接口失败，配置ID: {payload.get('api_config_id')}, 错误: {str(e)}")
+        return _failed(f'调用接口失败: {str(e)}', start)
+
+    return {
+        'status_code': resp.status_code,
+        'status': f'{resp.status_code} {resp.reason}',
+        'headers': {
+            k: v for k, v in resp.headers.items()
+            if k.lower() not in HOP_BY_HOP_HEADERS
+        },
+        'body': resp.text,
+        'duration': _elapsed_ms(start),
+        'error': '',
+    }
+
+
+def _failed(message, start):
+    """构造未拿到接口响应时的结果"""
+    return {
+        'status_code': 0,
+        'status': '',
+        'headers': {},
+        'body': '',
+        'duration': _elapsed_ms(start),
+        'error': message,
+    }
+
+
+def _elapsed_ms(start):
+    """返回从 start 开始的耗时（毫秒）"""
+    return int((time.time() - start) * 1000)